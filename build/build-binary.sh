@@ -11,6 +11,8 @@ read -r -a PLATFORMS <<< "$PLATFORMS"
 echo "PLATFORMS: $PLATFORMS"
 echo "BUILD_DIR: $BUILD_DIR"
 echo "BIN: $BIN"
+# GOFIPS140 builds in the Go Cryptographic Module in FIPS 140-3 mode, e.g. v1.0.0
+echo "GOFIPS140: ${GOFIPS140:-off}"
 
 rm -rf $BUILD_DIR
 mkdir -p $BUILD_DIR
@@ -26,11 +28,14 @@ for p in "${PLATFORMS[@]}"; do
     echo "ARCH: $GOARCH"
     echo "VERSION: $VERSION"
     final_name=$BIN'-'$GOOS'-'$GOARCH
+    if [ -n "$GOFIPS140" ] && [ "$GOFIPS140" != "off" ]; then
+        final_name+='-fips'
+    fi
     if [ "$GOOS" = "windows" ]; then
         final_name+='.exe'
     fi
 
-    env GOOS="$GOOS" GOARCH="$GOARCH" CGO_ENABLED=0 go build -ldflags "-X 'main.Version=$VERSION'" -o $BUILD_DIR/$final_name ../ || errorExit "Building $final_name failed"
+    env GOOS="$GOOS" GOARCH="$GOARCH" CGO_ENABLED=0 GOFIPS140="${GOFIPS140:-off}" go build -ldflags "-X 'main.Version=$VERSION'" -o $BUILD_DIR/$final_name ../ || errorExit "Building $final_name failed"
 done
 
 echo -e "\nDone!\nThe following binaries were created in the bin/ directory:"
@@ -38,6 +38,14 @@ env:
     value: {{ not $values.autoUpgrade | quote }}
   - name: log_level
     value: {{ $values.logLevel | quote }}
+  {{- if $values.fips }}
+  - name: GODEBUG
+    value: "fips140=on"
+  {{- end }}
+  {{- range $name, $value := $values.autoUpgradePolicy }}
+  - name: {{ $name }}
+    value: {{ $value | toString | quote }}
+  {{- end }}
   {{- if $item.http_timeout_seconds }}
   - name: http_timeout_seconds
     value: {{ $item.http_timeout_seconds | quote }}
@@ -46,6 +54,10 @@ env:
   - name: aws_region
     value: {{ $item.aws.aws_region | quote }}
   {{- end }}
+  {{- if $item.aws.aws_signing_algorithm }}
+  - name: aws_signing_algorithm
+    value: {{ $item.aws.aws_signing_algorithm | quote }}
+  {{- end }}
   {{- if eq $item.aws.aws_auth_method "cognito_oidc" }}
   - name: secret_name
     value: {{ $item.aws.aws_cognito_user_pool_secret_name | quote }}
@@ -64,5 +76,17 @@ env:
   {{- end }}
   - name: secret_ttl_seconds
     value: {{ ($item.aws.secret_ttl_seconds | default 14400) | quote }}
+  {{- else if eq $item.aws.aws_auth_method "pod_identity" }}
+  - name: aws_container_authorization_token_file
+    value: {{ $item.aws.aws_container_authorization_token_file | quote }}
+  {{- if $item.aws.aws_container_credentials_full_uri }}
+  - name: aws_container_credentials_full_uri
+    value: {{ $item.aws.aws_container_credentials_full_uri | quote }}
+  {{- end }}
+  {{- else if eq $item.aws.aws_auth_method "web_identity_token_file" }}
+  - name: aws_web_identity_token_file
+    value: {{ $item.aws.aws_web_identity_token_file | quote }}
+  - name: aws_role_name
+    value: {{ $item.aws.aws_role_name | quote }}
   {{- end }}
 {{- end }}
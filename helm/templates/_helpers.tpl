@@ -288,6 +288,10 @@ env:
   - name: azure_cloud_name
     value: {{ $item.azure.azure_cloud_name | quote }}
   {{- end }}
+  {{- if $item.azure.azure_authority_host }}
+  - name: azure_authority_host
+    value: {{ $item.azure.azure_authority_host | quote }}
+  {{- end }}
   {{- if $item.azure.azure_tenant_id }}
   - name: azure_tenant_id
     value: {{ $item.azure.azure_tenant_id | quote }}
@@ -316,6 +320,14 @@ env:
     value: {{ not $values.autoUpgrade | quote }}
   - name: log_level
     value: {{ $values.logLevel | quote }}
+  {{- if $values.fips }}
+  - name: GODEBUG
+    value: "fips140=on"
+  {{- end }}
+  {{- range $name, $value := $values.autoUpgradePolicy }}
+  - name: {{ $name }}
+    value: {{ $value | toString | quote }}
+  {{- end }}
   {{- if $item.http_timeout_seconds }}
   - name: http_timeout_seconds
     value: {{ $item.http_timeout_seconds | quote }}
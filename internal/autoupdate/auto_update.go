@@ -17,12 +17,16 @@ package autoupdate
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
 	"jfrog-credential-provider/internal/logger"
 	"jfrog-credential-provider/internal/utils"
 	"net/http"
 	"os"
 	"runtime"
 	"syscall"
+	"time"
 )
 
 // AutoUpdate checks for a new version, downloads, verifies, validates, and replaces the current binary if an update is available.
@@ -34,6 +38,15 @@ func AutoUpdate(request utils.CredentialProviderRequest, logs *logger.Logger, cl
 		logs.Info("Auto-update functionality is disabled. Skipping auto-update process.")
 		runtime.Goexit()
 	}
+	policy, err := utils.ParseAutoUpdatePolicy(os.Getenv)
+	if err != nil {
+		logs.Error("Invalid auto-update policy: " + err.Error())
+		runtime.Goexit()
+	}
+	if policy.Window != nil && !policy.Window.Contains(time.Now()) {
+		logs.Info("Outside of the autoupdate_window maintenance window. Skipping auto-update process.")
+		runtime.Goexit()
+	}
 
 	currentBinaryPath := utils.GetCurrentBinaryPath(logs)
 	// check if lock exists
@@ -60,8 +73,8 @@ func AutoUpdate(request utils.CredentialProviderRequest, logs *logger.Logger, cl
 
 	logs.Info("jfrogPluginReleasesUrl: " + jfrogPluginReleasesUrl)
 
-	// Step 1: Fetch the latest minor version tag from the JFrog plugin releases URL
-	latestBinaryVersionAvailable, err := fetchLatestVersionTag(ctx, client, Version, jfrogPluginReleasesUrl, logs)
+	// Step 1: Fetch the version the auto-update policy selects from the JFrog plugin releases URL
+	latestBinaryVersionAvailable, err := fetchLatestVersionTag(ctx, client, Version, jfrogPluginReleasesUrl, policy, logs)
 	if err != nil {
 		logs.Error("Failed to fetch latest version tag: " + err.Error())
 		runtime.Goexit()
@@ -71,8 +84,26 @@ func AutoUpdate(request utils.CredentialProviderRequest, logs *logger.Logger, cl
 		runtime.Goexit()
 	}
 	logs.Info("Latest binary version available: " + latestBinaryVersionAvailable)
+	if node, _ := os.Hostname(); !inRollout(node, latestBinaryVersionAvailable, policy.RolloutPercentage) {
+		logs.Info(fmt.Sprintf("Node %s is not among the %d%% of nodes updating to %s yet. Skipping auto-update process.", node, policy.RolloutPercentage, latestBinaryVersionAvailable))
+		runtime.Goexit()
+	}
 	newBinaryPath := currentBinaryPath + latestBinaryVersionAvailable
-	newBinarySigPath := newBinaryPath + ".asc"
+	sigstore, err := loadSigstorePolicy(logs)
+	if err != nil {
+		logs.Error("Failed to load Sigstore verification policy: " + err.Error())
+		runtime.Goexit()
+	}
+	artifacts := releaseArtifacts{
+		binaryName:    releaseBinaryName(logs),
+		binaryPath:    newBinaryPath,
+		manifestPath:  newBinaryPath + "." + checksumManifestName,
+		signaturePath: newBinaryPath + "." + checksumManifestName + manifestSignatureSuffix,
+	}
+	if sigstore != nil {
+		artifacts.bundlePath = newBinaryPath + "." + checksumManifestName + sigstoreBundleSuffix
+	}
+	defer func() { removeReleaseMetadata(logs, artifacts) }()
 
 	// Different from releases URL, this is the download URL for the JFrog credential provider binary.
 	jfrogPluginDownloadUrl := utils.GetEnvs(logs, "JFROG_CREDENTIAL_PROVIDER_DOWNLOAD_URL", "https://releases.jfrog.io/artifactory/run/jfrog-credentials-provider")
@@ -82,17 +113,17 @@ func AutoUpdate(request utils.CredentialProviderRequest, logs *logger.Logger, cl
 	// does not need to be set in usual cases
 	downloadSuffix := utils.GetEnvs(logs, "JFROG_CREDENTIAL_PROVIDER_DOWNLOAD_SUFFIX", "/")
 
-	// Step 2: Download the latest binary and its signature
-	err = downloadLatestBinary(ctx, logs, client, latestBinaryVersionAvailable, newBinaryPath, newBinarySigPath, jfrogPluginDownloadUrl, downloadSuffix)
+	// Step 2: Download the latest binary, the checksum manifest and its signatures
+	err = downloadLatestBinary(ctx, logs, client, latestBinaryVersionAvailable, &artifacts, jfrogPluginDownloadUrl, downloadSuffix)
 	if err != nil {
 		logs.Error("Failed to download latest binary: " + err.Error())
 		runtime.Goexit()
 	}
 
-	// Step 3: Verify the downloaded binary with its signature
-	err = verifyBinaryWithSignature(logs, newBinaryPath, newBinarySigPath)
+	// Step 3: Verify the signed checksum manifest and the binary against it
+	err = verifyRelease(logs, artifacts, sigstore, time.Now())
 	if err != nil {
-		logs.Error("Failed to verify binary with signature: " + err.Error())
+		logs.Error("Failed to verify binary with the signed checksum manifest: " + err.Error())
 		runtime.Goexit()
 	}
 
@@ -113,3 +144,23 @@ func AutoUpdate(request utils.CredentialProviderRequest, logs *logger.Logger, cl
 	}
 	logs.Info("Auto-update to version " + latestBinaryVersionAvailable + " completed successfully. New binary is now in use for the next session.")
 }
+
+// removeReleaseMetadata removes the downloaded manifest, signatures and bundle.
+func removeReleaseMetadata(logs *logger.Logger, artifacts releaseArtifacts) {
+	for _, path := range []string{artifacts.manifestPath, artifacts.signaturePath, artifacts.bundlePath, artifacts.legacySignaturePath} {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logs.Error("Failed to remove " + path + ": " + err.Error())
+		}
+	}
+}
+
+// inRollout reports whether node is among the percentage of nodes updating
+// to version. Nodes fall in or out consistently, and a different share of
+// the fleet goes first for every release.
+func inRollout(node, version string, percentage int) bool {
+	sum := sha256.Sum256([]byte(node + "/" + version))
+	return int(binary.BigEndian.Uint32(sum[:]))%100 < percentage
+}
@@ -17,9 +17,12 @@ package autoupdate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"jfrog-credential-provider/internal/fips"
 	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
 	"net/http"
 	"os"
 	"runtime"
@@ -38,8 +41,8 @@ func addVPrefix(logs *logger.Logger, versionTag string) string {
 	return versionTag
 }
 
-// fetchLatestVersionTag fetches the latest available release tag from the JFrog plugin releases URL and compares it to the current version. Outputs a new version tag if a newer version is available.
-func fetchLatestVersionTag(ctx context.Context, client *http.Client, currentVersion string, jfrogPluginReleasesUrl string, logs *logger.Logger) (string, error) {
+// fetchLatestVersionTag fetches the release tags from the JFrog plugin releases URL and outputs the version policy selects, the pinned version or the latest one its channel allows, empty if there is none to update to.
+func fetchLatestVersionTag(ctx context.Context, client *http.Client, currentVersion string, jfrogPluginReleasesUrl string, policy utils.AutoUpdatePolicy, logs *logger.Logger) (string, error) {
 	// jfrogPluginReleasesUrl = jfrogPluginReleasesUrl + "/releases"
 	request, err := http.NewRequestWithContext(ctx, "GET", jfrogPluginReleasesUrl, nil)
 	logs.Info("Fetching latest version from: " + jfrogPluginReleasesUrl)
@@ -87,6 +90,7 @@ func fetchLatestVersionTag(ctx context.Context, client *http.Client, currentVers
 		logs.Error("Error: children is not a slice")
 		return "", fmt.Errorf("invalid response structure, expected 'children' to be a slice")
 	}
+	var pinFound bool
 	for _, release := range releases {
 		releaseMap, ok := release.(map[string]interface{})
 		if !ok {
@@ -100,20 +104,47 @@ func fetchLatestVersionTag(ctx context.Context, client *http.Client, currentVers
 		}
 		releaseName = strings.TrimPrefix(releaseName, "/")
 		releaseName = addVPrefix(logs, releaseName)
+		if !semver.IsValid(releaseName) {
+			continue
+		}
+		if policy.Pin != "" {
+			pinFound = pinFound || semver.Compare(releaseName, policy.Pin) == 0
+			continue
+		}
 		logs.Debug("Checking if " + releaseName + " version is latest")
-		if semver.IsValid(releaseName) && semver.Compare(latestVersionTag, releaseName) < 0 {
+		if policy.Denied(releaseName) {
+			logs.Info("Skipping denylisted version " + releaseName)
+			continue
+		}
+		if semver.Compare(latestVersionTag, releaseName) < 0 && policy.Allows(currentVersion, releaseName) {
 			logs.Debug("Found newer version: " + releaseName)
 			latestVersionTag = releaseName
 		}
 	}
 
+	if policy.Pin != "" {
+		switch {
+		case semver.Compare(policy.Pin, addVPrefix(logs, currentVersion)) == 0:
+			logs.Info("Running the pinned version " + policy.Pin)
+			return "", nil
+		case !pinFound:
+			return "", fmt.Errorf("pinned version %s is not released", policy.Pin)
+		}
+		logs.Info("Moving to the pinned version " + policy.Pin)
+		return policy.Pin, nil
+	}
+
 	if latestVersionTag == addVPrefix(logs, currentVersion) {
-		logs.Info("No newer version available")
+		logs.Info("No newer version available in the " + string(policy.Channel) + " channel")
 		return "", nil
 	}
 	return latestVersionTag, nil
 }
 
+// errReleaseArtifactNotFound is returned by downloadReleaseArtifacts when the
+// release has no such artifact.
+var errReleaseArtifactNotFound = errors.New("release artifact not found")
+
 // downloadReleaseArtifacts downloads a release artifact from the given URL to the specified file path.
 func downloadReleaseArtifacts(ctx context.Context, logs *logger.Logger, client *http.Client, filepath string, downloadUrl string) error {
 	logs.Info("Downloading release artifacts from: " + downloadUrl + " to " + filepath)
@@ -147,6 +178,11 @@ func downloadReleaseArtifacts(ctx context.Context, logs *logger.Logger, client *
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode == http.StatusNotFound {
+		out.Close()
+		os.Remove(filepath)
+		return fmt.Errorf("%w: %s", errReleaseArtifactNotFound, downloadUrl)
+	}
 	if response.StatusCode != http.StatusOK {
 		logs.Error("Error: received non-200 response code: " + fmt.Sprint(response.StatusCode))
 	}
@@ -177,27 +213,46 @@ func getArchSuffix(logs *logger.Logger) string {
 	}
 }
 
-// downloadLatestBinary downloads the latest binary and its signature for the specified version and architecture.
-func downloadLatestBinary(ctx context.Context, logs *logger.Logger, client *http.Client, newVersion string, newBinaryPath string, newBinarySigPath string, jfrogPluginDownloadUrl string, downloadSuffix string) error {
+// releaseBinaryName returns the name of the release binary for this
+// platform, the FIPS 140-3 build when this binary is one.
+func releaseBinaryName(logs *logger.Logger) string {
+	name := "jfrog-credential-provider-linux-" + getArchSuffix(logs)
+	if fips.Module() != "" {
+		name += "-fips"
+	}
+	return name
+}
+
+// downloadLatestBinary downloads the latest binary, the checksum manifest of
+// its release and the manifest signatures and Sigstore bundle. For releases
+// published without a manifest it downloads the OpenPGP signature of the
+// binary instead and sets artifacts.legacySignaturePath.
+func downloadLatestBinary(ctx context.Context, logs *logger.Logger, client *http.Client, newVersion string, artifacts *releaseArtifacts, jfrogPluginDownloadUrl string, downloadSuffix string) error {
 	// check if new version has v prefix, if yes, remove it
 	if strings.HasPrefix(newVersion, "v") {
 		logs.Info("Release tag '%s' is missing 'v' prefix. Prepending 'v'." + newVersion)
 		newVersion = strings.TrimPrefix(newVersion, "v")
 	}
-	downloadUrl := jfrogPluginDownloadUrl + downloadSuffix + newVersion + "/jfrog-credential-provider-linux-" + getArchSuffix(logs)
-	logs.Info("Downloading new binary from: " + downloadUrl)
-	downloadSignUrl := downloadUrl + ".asc"
-	err := downloadReleaseArtifacts(ctx, logs, client, newBinaryPath, downloadUrl)
-	if err != nil {
-		logs.Error("Failed to download new binary: " + err.Error())
-		return err
+	releaseUrl := jfrogPluginDownloadUrl + downloadSuffix + newVersion + "/"
+	downloads := []struct{ name, path string }{
+		{artifacts.binaryName, artifacts.binaryPath},
+		{checksumManifestName, artifacts.manifestPath},
+		{checksumManifestName + manifestSignatureSuffix, artifacts.signaturePath},
 	}
-
-	err = downloadReleaseArtifacts(ctx, logs, client, newBinarySigPath, downloadSignUrl)
-	if err != nil {
-		logs.Error("Failed to download new binary signature: " + err.Error())
-		return err
+	if artifacts.bundlePath != "" {
+		downloads = append(downloads, struct{ name, path string }{checksumManifestName + sigstoreBundleSuffix, artifacts.bundlePath})
+	}
+	for _, download := range downloads {
+		err := downloadReleaseArtifacts(ctx, logs, client, download.path, releaseUrl+download.name)
+		if download.path == artifacts.manifestPath && errors.Is(err, errReleaseArtifactNotFound) {
+			logs.Info("Release " + newVersion + " has no " + checksumManifestName + ", downloading the OpenPGP signature of the binary")
+			artifacts.legacySignaturePath = artifacts.binaryPath + legacySignatureSuffix
+			return downloadReleaseArtifacts(ctx, logs, client, artifacts.legacySignaturePath, releaseUrl+artifacts.binaryName+legacySignatureSuffix)
+		}
+		if err != nil {
+			logs.Error("Failed to download " + download.name + ": " + err.Error())
+			return err
+		}
 	}
-	logs.Info("Attempting to download from: " + downloadUrl)
 	return nil
 }
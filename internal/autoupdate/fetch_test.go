@@ -0,0 +1,155 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"jfrog-credential-provider/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestFetchLatestVersionTagPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"children": [{"uri": "/1.4.1"}, {"uri": "/1.4.2"}, {"uri": "/1.4.3"}, {"uri": "/1.4.4"}, {"uri": "/1.5.0"}, {"uri": "/2.0.0"}, {"uri": "/latest"}]}`)
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		env      map[string]string
+		expected string
+	}{
+		"major channel":           {nil, "v2.0.0"},
+		"minor channel":           {map[string]string{"autoupdate_channel": "minor"}, "v1.5.0"},
+		"patch channel":           {map[string]string{"autoupdate_channel": "patch"}, "v1.4.4"},
+		"patch channel denylist":  {map[string]string{"autoupdate_channel": "patch", "autoupdate_denylist": "1.4.4"}, "v1.4.3"},
+		"everything denied":       {map[string]string{"autoupdate_channel": "patch", "autoupdate_denylist": "1.4.3,1.4.4"}, ""},
+		"pin":                     {map[string]string{"autoupdate_pin": "1.4.3"}, "v1.4.3"},
+		"pin to the current one":  {map[string]string{"autoupdate_pin": "v1.4.2"}, ""},
+		"pin to an older release": {map[string]string{"autoupdate_pin": "1.4.1"}, "v1.4.1"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy, err := utils.ParseAutoUpdatePolicy(func(name string) string { return tt.env[name] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			version, err := fetchLatestVersionTag(context.Background(), server.Client(), "1.4.2", server.URL, policy, testLogger())
+			if err != nil || version != tt.expected {
+				t.Fatalf("expected %q, got %q, %v", tt.expected, version, err)
+			}
+		})
+	}
+
+	policy, _ := utils.ParseAutoUpdatePolicy(func(name string) string { return map[string]string{"autoupdate_pin": "1.9.9"}[name] })
+	if _, err := fetchLatestVersionTag(context.Background(), server.Client(), "1.4.2", server.URL, policy, testLogger()); err == nil {
+		t.Fatal("expected an error for a pinned version that is not released")
+	}
+}
+
+func TestInRollout(t *testing.T) {
+	updating := 0
+	for i := range 1000 {
+		if inRollout(fmt.Sprintf("ip-10-0-%d-%d.ec2.internal", i/250, i%250), "v1.5.0", 25) {
+			updating++
+		}
+	}
+	if updating < 200 || updating > 300 {
+		t.Fatalf("expected about a quarter of the nodes to update, got %d of 1000", updating)
+	}
+	if !inRollout("node", "v1.5.0", 100) || inRollout("node", "v1.5.0", 0) {
+		t.Fatal("expected every node at 100% and none at 0%")
+	}
+	if inRollout("node", "v1.5.0", 50) != inRollout("node", "v1.5.0", 50) {
+		t.Fatal("expected a node to fall in or out of a rollout consistently")
+	}
+}
+
+func TestDownloadAndVerifyReleaseWithoutManifest(t *testing.T) {
+	entity, err := openpgp.NewEntity("release", "", "release@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var armoredKey bytes.Buffer
+	w, err := armor.Encode(&armoredKey, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	original := legacyPublicKey
+	legacyPublicKey = armoredKey.String()
+	t.Cleanup(func() { legacyPublicKey = original })
+
+	binary := []byte("#!/bin/sh\necho 1.4.1\n")
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader(binary), nil); err != nil {
+		t.Fatal(err)
+	}
+	binaryName := "jfrog-credential-provider-linux-amd64"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.4.1/" + binaryName:
+			w.Write(binary)
+		case "/1.4.1/" + binaryName + legacySignatureSuffix:
+			w.Write(signature.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	binaryPath := filepath.Join(dir, "provider1.4.1")
+	artifacts := releaseArtifacts{
+		binaryName:    binaryName,
+		binaryPath:    binaryPath,
+		manifestPath:  binaryPath + "." + checksumManifestName,
+		signaturePath: binaryPath + "." + checksumManifestName + manifestSignatureSuffix,
+	}
+	if err := downloadLatestBinary(context.Background(), testLogger(), server.Client(), "v1.4.1", &artifacts, server.URL, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if artifacts.legacySignaturePath != binaryPath+legacySignatureSuffix {
+		t.Fatalf("expected the OpenPGP signature to be downloaded, got %+v", artifacts)
+	}
+	if _, err := os.Stat(artifacts.manifestPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no manifest file for a missing manifest, got %v", err)
+	}
+	if err := verifyRelease(testLogger(), artifacts, nil, time.Now()); err != nil {
+		t.Fatalf("expected the pre-manifest release to verify, got %v", err)
+	}
+	if err := verifyRelease(testLogger(), artifacts, &sigstorePolicy{}, time.Now()); err == nil || !strings.Contains(err.Error(), "Sigstore") {
+		t.Fatalf("expected a required Sigstore bundle to refuse the release, got %v", err)
+	}
+	if err := os.WriteFile(binaryPath, []byte("tampered"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyRelease(testLogger(), artifacts, nil, time.Now()); err == nil {
+		t.Fatal("expected a tampered binary to fail verification")
+	}
+}
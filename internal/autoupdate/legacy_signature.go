@@ -1,4 +1,3 @@
-// Package autoupdate provides signature verification for the JFrog credential provider auto-update process.
 // Copyright (c) JFrog Ltd. (2025)
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
@@ -17,6 +16,8 @@ package autoupdate
 
 import (
 	"bytes"
+	"fmt"
+	"jfrog-credential-provider/internal/fips"
 	"jfrog-credential-provider/internal/logger"
 	"os"
 	"strings"
@@ -24,7 +25,13 @@ import (
 	"golang.org/x/crypto/openpgp"
 )
 
-const publicKey = `
+// legacySignatureSuffix names the OpenPGP signature of the binary that
+// releases published before the checksum manifest carry instead of it.
+const legacySignatureSuffix = ".asc"
+
+// legacyPublicKey is the OpenPGP key that signed the binaries of releases
+// without a checksum manifest.
+var legacyPublicKey = `
 -----BEGIN PGP PUBLIC KEY BLOCK-----
 
 mQINBGg+x1sBEADJxcIFZYF0DsgFaK2FXqmYJbTwkGuG59eXMfQnASrCX8GoF6sf
@@ -57,10 +64,23 @@ R2FfWks6AgUeIK6mEkt3TcPK1EyuPY9m65d/aJynSPD2xt0/2f1d6eDvHH2Maa0i
 -----END PGP PUBLIC KEY BLOCK-----
 `
 
+// verifyLegacyRelease verifies a release without a checksum manifest by the
+// OpenPGP signature of its binary. Such releases cannot satisfy a Sigstore
+// policy, and OpenPGP is outside the FIPS module, so both refuse them.
+func verifyLegacyRelease(logs *logger.Logger, artifacts releaseArtifacts, policy *sigstorePolicy) error {
+	if policy != nil {
+		return fmt.Errorf("the release has no checksum manifest to verify the required Sigstore bundle of")
+	}
+	if err := fips.Check("verifying a release without a checksum manifest with OpenPGP"); err != nil {
+		return err
+	}
+	logs.Info("The release has no checksum manifest, verifying the OpenPGP signature of the binary")
+	return verifyBinaryWithSignature(logs, artifacts.binaryPath, artifacts.legacySignaturePath)
+}
+
 // verifyBinaryWithSignature verifies the binary file against its signature using the embedded public PGP key.
 func verifyBinaryWithSignature(logs *logger.Logger, binaryPath string, signaturePath string) error {
-	publicKey := publicKey
-	keyRing, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	keyRing, err := openpgp.ReadArmoredKeyRing(strings.NewReader(legacyPublicKey))
 	if err != nil {
 		logs.Error("failed to read public key: " + err.Error())
 		return err
@@ -0,0 +1,166 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupdate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"jfrog-credential-provider/internal/logger"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// checksumManifestName is the sha256sum output of a release's binaries
+	checksumManifestName = "SHA256SUMS"
+	// manifestSignatureSuffix names the manifest signatures, one base64
+	// signature per line as cosign sign-blob prints it, one line per key
+	manifestSignatureSuffix = ".sig"
+	// sigstoreBundleSuffix names the optional Sigstore bundle of the manifest
+	sigstoreBundleSuffix = ".sigstore.json"
+)
+
+// releaseArtifacts are the downloaded files of a release.
+type releaseArtifacts struct {
+	// binaryName is the name of the binary in the manifest
+	binaryName    string
+	binaryPath    string
+	manifestPath  string
+	signaturePath string
+	// bundlePath is empty when no Sigstore bundle is verified
+	bundlePath string
+	// legacySignaturePath is set instead of the manifest paths for releases
+	// published without a checksum manifest
+	legacySignaturePath string
+}
+
+// verifyRelease checks the manifest signatures with the trusted keys, the
+// Sigstore bundle of the manifest under policy when there is one, and the
+// binary against its checksum in the manifest. Releases without a manifest
+// are verified by the OpenPGP signature of their binary.
+func verifyRelease(logs *logger.Logger, artifacts releaseArtifacts, policy *sigstorePolicy, now time.Time) error {
+	if artifacts.legacySignaturePath != "" {
+		return verifyLegacyRelease(logs, artifacts, policy)
+	}
+	keys, err := loadTrustedKeys(logs)
+	if err != nil {
+		return err
+	}
+	manifest, err := os.ReadFile(artifacts.manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to read checksum manifest: %w", err)
+	}
+	signatures, err := os.ReadFile(artifacts.signaturePath)
+	if err != nil {
+		return fmt.Errorf("failed to read checksum manifest signature: %w", err)
+	}
+	key, err := verifyManifestSignatures(manifest, signatures, keys, now)
+	if err != nil {
+		return err
+	}
+	logs.Info("Checksum manifest signature verified with trusted key: " + key.ID)
+
+	if artifacts.bundlePath != "" {
+		bundle, err := os.ReadFile(artifacts.bundlePath)
+		if err != nil {
+			return fmt.Errorf("failed to read Sigstore bundle: %w", err)
+		}
+		if err := verifySigstoreBundle(bundle, manifest, keys, policy); err != nil {
+			return fmt.Errorf("Sigstore bundle verification failed: %w", err)
+		}
+		logs.Info("Sigstore bundle of the checksum manifest verified")
+	}
+
+	checksums, err := parseChecksumManifest(manifest)
+	if err != nil {
+		return err
+	}
+	if err := verifyBinaryChecksum(checksums, artifacts.binaryName, artifacts.binaryPath); err != nil {
+		return err
+	}
+	logs.Info("Checksum verification successful for binary: " + artifacts.binaryPath)
+	return nil
+}
+
+// verifyManifestSignatures returns the trusted key, valid at now, that one
+// of the signatures verifies manifest with. Releases carry a signature per
+// key while keys rotate, so updaters knowing either key accept them.
+func verifyManifestSignatures(manifest, signatures []byte, keys []TrustedKey, now time.Time) (TrustedKey, error) {
+	for _, line := range strings.Fields(string(signatures)) {
+		signature, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return TrustedKey{}, fmt.Errorf("invalid checksum manifest signature: %w", err)
+		}
+		for _, key := range keys {
+			if key.validAt(now) && key.verify(manifest, signature) {
+				return key, nil
+			}
+		}
+	}
+	return TrustedKey{}, errNoTrustedSignature
+}
+
+// parseChecksumManifest returns the hex SHA-256 by file name of a sha256sum
+// output, "<hex>  <name>" or "<hex> *<name>" lines.
+func parseChecksumManifest(manifest []byte) (map[string]string, error) {
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		if decoded, err := hex.DecodeString(sum); !ok || err != nil || len(decoded) != sha256.Size || name == "" {
+			return nil, fmt.Errorf("invalid checksum manifest line: %q", line)
+		}
+		if _, ok := checksums[name]; ok {
+			return nil, fmt.Errorf("checksum manifest lists %s twice", name)
+		}
+		checksums[name] = strings.ToLower(sum)
+	}
+	return checksums, scanner.Err()
+}
+
+// verifyBinaryChecksum checks that the file at path hashes to the checksum
+// of name in checksums.
+func verifyBinaryChecksum(checksums map[string]string, name, path string) error {
+	expected, ok := checksums[name]
+	if !ok {
+		return fmt.Errorf("checksum manifest has no entry for %s", name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read binary file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to read binary file: %w", err)
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) != 1 {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupdate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"jfrog-credential-provider/internal/logger"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogger() *logger.Logger {
+	return &logger.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+// testSigner is a release signing key and the TrustedKey for it.
+type testSigner struct {
+	signer  crypto.Signer
+	trusted TrustedKey
+}
+
+func newTestSigner(t *testing.T, id string, notBefore, notAfter time.Time) testSigner {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSignerFor(t, priv, id, notBefore, notAfter)
+}
+
+func testSignerFor(t *testing.T, priv crypto.Signer, id string, notBefore, notAfter time.Time) testSigner {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := TrustedKey{ID: id, PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), NotBefore: notBefore, NotAfter: notAfter}
+	parsed, err := key.parsePublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{signer: priv, trusted: parsed}
+}
+
+// sign signs data as cosign sign-blob does.
+func (s testSigner) sign(t *testing.T, data []byte) []byte {
+	t.Helper()
+	if _, ok := s.signer.(ed25519.PrivateKey); ok {
+		sig, err := s.signer.Sign(rand.Reader, data, crypto.Hash(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	digest := sha256.Sum256(data)
+	sig, err := s.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestVerifyManifestSignaturesRotation(t *testing.T) {
+	now := time.Now()
+	manifest := []byte(strings.Repeat("0", 64) + "  jfrog-credential-provider-linux-amd64\n")
+	old := newTestSigner(t, "old", now.Add(-48*time.Hour), now.Add(time.Hour))
+	next := newTestSigner(t, "next", now.Add(-time.Hour), time.Time{})
+	_, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	ed := testSignerFor(t, edPriv, "ed25519", time.Time{}, time.Time{})
+
+	// a release signed with both keys during the rotation
+	signatures := base64.StdEncoding.EncodeToString(old.sign(t, manifest)) + "\n" + base64.StdEncoding.EncodeToString(next.sign(t, manifest)) + "\n"
+
+	tests := map[string]struct {
+		keys     []TrustedKey
+		at       time.Time
+		expected string
+	}{
+		"updater trusting the old key":  {[]TrustedKey{old.trusted}, now, "old"},
+		"updater trusting the next key": {[]TrustedKey{next.trusted}, now, "next"},
+		"old key expired":               {[]TrustedKey{old.trusted, next.trusted}, now.Add(2 * time.Hour), "next"},
+		"next key not yet valid":        {[]TrustedKey{next.trusted}, now.Add(-2 * time.Hour), ""},
+		"untrusted keys":                {[]TrustedKey{ed.trusted}, now, ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := verifyManifestSignatures(manifest, []byte(signatures), tt.keys, tt.at)
+			if tt.expected == "" {
+				if !errors.Is(err, errNoTrustedSignature) {
+					t.Fatalf("expected no trusted signature, got key %q, %v", key.ID, err)
+				}
+				return
+			}
+			if err != nil || key.ID != tt.expected {
+				t.Fatalf("expected key %q, got %q, %v", tt.expected, key.ID, err)
+			}
+		})
+	}
+
+	edSignature := base64.StdEncoding.EncodeToString(ed.sign(t, manifest))
+	if _, err := verifyManifestSignatures(manifest, []byte(edSignature), []TrustedKey{ed.trusted}, now); err != nil {
+		t.Fatalf("expected the Ed25519 signature to verify, got %v", err)
+	}
+	tampered := append([]byte{}, manifest...)
+	tampered[0] = '1'
+	if _, err := verifyManifestSignatures(tampered, []byte(signatures), []TrustedKey{old.trusted, next.trusted}, now); err == nil {
+		t.Fatal("expected a tampered manifest to be rejected")
+	}
+}
+
+func TestParseChecksumManifest(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	checksums, err := parseChecksumManifest([]byte(sum + "  jfrog-credential-provider-linux-amd64\n" + strings.ToUpper(sum) + " *jfrog-credential-provider-linux-arm64\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksums["jfrog-credential-provider-linux-amd64"] != sum || checksums["jfrog-credential-provider-linux-arm64"] != sum {
+		t.Fatalf("unexpected checksums %v", checksums)
+	}
+	for _, manifest := range []string{"abcd  short", sum, sum + "  a\n" + sum + "  a\n"} {
+		if _, err := parseChecksumManifest([]byte(manifest)); err == nil {
+			t.Fatalf("expected %q to be rejected", manifest)
+		}
+	}
+}
+
+func TestVerifyRelease(t *testing.T) {
+	now := time.Now()
+	signer := newTestSigner(t, "release", now.Add(-time.Hour), now.Add(time.Hour))
+	dir := t.TempDir()
+	keysPath := filepath.Join(dir, "trusted-keys.json")
+	keys, _ := json.Marshal([]TrustedKey{signer.trusted})
+	os.WriteFile(keysPath, keys, 0644)
+	t.Setenv("JFROG_CREDENTIAL_PROVIDER_TRUSTED_KEYS", keysPath)
+
+	binary := []byte("new binary")
+	sum := sha256.Sum256(binary)
+	manifest := []byte(hex.EncodeToString(sum[:]) + "  jfrog-credential-provider-linux-amd64\n")
+	artifacts := releaseArtifacts{
+		binaryName:    "jfrog-credential-provider-linux-amd64",
+		binaryPath:    filepath.Join(dir, "binary"),
+		manifestPath:  filepath.Join(dir, "SHA256SUMS"),
+		signaturePath: filepath.Join(dir, "SHA256SUMS.sig"),
+	}
+	os.WriteFile(artifacts.binaryPath, binary, 0644)
+	os.WriteFile(artifacts.manifestPath, manifest, 0644)
+	os.WriteFile(artifacts.signaturePath, []byte(base64.StdEncoding.EncodeToString(signer.sign(t, manifest))+"\n"), 0644)
+
+	if err := verifyRelease(testLogger(), artifacts, nil, now); err != nil {
+		t.Fatalf("expected the release to verify, got %v", err)
+	}
+
+	os.WriteFile(artifacts.binaryPath, []byte("tampered binary"), 0644)
+	if err := verifyRelease(testLogger(), artifacts, nil, now); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	artifacts.binaryName = "jfrog-credential-provider-linux-arm64"
+	if err := verifyRelease(testLogger(), artifacts, nil, now); err == nil {
+		t.Fatal("expected a binary missing from the manifest to be rejected")
+	}
+}
+
+func TestReleaseKeysParse(t *testing.T) {
+	keys, err := loadTrustedKeys(testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(releaseKeys) || keys[0].key == nil {
+		t.Fatalf("expected the release keys to parse, got %d keys", len(keys))
+	}
+}
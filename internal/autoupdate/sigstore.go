@@ -0,0 +1,334 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupdate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"jfrog-credential-provider/internal/logger"
+	"os"
+	"slices"
+	"time"
+)
+
+var (
+	// oidFulcioIssuerV1 and oidFulcioIssuerV2 hold the OIDC issuer of the
+	// identity a Fulcio certificate was issued to
+	oidFulcioIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// sigstorePolicy is what a Sigstore bundle is verified against: an offline
+// trust root, and for keyless bundles the signer identity.
+type sigstorePolicy struct {
+	root trustedRoot
+	// identity is the certificate subject alternative name of keyless signers
+	identity string
+	// issuer is the OIDC issuer that identity was authenticated by
+	issuer string
+}
+
+// trustedRoot is the part of a Sigstore trusted_root.json verification needs.
+type trustedRoot struct {
+	Tlogs                  []transparencyLog `json:"tlogs"`
+	CertificateAuthorities []struct {
+		CertChain struct {
+			Certificates []rawBytes `json:"certificates"`
+		} `json:"certChain"`
+		ValidFor validity `json:"validFor"`
+	} `json:"certificateAuthorities"`
+}
+
+type transparencyLog struct {
+	PublicKey struct {
+		RawBytes []byte   `json:"rawBytes"`
+		ValidFor validity `json:"validFor"`
+	} `json:"publicKey"`
+}
+
+// key returns the log key, valid for its validity period.
+func (l transparencyLog) key() (TrustedKey, error) {
+	return TrustedKey{ID: "transparency log", NotBefore: l.PublicKey.ValidFor.Start, NotAfter: l.PublicKey.ValidFor.End}.withDER(l.PublicKey.RawBytes)
+}
+
+type validity struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end,omitzero"`
+}
+
+type rawBytes struct {
+	RawBytes []byte `json:"rawBytes"`
+}
+
+// sigstoreBundle is a Sigstore bundle of a message signature, as made by
+// cosign sign-blob --bundle.
+type sigstoreBundle struct {
+	VerificationMaterial struct {
+		PublicKey            *struct{} `json:"publicKey"`
+		Certificate          *rawBytes `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []rawBytes `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		TlogEntries []tlogEntry `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	MessageSignature *struct {
+		MessageDigest struct {
+			Algorithm string `json:"algorithm"`
+			Digest    []byte `json:"digest"`
+		} `json:"messageDigest"`
+		Signature []byte `json:"signature"`
+	} `json:"messageSignature"`
+}
+
+type tlogEntry struct {
+	LogIndex int64 `json:"logIndex,string"`
+	LogID    struct {
+		KeyID []byte `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime   int64 `json:"integratedTime,string"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody []byte `json:"canonicalizedBody"`
+}
+
+// hashedRekord is the Rekor entry of a signed digest.
+type hashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   []byte `json:"content"`
+			PublicKey struct {
+				Content []byte `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// loadSigstorePolicy returns the policy of JFROG_CREDENTIAL_PROVIDER_SIGSTORE_*,
+// nil when no trusted root is set and bundles are not verified.
+func loadSigstorePolicy(logs *logger.Logger) (*sigstorePolicy, error) {
+	path := os.Getenv("JFROG_CREDENTIAL_PROVIDER_SIGSTORE_TRUSTED_ROOT")
+	if path == "" {
+		return nil, nil
+	}
+	logs.Info("Verifying Sigstore bundles with trusted root: " + path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Sigstore trusted root: %w", err)
+	}
+	policy := &sigstorePolicy{
+		identity: os.Getenv("JFROG_CREDENTIAL_PROVIDER_SIGSTORE_IDENTITY"),
+		issuer:   os.Getenv("JFROG_CREDENTIAL_PROVIDER_SIGSTORE_ISSUER"),
+	}
+	if err := json.Unmarshal(data, &policy.root); err != nil {
+		return nil, fmt.Errorf("failed to parse Sigstore trusted root %s: %w", path, err)
+	}
+	if len(policy.root.Tlogs) == 0 {
+		return nil, fmt.Errorf("Sigstore trusted root %s has no transparency log", path)
+	}
+	return policy, nil
+}
+
+// verifySigstoreBundle checks that bundle signs manifest and was logged in a
+// transparency log of the trusted root, whose signed entry timestamp proves
+// the signing time offline. The signer is a trusted key valid at that time,
+// or a certificate of a trusted root CA issued to the policy identity.
+func verifySigstoreBundle(bundle, manifest []byte, keys []TrustedKey, policy *sigstorePolicy) error {
+	var b sigstoreBundle
+	if err := json.Unmarshal(bundle, &b); err != nil {
+		return fmt.Errorf("invalid bundle: %w", err)
+	}
+	if b.MessageSignature == nil {
+		return errors.New("the bundle has no message signature")
+	}
+	digest := sha256.Sum256(manifest)
+	if b.MessageSignature.MessageDigest.Algorithm != "SHA2_256" || !bytes.Equal(b.MessageSignature.MessageDigest.Digest, digest[:]) {
+		return errors.New("the bundle does not sign the checksum manifest")
+	}
+	signature := b.MessageSignature.Signature
+
+	entry, err := verifyTlogEntry(b.VerificationMaterial.TlogEntries, policy.root, digest[:], signature)
+	if err != nil {
+		return err
+	}
+	signedAt := time.Unix(entry.IntegratedTime, 0)
+	var logged hashedRekord
+	if err := json.Unmarshal(entry.CanonicalizedBody, &logged); err != nil {
+		return fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	loggedKey, _ := pem.Decode(logged.Spec.Signature.PublicKey.Content)
+	if loggedKey == nil {
+		return errors.New("the transparency log entry has no signer")
+	}
+
+	material := b.VerificationMaterial
+	switch {
+	case material.Certificate != nil || material.X509CertificateChain != nil:
+		certs := []rawBytes{}
+		if material.Certificate != nil {
+			certs = append(certs, *material.Certificate)
+		} else {
+			certs = material.X509CertificateChain.Certificates
+		}
+		if len(certs) == 0 {
+			return errors.New("the bundle has an empty certificate chain")
+		}
+		cert, err := verifySigningCertificate(certs[0].RawBytes, policy, signedAt)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(loggedKey.Bytes, cert.Raw) {
+			return errors.New("the transparency log entry was signed by another certificate")
+		}
+		key, err := TrustedKey{ID: cert.Subject.String()}.withDER(cert.RawSubjectPublicKeyInfo)
+		if err != nil {
+			return err
+		}
+		if !key.verify(manifest, signature) {
+			return errors.New("the bundle signature does not verify with its certificate")
+		}
+		return nil
+	case material.PublicKey != nil:
+		for _, key := range keys {
+			if key.validAt(signedAt) && bytes.Equal(loggedKey.Bytes, key.der) && key.verify(manifest, signature) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w at %s", errNoTrustedSignature, signedAt.UTC().Format(time.RFC3339))
+	}
+	return errors.New("the bundle has no verification material")
+}
+
+// verifyTlogEntry returns the first entry whose signed entry timestamp
+// verifies with a transparency log key of root, and that logs the signature
+// of digest.
+func verifyTlogEntry(entries []tlogEntry, root trustedRoot, digest, signature []byte) (tlogEntry, error) {
+	for _, entry := range entries {
+		if entry.InclusionPromise == nil {
+			continue
+		}
+		integratedTime := time.Unix(entry.IntegratedTime, 0)
+		logID := hex.EncodeToString(entry.LogID.KeyID)
+		// the signed entry timestamp signs the canonical JSON of the entry
+		payload, err := json.Marshal(struct {
+			Body           []byte `json:"body"`
+			IntegratedTime int64  `json:"integratedTime"`
+			LogID          string `json:"logID"`
+			LogIndex       int64  `json:"logIndex"`
+		}{entry.CanonicalizedBody, entry.IntegratedTime, logID, entry.LogIndex})
+		if err != nil {
+			return tlogEntry{}, err
+		}
+		verified := slices.ContainsFunc(root.Tlogs, func(tlog transparencyLog) bool {
+			key, err := tlog.key()
+			return err == nil && key.fingerprint() == logID && key.validAt(integratedTime) && key.verify(payload, entry.InclusionPromise.SignedEntryTimestamp)
+		})
+		if !verified {
+			continue
+		}
+		var logged hashedRekord
+		if err := json.Unmarshal(entry.CanonicalizedBody, &logged); err != nil || logged.Kind != "hashedrekord" {
+			return tlogEntry{}, errors.New("the transparency log entry is not a hashedrekord")
+		}
+		if logged.Spec.Data.Hash.Algorithm != "sha256" || logged.Spec.Data.Hash.Value != hex.EncodeToString(digest) ||
+			!bytes.Equal(logged.Spec.Signature.Content, signature) {
+			return tlogEntry{}, errors.New("the transparency log entry is for another signature")
+		}
+		return entry, nil
+	}
+	return tlogEntry{}, errors.New("no transparency log entry verifies with the trusted root")
+}
+
+// verifySigningCertificate checks that the certificate chains to a CA of the
+// policy root valid at signedAt, and was issued to the policy identity.
+func verifySigningCertificate(der []byte, policy *sigstorePolicy, signedAt time.Time) (*x509.Certificate, error) {
+	if policy.identity == "" || policy.issuer == "" {
+		return nil, errors.New("keyless bundles need JFROG_CREDENTIAL_PROVIDER_SIGSTORE_IDENTITY and JFROG_CREDENTIAL_PROVIDER_SIGSTORE_ISSUER")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %w", err)
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, ca := range policy.root.CertificateAuthorities {
+		if signedAt.Before(ca.ValidFor.Start) || (!ca.ValidFor.End.IsZero() && !signedAt.Before(ca.ValidFor.End)) {
+			continue
+		}
+		chain := ca.CertChain.Certificates
+		for i, raw := range chain {
+			caCert, err := x509.ParseCertificate(raw.RawBytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted root certificate: %w", err)
+			}
+			// chains are ordered from the issuing CA to the root
+			if i == len(chain)-1 {
+				roots.AddCert(caCert)
+			} else {
+				intermediates.AddCert(caCert)
+			}
+		}
+	}
+	// short-lived certificates are checked at the logged signing time
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, fmt.Errorf("untrusted signing certificate: %w", err)
+	}
+
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	if !slices.Contains(identities, policy.identity) {
+		return nil, fmt.Errorf("the signing certificate was issued to %v, not %s", identities, policy.identity)
+	}
+	if issuer := certificateIssuer(cert); issuer != policy.issuer {
+		return nil, fmt.Errorf("the signing certificate identity was issued by %q, not %s", issuer, policy.issuer)
+	}
+	return cert, nil
+}
+
+// certificateIssuer returns the OIDC issuer of the Fulcio certificate cert.
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var issuer string
+			if _, err := asn1.UnmarshalWithParams(ext.Value, &issuer, "utf8"); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidFulcioIssuerV1):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupdate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIdentity = "https://github.com/jfrog/jfrog-credential-provider/.github/workflows/release.yml@refs/tags/v1.2.3"
+	testIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+// testSigstore is a transparency log and a certificate authority of a
+// trusted root.
+type testSigstore struct {
+	log    testSigner
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	policy *sigstorePolicy
+}
+
+func newTestSigstore(t *testing.T) testSigstore {
+	t.Helper()
+	now := time.Now()
+	log := newTestSigner(t, "rekor", time.Time{}, time.Time{})
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+
+	var root trustedRoot
+	rootJSON := `{"tlogs":[{"publicKey":{"rawBytes":"` + base64.StdEncoding.EncodeToString(log.trusted.der) + `","validFor":{"start":"` + now.Add(-time.Hour).Format(time.RFC3339) + `"}}}],` +
+		`"certificateAuthorities":[{"certChain":{"certificates":[{"rawBytes":"` + base64.StdEncoding.EncodeToString(der) + `"}]},"validFor":{"start":"` + now.Add(-time.Hour).Format(time.RFC3339) + `"}}]}`
+	if err := json.Unmarshal([]byte(rootJSON), &root); err != nil {
+		t.Fatal(err)
+	}
+	return testSigstore{log: log, ca: ca, caKey: caKey, policy: &sigstorePolicy{root: root, identity: testIdentity, issuer: testIssuer}}
+}
+
+// certificate issues a short-lived code signing certificate for key.
+func (s testSigstore) certificate(t *testing.T, key *ecdsa.PrivateKey, identity, issuer string) []byte {
+	t.Helper()
+	issuerValue, _ := asn1.MarshalWithParams(issuer, "utf8")
+	uri, _ := url.Parse(identity)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-time.Minute),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: oidFulcioIssuerV2, Value: issuerValue}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.ca, key.Public(), s.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// bundle logs signature of manifest by signerPEM and returns its bundle with
+// the verification material.
+func (s testSigstore) bundle(t *testing.T, manifest, signature []byte, signerPEM []byte, material string) []byte {
+	t.Helper()
+	digest := sha256.Sum256(manifest)
+	body, _ := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"data":      map[string]any{"hash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(digest[:])}},
+			"signature": map[string]any{"content": signature, "publicKey": map[string]any{"content": signerPEM}},
+		},
+	})
+	integratedTime := time.Now().Unix()
+	payload, _ := json.Marshal(struct {
+		Body           []byte `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{body, integratedTime, s.log.trusted.fingerprint(), 42})
+	logID, _ := hex.DecodeString(s.log.trusted.fingerprint())
+
+	bundle, _ := json.Marshal(map[string]any{
+		"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+		"verificationMaterial": json.RawMessage(`{` + material + `,"tlogEntries":[{` +
+			`"logIndex":"42","logId":{"keyId":"` + base64.StdEncoding.EncodeToString(logID) + `"},` +
+			`"kindVersion":{"kind":"hashedrekord","version":"0.0.1"},` +
+			`"integratedTime":"` + strconv.FormatInt(integratedTime, 10) + `",` +
+			`"inclusionPromise":{"signedEntryTimestamp":"` + base64.StdEncoding.EncodeToString(s.log.sign(t, payload)) + `"},` +
+			`"canonicalizedBody":"` + base64.StdEncoding.EncodeToString(body) + `"}]}`),
+		"messageSignature": map[string]any{
+			"messageDigest": map[string]any{"algorithm": "SHA2_256", "digest": digest[:]},
+			"signature":     signature,
+		},
+	})
+	return bundle
+}
+
+func TestVerifySigstoreBundleKeyed(t *testing.T) {
+	sigstore := newTestSigstore(t)
+	signer := newTestSigner(t, "release", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	manifest := []byte(strings.Repeat("0", 64) + "  jfrog-credential-provider-linux-amd64\n")
+	signature := signer.sign(t, manifest)
+	signerPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: signer.trusted.der})
+	bundle := sigstore.bundle(t, manifest, signature, signerPEM, `"publicKey":{"hint":"release"}`)
+
+	if err := verifySigstoreBundle(bundle, manifest, []TrustedKey{signer.trusted}, sigstore.policy); err != nil {
+		t.Fatalf("expected the bundle to verify, got %v", err)
+	}
+	if err := verifySigstoreBundle(bundle, []byte("another manifest"), []TrustedKey{signer.trusted}, sigstore.policy); err == nil {
+		t.Fatal("expected a bundle of another manifest to be rejected")
+	}
+
+	expired := signer.trusted
+	expired.NotAfter = time.Now().Add(-time.Minute)
+	if err := verifySigstoreBundle(bundle, manifest, []TrustedKey{expired}, sigstore.policy); err == nil {
+		t.Fatal("expected a key expired at the logged signing time to be rejected")
+	}
+
+	other := newTestSigstore(t)
+	if err := verifySigstoreBundle(bundle, manifest, []TrustedKey{signer.trusted}, other.policy); err == nil {
+		t.Fatal("expected an entry of an untrusted transparency log to be rejected")
+	}
+}
+
+func TestVerifySigstoreBundleKeyless(t *testing.T) {
+	sigstore := newTestSigstore(t)
+	manifest := []byte(strings.Repeat("0", 64) + "  jfrog-credential-provider-linux-amd64\n")
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ephemeral := testSignerFor(t, key, "ephemeral", time.Time{}, time.Time{})
+	signature := ephemeral.sign(t, manifest)
+
+	tests := map[string]struct {
+		identity, issuer string
+		valid            bool
+	}{
+		"expected identity": {testIdentity, testIssuer, true},
+		"another workflow":  {"https://github.com/someone/else/.github/workflows/release.yml@refs/heads/main", testIssuer, false},
+		"another issuer":    {testIdentity, "https://accounts.google.com", false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cert := sigstore.certificate(t, key, tt.identity, tt.issuer)
+			certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+			bundle := sigstore.bundle(t, manifest, signature, certPEM, `"certificate":{"rawBytes":"`+base64.StdEncoding.EncodeToString(cert)+`"}`)
+			err := verifySigstoreBundle(bundle, manifest, nil, sigstore.policy)
+			if tt.valid && err != nil {
+				t.Fatalf("expected the bundle to verify, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected the bundle to be rejected")
+			}
+		})
+	}
+}
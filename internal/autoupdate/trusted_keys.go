@@ -0,0 +1,159 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoupdate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"jfrog-credential-provider/internal/logger"
+	"os"
+	"time"
+)
+
+// releaseKeys are the keys release manifests are signed with. A new key is
+// added here well before it signs its first release, and the old one stays
+// until it expires, so updaters in the field keep verifying across rotations.
+var releaseKeys = []TrustedKey{
+	{
+		// the RSA key of the OpenPGP release signatures, until its expiry
+		ID: "jfrog-credential-provider-2025",
+		PublicKey: `-----BEGIN PUBLIC KEY-----
+MIICIjANBgkqhkiG9w0BAQEFAAOCAg8AMIICCgKCAgEAycXCBWWBdA7IBWithV6p
+mCW08JBrhufXlzH0JwEqwl/BqBerH4eLIKSwRL8BwxO1nS+YF/5CokHKfBOBj7eJ
+Ox7qtaHVo/4Hf2/ihhUcTZafhqdNxK8QX+GWGeTBiDdXjHhuXxKjRb3Lg40TqvJJ
+gU11TMaFDBOttimuE7V+OzKOK6MR3pupIDhwXxzSBBiwJo7IPAV7WnJxbwdOar/P
+NZt5M+s8UupeC2gW3i0aNp4rv5AH9fPDJrtFibqEQ7vJzTQ+HZ7yTipu26Iv9JKl
+2cd1IfcqRb40KhfdVHELXq+43ovCXpcsr4RxtZzzt9mNIExx93aqZYd+HQ6wWjQL
+EXKJ9b1rTBW6ALb6IZC58pr4yi9xBP9/ZMFC2ZJJVniEXddsU6JiuuKT3Y1gcGmn
+St3p+jIz931ZSkjfArvcheIDfDdZMvth8dvhjFK3ORJ03a5h305A7F2y3s8Jl8L8
+nFLuOBnnA4JWHHYhVLJmbCtqr9l7y1oY7J9mKKJu+ByqG+mlemWkDcwVv77NckQk
+K36hYMhxyom2y9tczC2G3dsWNY0mTKCVDCmHJbDCO2l4YOvqjfRuQlT5kODdy9Bs
+Ll2Ljmm8Ibqszi6Oo6JR7z8bHgCkz1wwo9Z6BJFyGjO6W4RveJQ8am1RE5jGg0Xp
+6qZgBmYY2KqYGWl4IclEKJkCAwEAAQ==
+-----END PUBLIC KEY-----
+`,
+		NotBefore: time.Date(2025, 6, 3, 9, 58, 51, 0, time.UTC),
+		NotAfter:  time.Date(2027, 6, 3, 9, 58, 51, 0, time.UTC),
+	},
+}
+
+// TrustedKey is a public key release manifests may be signed with while it
+// is valid.
+type TrustedKey struct {
+	ID string `json:"id"`
+	// PublicKey is a PEM encoded PKIX ECDSA P-256, Ed25519 or RSA key
+	PublicKey string    `json:"public_key"`
+	NotBefore time.Time `json:"not_before,omitzero"`
+	NotAfter  time.Time `json:"not_after,omitzero"`
+
+	key crypto.PublicKey
+	der []byte
+}
+
+// errNoTrustedSignature is returned when no signature verifies with a key
+// valid at verification time, or for Sigstore bundles at the time the
+// transparency log recorded the signature.
+var errNoTrustedSignature = errors.New("no signature verifies with a trusted key")
+
+// validAt reports whether the key is within its validity window at t.
+func (k TrustedKey) validAt(t time.Time) bool {
+	return (k.NotBefore.IsZero() || !t.Before(k.NotBefore)) && (k.NotAfter.IsZero() || t.Before(k.NotAfter))
+}
+
+// verify checks signature over data: ASN.1 ECDSA or PKCS #1 v1.5 RSA over
+// its SHA-256, or Ed25519 over data itself, as cosign sign-blob makes them.
+func (k TrustedKey) verify(data, signature []byte) bool {
+	digest := sha256.Sum256(data)
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, signature)
+	}
+	return false
+}
+
+// fingerprint is the hex SHA-256 of the DER encoded key, the log ID of a
+// transparency log key.
+func (k TrustedKey) fingerprint() string {
+	sum := sha256.Sum256(k.der)
+	return hex.EncodeToString(sum[:])
+}
+
+// parsePublicKey decodes the PEM public key of k.
+func (k TrustedKey) parsePublicKey() (TrustedKey, error) {
+	block, _ := pem.Decode([]byte(k.PublicKey))
+	if block == nil {
+		return k, fmt.Errorf("trusted key %s: no PEM public key", k.ID)
+	}
+	return k.withDER(block.Bytes)
+}
+
+// withDER sets the key of k from its DER encoded PKIX form.
+func (k TrustedKey) withDER(der []byte) (TrustedKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return k, fmt.Errorf("trusted key %s: %w", k.ID, err)
+	}
+	switch key := key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return k, fmt.Errorf("trusted key %s: RSA keys must be at least 2048 bits", k.ID)
+		}
+	default:
+		return k, fmt.Errorf("trusted key %s: unsupported key type %T", k.ID, key)
+	}
+	k.key, k.der = key, der
+	return k, nil
+}
+
+// loadTrustedKeys returns the release keys and the keys of the JSON file at
+// JFROG_CREDENTIAL_PROVIDER_TRUSTED_KEYS, for releases signed with a key
+// added after this binary was built.
+func loadTrustedKeys(logs *logger.Logger) ([]TrustedKey, error) {
+	keys := append([]TrustedKey{}, releaseKeys...)
+	if path := os.Getenv("JFROG_CREDENTIAL_PROVIDER_TRUSTED_KEYS"); path != "" {
+		logs.Info("Loading additional trusted keys from: " + path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+		}
+		var extra []TrustedKey
+		if err := json.Unmarshal(data, &extra); err != nil {
+			return nil, fmt.Errorf("failed to parse trusted keys %s: %w", path, err)
+		}
+		keys = append(keys, extra...)
+	}
+	for i, key := range keys {
+		parsed, err := key.parsePublicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = parsed
+	}
+	return keys, nil
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"jfrog-credential-provider/internal/handlers"
 	"jfrog-credential-provider/internal/logger"
 	"jfrog-credential-provider/internal/utils"
 	"net/http"
@@ -36,9 +37,11 @@ func createRequestJson(logs *logger.Logger, artifactoryUrl string, request utils
 		Image:      artifactoryUrl,
 	}
 
-	if request.ServiceAccountAnnotations["JFrogExchange"] == "true" &&
+	if (request.ServiceAccountAnnotations["JFrogExchange"] == "true" &&
 		(request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"] != "" ||
-			request.ServiceAccountAnnotations["azure.workload.identity/client-id"] != "") {
+			request.ServiceAccountAnnotations["azure.workload.identity/client-id"] != "")) ||
+		// cloud_provider kubernetes always authenticates with the service account token
+		(os.Getenv("cloud_provider") == utils.CloudProviderKubernetes && request.ServiceAccountToken != "") {
 		jsonReq.ServiceAccountToken = request.ServiceAccountToken
 		jsonReq.ServiceAccountAnnotations = request.ServiceAccountAnnotations
 	}
@@ -60,7 +63,9 @@ func fetchArtifactoryAuth(ctx context.Context, client *http.Client, logs *logger
 	cmd := exec.Command(newBinaryPath)
 	cmd.Stdin = strings.NewReader(string(kubeletPluginRequest))
 
-	cmd.Env = os.Environ()
+	// bypass the on-node token cache and any running credential daemon so the
+	// new binary performs a real exchange
+	cmd.Env = append(os.Environ(), "disable_token_cache=true", "credential_daemon_socket=")
 	logs.Info("Validating new binary with following environment variables: " + strings.Join(cmd.Env, " "))
 
 	var stdoutBuf, stderrBuf bytes.Buffer
@@ -70,6 +75,12 @@ func fetchArtifactoryAuth(ctx context.Context, client *http.Client, logs *logger
 	logs.Debug("Running: " + cmd.String())
 	err := cmd.Run()
 	if err != nil {
+		// a classified failure tells a broken binary (INTERNAL) apart from the
+		// node's environment (e.g. IMDS_UNREACHABLE, STS_DENIED)
+		if line, ok := handlers.ParseErrorLine(stderrBuf.String()); ok {
+			logs.Error("Error running the new binary [" + string(line.Code) + "]: " + line.Message)
+			return utils.CredentialProviderResponse{}, line.Err("validate new binary")
+		}
 		if stderrBuf.Len() > 0 {
 			logs.Error("Error running the new binary: " + stderrBuf.String())
 			return utils.CredentialProviderResponse{}, err
@@ -0,0 +1,203 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache provides an on-node, file-backed cache of Artifactory tokens
+// shared across kubelet plugin invocations.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultCacheDir     = "/var/lib/jfrog-credentials-provider"
+	DefaultSafetyMargin = 5 * time.Minute
+	cacheFileName       = "token-cache.json"
+	lockDirName         = "locks"
+	// refreshRetention is how long an expired entry is kept for its refresh token
+	refreshRetention = 7 * 24 * time.Hour
+)
+
+// Entry is a cached Artifactory token.
+type Entry struct {
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// RefreshToken renews Token with the refresh grant once it expires, set
+	// only when Artifactory issued a refreshable token
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// TokenCache is the cache file in a directory. The file is only locked
+// while it is read or written, so a slow exchange for one key never blocks
+// the other keys. Callers that want a burst of identical requests to result
+// in a single exchange hold Lock for the key across it.
+type TokenCache struct {
+	dir          string
+	path         string
+	logs         *logger.Logger
+	safetyMargin time.Duration
+}
+
+// Key derives a stable cache key from the parts that identify a token. The
+// parts are hashed so the cache file never contains role names or subjects
+// in clear text.
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Open creates (if needed) the cache file in dir with 0600 permissions and
+// returns a cache on it. No lock is held between calls.
+func Open(logs *logger.Logger, dir string, safetyMargin time.Duration) (*TokenCache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, lockDirName), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create token cache dir %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, cacheFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token cache %s: %w", path, err)
+	}
+	defer file.Close()
+	// enforce 0600 even if the file was created by an older version or a different umask
+	if err := file.Chmod(0600); err != nil {
+		return nil, fmt.Errorf("failed to set token cache permissions: %w", err)
+	}
+	return &TokenCache{dir: dir, path: path, logs: logs, safetyMargin: safetyMargin}, nil
+}
+
+// Lock takes an exclusive lock on key alone, in a lock file of its own. The
+// returned function releases it. Lock files are left in place, removing one
+// another invocation waits on would let a third one in.
+func (c *TokenCache) Lock(key string) (func(), error) {
+	file, err := os.OpenFile(filepath.Join(c.dir, lockDirName, key+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token cache lock: %w", err)
+	}
+	if err := utils.GetLock(c.logs, file, syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		utils.ReleaseLock(c.logs, file)
+		file.Close()
+	}, nil
+}
+
+// read returns the entries of the cache file, read under a shared lock.
+func (c *TokenCache) read() map[string]Entry {
+	file, err := os.Open(c.path)
+	if err != nil {
+		c.logs.Error("Could not read token cache: " + err.Error())
+		return map[string]Entry{}
+	}
+	defer file.Close()
+	if err := utils.GetLock(c.logs, file, syscall.LOCK_SH); err != nil {
+		return map[string]Entry{}
+	}
+	defer utils.ReleaseLock(c.logs, file)
+	return c.decode(file)
+}
+
+func (c *TokenCache) decode(file *os.File) map[string]Entry {
+	entries := map[string]Entry{}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.logs.Error("Could not read token cache: " + err.Error())
+		return entries
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			// a corrupt cache is not fatal, it is rewritten on the next Put
+			c.logs.Info("Token cache is unreadable, starting with an empty cache: " + err.Error())
+			return map[string]Entry{}
+		}
+	}
+	return entries
+}
+
+// Get returns the entry for key if it is still valid for at least the
+// safety margin.
+func (c *TokenCache) Get(key string) (Entry, bool) {
+	entry, ok := c.read()[key]
+	if !ok {
+		return Entry{}, false
+	}
+	if time.Until(entry.ExpiresAt) <= c.safetyMargin {
+		c.logs.Debug("Token cache entry expired or within safety margin")
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Refreshable returns the entry for key, whatever its expiry, if it holds a
+// refresh token.
+func (c *TokenCache) Refreshable(key string) (Entry, bool) {
+	entry, ok := c.read()[key]
+	if !ok || entry.RefreshToken == "" {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Put stores entry under key, drops expired entries and rewrites the file
+// under an exclusive lock, keeping what other invocations wrote since. Entries
+// with a refresh token are kept for refreshRetention past their expiry.
+func (c *TokenCache) Put(key string, entry Entry) error {
+	file, err := os.OpenFile(c.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open token cache %s: %w", c.path, err)
+	}
+	defer file.Close()
+	if err := utils.GetLock(c.logs, file, syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer utils.ReleaseLock(c.logs, file)
+
+	entries := c.decode(file)
+	now := time.Now()
+	for k, e := range entries {
+		expiry := e.ExpiresAt
+		if e.RefreshToken != "" {
+			expiry = expiry.Add(refreshRetention)
+		}
+		if !expiry.After(now) {
+			delete(entries, k)
+		}
+	}
+	entries[key] = entry
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token cache: %w", err)
+	}
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate token cache: %w", err)
+	}
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+	return file.Sync()
+}
@@ -0,0 +1,183 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io"
+	"jfrog-credential-provider/internal/logger"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testLogger() *logger.Logger {
+	return &logger.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func TestTokenCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	key := Key("example.jfrog.io", "aws/assume_role", "my-role", "")
+
+	c, err := Open(testLogger(), dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Fatal("expected empty cache")
+	}
+	if err := c.Put(key, Entry{Username: "user", Token: "token", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, cacheFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("expected cache file mode 0600, got %o", perm)
+	}
+
+	c, err = Open(testLogger(), dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := c.Get(key)
+	if !ok {
+		t.Fatal("expected cache hit after reopening")
+	}
+	if entry.Username != "user" || entry.Token != "token" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
+
+func TestTokenCacheHonoursSafetyMargin(t *testing.T) {
+	c, err := Open(testLogger(), t.TempDir(), 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key("example.jfrog.io")
+	if err := c.Put(key, Entry{Username: "user", Token: "token", ExpiresAt: time.Now().Add(5 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Fatal("expected entry inside the safety margin to be treated as expired")
+	}
+}
+
+func TestTokenCacheIgnoresCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, cacheFileName), []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Open(testLogger(), dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(Key("anything")); ok {
+		t.Fatal("expected empty cache")
+	}
+}
+
+func TestKeyDiffersPerPart(t *testing.T) {
+	if Key("a", "bc") == Key("ab", "c") {
+		t.Fatal("expected keys to be separated by part")
+	}
+}
+
+func TestTokenCacheKeepsExpiredRefreshableEntries(t *testing.T) {
+	c, err := Open(testLogger(), t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	refreshable, plain := Key("refreshable"), Key("plain")
+	if err := c.Put(refreshable, Entry{Username: "user", Token: "token", RefreshToken: "refresh", ExpiresAt: expired}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(plain, Entry{Username: "user", Token: "token", ExpiresAt: expired}); err != nil {
+		t.Fatal(err)
+	}
+	// the next Put prunes the expired entry without a refresh token
+	if err := c.Put(Key("other"), Entry{Username: "user", Token: "token", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Get(refreshable); ok {
+		t.Fatal("expected expired entry not to be served")
+	}
+	entry, ok := c.Refreshable(refreshable)
+	if !ok || entry.RefreshToken != "refresh" {
+		t.Fatalf("expected refreshable entry to be kept, got %+v", entry)
+	}
+	if _, ok := c.read()[plain]; ok {
+		t.Fatal("expected expired entry without refresh token to be pruned")
+	}
+}
+
+func TestTokenCacheLocksPerKey(t *testing.T) {
+	c, err := Open(testLogger(), t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := c.Lock(Key("slow exchange"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		other, err := c.Lock(Key("another key"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		other()
+		if err := c.Put(Key("another key"), Entry{Username: "user", Token: "token", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected another key to be locked and written while the first one is held")
+	}
+	if _, ok := c.Get(Key("another key")); !ok {
+		t.Fatal("expected the entry of another key to be cached")
+	}
+}
+
+func TestTokenCachePutKeepsConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := Open(testLogger(), dir, time.Minute)
+	b, _ := Open(testLogger(), dir, time.Minute)
+	expiresAt := time.Now().Add(time.Hour)
+	if err := a.Put(Key("a"), Entry{Token: "a", ExpiresAt: expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(Key("b"), Entry{Token: "b", ExpiresAt: expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Get(Key("b")); !ok {
+		t.Fatal("expected the entry written by another invocation to be read")
+	}
+	if _, ok := b.Get(Key("a")); !ok {
+		t.Fatal("expected a Put not to drop the entries of another invocation")
+	}
+}
@@ -0,0 +1,462 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakemetadata emulates the cloud metadata services the provider
+// calls (AWS IMDSv2, the EKS Pod Identity agent, AWS STS AssumeRole,
+// AssumeRoleWithWebIdentity and GetCallerIdentity, Azure IMDS, GCE metadata,
+// Google STS and the IAM Credentials generateIdToken API), so credential
+// flows can be tested offline. Point the provider at it with the aws_imds_endpoint,
+// aws_container_credentials_full_uri, AWS_ENDPOINT_URL_STS,
+// azure_imds_endpoint, google_metadata_endpoint, google_sts_endpoint and
+// google_iamcredentials_endpoint env vars.
+package fakemetadata
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AWSInstance is what the emulated IMDSv2 reports. The Pod Identity agent
+// and STS hand out the same credentials, they answer even when IMDS is
+// disabled, as for pods that cannot reach it.
+type AWSInstance struct {
+	Enabled         bool
+	Region          string
+	RoleName        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+	// PodIdentityToken is the token the Pod Identity agent expects
+	PodIdentityToken string
+	// ExternalIDs maps role ARNs to the ExternalId AssumeRole requires for them
+	ExternalIDs map[string]string
+}
+
+// AzureInstance is what the emulated Azure IMDS reports.
+type AzureInstance struct {
+	Enabled bool
+	// AccessToken is returned for any resource and client id
+	AccessToken string
+	ExpiresIn   int
+}
+
+// GoogleInstance is what the emulated GCE metadata server, STS and IAM
+// Credentials API report.
+type GoogleInstance struct {
+	Enabled        bool
+	InstanceID     string
+	ServiceAccount string
+	AccessToken    string
+	// FederatedToken is returned by STS for any subject token, and accepted
+	// by generateIdToken like AccessToken
+	FederatedToken string
+	// IDToken is returned by generateIdToken for ServiceAccount
+	IDToken string
+}
+
+// Server serves all three metadata services from one listener, their paths
+// do not overlap. A disabled service answers 404, as on another cloud.
+type Server struct {
+	*httptest.Server
+	AWS    AWSInstance
+	Azure  AzureInstance
+	Google GoogleInstance
+
+	mu         sync.Mutex
+	imdsTokens map[string]bool
+	requests   []string
+	// stsSessions maps the session tokens AssumeRole issued to the assumed role
+	stsSessions     map[string]string
+	assumeRoleCalls []url.Values
+}
+
+// NewServer starts a server with all services enabled and fake identities.
+func NewServer() *Server {
+	s := &Server{
+		AWS: AWSInstance{
+			Enabled:          true,
+			Region:           "us-east-1",
+			RoleName:         "jfrog-node-role",
+			AccessKeyID:      "ASIAFAKEACCESSKEY000",
+			SecretAccessKey:  "fake-secret-access-key",
+			SessionToken:     "fake-session-token",
+			Expiration:       time.Now().Add(6 * time.Hour),
+			PodIdentityToken: "fake-pod-identity-token",
+		},
+		Azure: AzureInstance{
+			Enabled:     true,
+			AccessToken: "fake-azure-access-token",
+			ExpiresIn:   3600,
+		},
+		Google: GoogleInstance{
+			Enabled:        true,
+			InstanceID:     "1234567890123456789",
+			ServiceAccount: "jfrog@project.iam.gserviceaccount.com",
+			AccessToken:    "fake-google-access-token",
+			FederatedToken: "fake-google-federated-token",
+			IDToken:        "fake-google-id-token",
+		},
+		imdsTokens:  map[string]bool{},
+		stsSessions: map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /latest/api/token", s.awsToken)
+	mux.HandleFunc("GET /latest/meta-data/", s.awsMetadata)
+	mux.HandleFunc("GET /v1/credentials", s.awsPodIdentityCredentials)
+	mux.HandleFunc("POST /{$}", s.awsSTS)
+	mux.HandleFunc("GET /metadata/instance", s.azureInstance)
+	mux.HandleFunc("GET /metadata/identity/oauth2/token", s.azureIdentityToken)
+	mux.HandleFunc("GET /computeMetadata/v1/instance/id", s.googleInstanceID)
+	mux.HandleFunc("GET /computeMetadata/v1/instance/service-accounts/{account}/token", s.googleAccessToken)
+	mux.HandleFunc("GET /computeMetadata/v1/instance/service-accounts/{account}/identity", s.googleIdentity)
+	mux.HandleFunc("POST /v1/projects/-/serviceAccounts/{call}", s.googleGenerateIDToken)
+	mux.HandleFunc("POST /v1/token", s.googleSTSToken)
+	s.Server = httptest.NewServer(s.record(mux))
+	return s
+}
+
+// Requests returns the method and path of every request served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// AssumeRoleCalls returns the form of every AssumeRole call served so far.
+func (s *Server) AssumeRoleCalls() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]url.Values(nil), s.assumeRoleCalls...)
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// awsToken issues an IMDSv2 session token, which every metadata read must carry.
+func (s *Server) awsToken(w http.ResponseWriter, r *http.Request) {
+	if !s.AWS.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	ttl := r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds")
+	if ttl == "" {
+		http.Error(w, "missing X-aws-ec2-metadata-token-ttl-seconds", http.StatusBadRequest)
+		return
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	s.mu.Lock()
+	s.imdsTokens[token] = true
+	s.mu.Unlock()
+	// the SDK IMDS client falls back to IMDSv1 without the echoed TTL
+	w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", ttl)
+	w.Write([]byte(token))
+}
+
+func (s *Server) awsMetadata(w http.ResponseWriter, r *http.Request) {
+	if !s.AWS.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	valid := s.imdsTokens[r.Header.Get("X-aws-ec2-metadata-token")]
+	s.mu.Unlock()
+	if !valid {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	const credentialsPath = "/latest/meta-data/iam/security-credentials/"
+	switch path := r.URL.Path; {
+	case path == "/latest/meta-data/":
+		w.Write([]byte("ami-id\niam/\nplacement/\n"))
+	case path == "/latest/meta-data/placement/region":
+		w.Write([]byte(s.AWS.Region))
+	case path == credentialsPath:
+		w.Write([]byte(s.AWS.RoleName))
+	case path == credentialsPath+s.AWS.RoleName:
+		writeJSON(w, map[string]string{
+			"Code":            "Success",
+			"LastUpdated":     time.Now().UTC().Format(time.RFC3339),
+			"Type":            "AWS-HMAC",
+			"AccessKeyId":     s.AWS.AccessKeyID,
+			"SecretAccessKey": s.AWS.SecretAccessKey,
+			"Token":           s.AWS.SessionToken,
+			"Expiration":      s.AWS.Expiration.UTC().Format(time.RFC3339),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// awsPodIdentityCredentials emulates the EKS Pod Identity agent.
+func (s *Server) awsPodIdentityCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != s.AWS.PodIdentityToken {
+		http.Error(w, `{"Message":"Service account token cannot be empty or invalid"}`, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]string{
+		"AccessKeyId":     s.AWS.AccessKeyID,
+		"SecretAccessKey": s.AWS.SecretAccessKey,
+		"Token":           s.AWS.SessionToken,
+		"AccountId":       "123456789012",
+		"Expiration":      s.AWS.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+// awsSTS emulates the AssumeRole, AssumeRoleWithWebIdentity and
+// GetCallerIdentity actions of AWS STS. AssumeRole issues a new session
+// token each time so GetCallerIdentity can tell the hops of a chain apart.
+func (s *Server) awsSTS(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	form := r.PostForm
+	switch form.Get("Action") {
+	case "AssumeRoleWithWebIdentity":
+		if form.Get("WebIdentityToken") == "" || form.Get("RoleArn") == "" {
+			break
+		}
+		writeSTSCredentials(w, "AssumeRoleWithWebIdentity", s.AWS.AccessKeyID, s.AWS.SecretAccessKey, s.AWS.SessionToken, s.AWS.Expiration,
+			form.Get("RoleArn")+"/"+form.Get("RoleSessionName"))
+		return
+	case "AssumeRole":
+		roleARN := form.Get("RoleArn")
+		if roleARN == "" || form.Get("RoleSessionName") == "" {
+			break
+		}
+		if externalID := s.AWS.ExternalIDs[roleARN]; externalID != "" && form.Get("ExternalId") != externalID {
+			http.Error(w, "<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code></Error></ErrorResponse>", http.StatusForbidden)
+			return
+		}
+		b := make([]byte, 8)
+		rand.Read(b)
+		sessionToken := s.AWS.SessionToken + "-" + hex.EncodeToString(b)
+		arn := assumedRoleARN(roleARN, form.Get("RoleSessionName"))
+		s.mu.Lock()
+		s.stsSessions[sessionToken] = arn
+		s.assumeRoleCalls = append(s.assumeRoleCalls, form)
+		s.mu.Unlock()
+		writeSTSCredentials(w, "AssumeRole", s.AWS.AccessKeyID, s.AWS.SecretAccessKey, sessionToken, s.AWS.Expiration, arn)
+		return
+	case "GetCallerIdentity":
+		s.mu.Lock()
+		arn, ok := s.stsSessions[r.Header.Get("X-Amz-Security-Token")]
+		s.mu.Unlock()
+		if !ok {
+			arn = "arn:aws:sts::123456789012:assumed-role/" + s.AWS.RoleName + "/i-0123456789abcdef0"
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>%s</Arn>
+    <UserId>AROAFAKEROLEID:session</UserId>
+    <Account>%s</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata><RequestId>00000000-0000-0000-0000-000000000000</RequestId></ResponseMetadata>
+</GetCallerIdentityResponse>`, arn, strings.Split(arn, ":")[4])
+		return
+	}
+	http.Error(w, "<ErrorResponse><Error><Type>Sender</Type><Code>InvalidAction</Code></Error></ErrorResponse>", http.StatusBadRequest)
+}
+
+// assumedRoleARN returns the STS ARN of a session of roleARN,
+// arn:aws:iam::<account>:role/<path>/<name> becoming
+// arn:aws:sts::<account>:assumed-role/<name>/<session>.
+func assumedRoleARN(roleARN, sessionName string) string {
+	parts := strings.SplitN(roleARN, ":", 6)
+	if len(parts) != 6 {
+		return roleARN + "/" + sessionName
+	}
+	name := parts[5][strings.LastIndex(parts[5], "/")+1:]
+	return fmt.Sprintf("arn:%s:sts::%s:assumed-role/%s/%s", parts[1], parts[4], name, sessionName)
+}
+
+func writeSTSCredentials(w http.ResponseWriter, action, accessKeyID, secretAccessKey, sessionToken string, expiration time.Time, arn string) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>%[2]s</AccessKeyId>
+      <SecretAccessKey>%[3]s</SecretAccessKey>
+      <SessionToken>%[4]s</SessionToken>
+      <Expiration>%[5]s</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>%[6]s</Arn>
+      <AssumedRoleId>AROAFAKEROLEID:session</AssumedRoleId>
+    </AssumedRoleUser>
+  </%[1]sResult>
+  <ResponseMetadata><RequestId>00000000-0000-0000-0000-000000000000</RequestId></ResponseMetadata>
+</%[1]sResponse>`, action, accessKeyID, secretAccessKey, sessionToken, expiration.UTC().Format(time.RFC3339), arn)
+}
+
+func (s *Server) azureAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if !s.Azure.Enabled {
+		http.NotFound(w, r)
+		return false
+	}
+	if r.Header.Get("Metadata") != "true" {
+		http.Error(w, `{"error":"invalid_request","error_description":"Required metadata header not specified"}`, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (s *Server) azureInstance(w http.ResponseWriter, r *http.Request) {
+	if !s.azureAllowed(w, r) {
+		return
+	}
+	writeJSON(w, map[string]any{"compute": map[string]string{"azEnvironment": "AzurePublicCloud", "location": "eastus"}})
+}
+
+func (s *Server) azureIdentityToken(w http.ResponseWriter, r *http.Request) {
+	if !s.azureAllowed(w, r) {
+		return
+	}
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		http.Error(w, `{"error":"invalid_request","error_description":"Required query variable 'resource' is missing"}`, http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	writeJSON(w, map[string]string{
+		"access_token":   s.Azure.AccessToken,
+		"client_id":      r.URL.Query().Get("client_id"),
+		"expires_in":     strconv.Itoa(s.Azure.ExpiresIn),
+		"ext_expires_in": strconv.Itoa(s.Azure.ExpiresIn),
+		"expires_on":     strconv.Itoa(int(now.Unix()) + s.Azure.ExpiresIn),
+		"not_before":     strconv.Itoa(int(now.Unix())),
+		"resource":       resource,
+		"token_type":     "Bearer",
+	})
+}
+
+func (s *Server) googleAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if !s.Google.Enabled {
+		http.NotFound(w, r)
+		return false
+	}
+	if r.Header.Get("Metadata-Flavor") != "Google" {
+		http.Error(w, "Missing Metadata-Flavor:Google header", http.StatusForbidden)
+		return false
+	}
+	w.Header().Set("Metadata-Flavor", "Google")
+	return true
+}
+
+func (s *Server) googleInstanceID(w http.ResponseWriter, r *http.Request) {
+	if !s.googleAllowed(w, r) {
+		return
+	}
+	w.Write([]byte(s.Google.InstanceID))
+}
+
+func (s *Server) googleAccessToken(w http.ResponseWriter, r *http.Request) {
+	if !s.googleAllowed(w, r) {
+		return
+	}
+	if account := r.PathValue("account"); account != "default" && account != s.Google.ServiceAccount {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, map[string]any{"access_token": s.Google.AccessToken, "expires_in": 3599, "token_type": "Bearer"})
+}
+
+// googleIdentity answers the metadata identity endpoint with the bare IDToken.
+func (s *Server) googleIdentity(w http.ResponseWriter, r *http.Request) {
+	if !s.googleAllowed(w, r) {
+		return
+	}
+	if account := r.PathValue("account"); account != "default" && account != s.Google.ServiceAccount {
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Query().Get("audience") == "" {
+		http.Error(w, "non-empty audience parameter required", http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte(s.Google.IDToken))
+}
+
+// googleGenerateIDToken emulates
+// POST /v1/projects/-/serviceAccounts/{email}:generateIdToken.
+func (s *Server) googleGenerateIDToken(w http.ResponseWriter, r *http.Request) {
+	account, ok := strings.CutSuffix(r.PathValue("call"), ":generateIdToken")
+	if !s.Google.Enabled || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if auth := r.Header.Get("Authorization"); auth != "Bearer "+s.Google.AccessToken && auth != "Bearer "+s.Google.FederatedToken {
+		http.Error(w, `{"error":{"code":401,"status":"UNAUTHENTICATED"}}`, http.StatusUnauthorized)
+		return
+	}
+	if account != s.Google.ServiceAccount {
+		http.Error(w, `{"error":{"code":403,"status":"PERMISSION_DENIED"}}`, http.StatusForbidden)
+		return
+	}
+	var body struct {
+		Audience string `json:"audience"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Audience == "" {
+		http.Error(w, `{"error":{"code":400,"status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]string{"token": s.Google.IDToken})
+}
+
+// googleSTSToken emulates the token exchange of sts.googleapis.com/v1/token.
+func (s *Server) googleSTSToken(w http.ResponseWriter, r *http.Request) {
+	if !s.Google.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	var body struct {
+		GrantType    string `json:"grantType"`
+		Audience     string `json:"audience"`
+		SubjectToken string `json:"subjectToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.GrantType != "urn:ietf:params:oauth:grant-type:token-exchange" ||
+		body.Audience == "" || body.SubjectToken == "" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"access_token":      s.Google.FederatedToken,
+		"issued_token_type": "urn:ietf:params:oauth:token-type:access_token",
+		"token_type":        "Bearer",
+		"expires_in":        3600,
+	})
+}
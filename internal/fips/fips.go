@@ -0,0 +1,77 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fips reports whether the provider runs in FIPS 140-3 mode, with
+// the Go Cryptographic Module either built in with GOFIPS140 or enabled at
+// run time with GODEBUG=fips140=on.
+package fips
+
+import (
+	"crypto/fips140"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrNotApproved is wrapped by the errors of Check.
+var ErrNotApproved = errors.New("not approved in FIPS 140-3 mode")
+
+// Enabled reports whether FIPS 140-3 mode is on.
+func Enabled() bool {
+	return fips140.Enabled()
+}
+
+// Check returns an error wrapping ErrNotApproved when FIPS 140-3 mode is on,
+// for what uses an algorithm or implementation outside of the module.
+func Check(what string) error {
+	if Enabled() {
+		return fmt.Errorf("%s is %w", what, ErrNotApproved)
+	}
+	return nil
+}
+
+// Module returns the version of the Go Cryptographic Module the binary was
+// built with by GOFIPS140, empty when it was not.
+func Module() string {
+	if module := buildSetting("GOFIPS140"); module != "off" {
+		return module
+	}
+	return ""
+}
+
+// Mode describes the FIPS 140-3 mode for the version output, with the module
+// version the binary was built with when set.
+func Mode() string {
+	module := Module()
+	switch {
+	case Enabled() && module != "":
+		return "FIPS 140-3 mode: on (Go Cryptographic Module " + module + ")"
+	case Enabled():
+		return "FIPS 140-3 mode: on"
+	}
+	return "FIPS 140-3 mode: off"
+}
+
+func buildSetting(key string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == key {
+			return setting.Value
+		}
+	}
+	return ""
+}
@@ -12,20 +12,25 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package controllers
+//go:debug fips140=on
 
-import "fmt"
+package fips
 
-// SigningError indicates an error condition occurred while performing SigV4a signing
-type SigningError struct {
-	Err error
-}
-
-func (e *SigningError) Error() string {
-	return fmt.Sprintf("failed to sign request: %v", e.Err)
-}
+import (
+	"errors"
+	"strings"
+	"testing"
+)
 
-// Unwrap returns the underlying error cause
-func (e *SigningError) Unwrap() error {
-	return e.Err
+func TestCheckInFIPSMode(t *testing.T) {
+	if !Enabled() {
+		t.Fatal("expected the go:debug directive to turn FIPS 140-3 mode on")
+	}
+	err := Check("signing with sigv4a")
+	if !errors.Is(err, ErrNotApproved) {
+		t.Fatalf("expected an ErrNotApproved error, got %v", err)
+	}
+	if !strings.HasPrefix(Mode(), "FIPS 140-3 mode: on") {
+		t.Fatalf("unexpected mode %q", Mode())
+	}
 }
@@ -20,16 +20,21 @@ import (
 	"fmt"
 	"io"
 	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/fips"
 	signer "jfrog-credential-provider/internal/sign"
 	"jfrog-credential-provider/internal/utils"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
@@ -38,12 +43,17 @@ import (
 )
 
 const (
-	TOKEN_URL          = "http://169.254.169.254/latest/api/token"
-	TEMP_SESSION_URL   = "http://169.254.169.254/latest/meta-data/iam/security-credentials/"
-	REGION_URL         = "http://169.254.169.254/latest/meta-data/placement/region"
-	GRANT_TYPE         = "client_credentials"
-	AWS_OIDC_TOKEN_URL = "https://$user_pool_resource_domain.auth.$region.amazoncognito.com/oauth2/token"
-	METADATA_URL       = "http://169.254.169.254/latest/meta-data/"
+	AWS_IMDS_ENDPOINT      = "http://169.254.169.254"
+	AWS_IMDS_IPV6_ENDPOINT = "http://[fd00:ec2::254]"
+	TOKEN_PATH             = "/latest/api/token"
+	TEMP_SESSION_PATH      = "/latest/meta-data/iam/security-credentials/"
+	REGION_PATH            = "/latest/meta-data/placement/region"
+	METADATA_PATH          = "/latest/meta-data/"
+	GRANT_TYPE             = "client_credentials"
+	AWS_OIDC_TOKEN_URL     = "https://$user_pool_resource_domain.auth.$region.amazoncognito.com/oauth2/token"
+
+	// EKS_POD_IDENTITY_CREDENTIALS_URI is the EKS Pod Identity agent on the node
+	EKS_POD_IDENTITY_CREDENTIALS_URI = "http://169.254.170.23/v1/credentials"
 
 	CREDENTIALS_SUCCESS_CODE = "Success"
 	CREDENTIALS_TOKEN_TYPE   = "AWS-HMAC"
@@ -65,14 +75,13 @@ type TempCredentials struct {
 	Expiration      string `json:"Expiration"`
 }
 
-// Add custom error types for better error handling
-type CredentialError struct {
-	Operation string
-	Err       error
-}
-
-func (e *CredentialError) Error() string {
-	return fmt.Sprintf("%s failed: %v", e.Operation, e.Err)
+// ContainerCredentials is the answer of the EKS Pod Identity agent.
+type ContainerCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	AccountId       string `json:"AccountId"`
+	Expiration      string `json:"Expiration"`
 }
 
 type SecretResult struct {
@@ -98,7 +107,7 @@ func GetAwsOidcToken(s *service.Service, ctx context.Context, awsRoleName, secre
 		return "", err
 	}
 
-	awsConfig, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+	awsConfig, err := loadAWSConfig(s, context.TODO(), region)
 	if err != nil {
 		return "", fmt.Errorf("error loading AWS config: %v", err)
 	}
@@ -111,6 +120,41 @@ func GetAwsOidcToken(s *service.Service, ctx context.Context, awsRoleName, secre
 	return requestOidcToken(s, ctx, secretResult, resourceServerId, userPoolResourceDomain, scope, region)
 }
 
+// loadAWSConfig loads the SDK config for region, retrying STS and Secrets
+// Manager calls as many times as the provider's own HTTP calls, and reading
+// instance credentials from the same IMDS endpoint.
+func loadAWSConfig(s *service.Service, ctx context.Context, region string) (aws.Config, error) {
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithRetryMaxAttempts(max(s.Retry.MaxAttempts, 1)),
+		// STS and Secrets Manager calls share the timeout and proxy of the provider client
+		config.WithHTTPClient(sdkHTTPClient(s.Client)),
+	}
+	if s.Endpoints.AWSIMDS != "" {
+		options = append(options, config.WithEC2IMDSEndpoint(s.Endpoints.AWSIMDS))
+	}
+	if fips.Enabled() {
+		// STS and Secrets Manager calls go to their sts-fips and secretsmanager-fips endpoints
+		options = append(options, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	return config.LoadDefaultConfig(ctx, options...)
+}
+
+// sdkHTTPClient builds an SDK client with the timeout, proxy and TLS settings
+// of client. The SDK needs a buildable client to add AWS_CA_BUNDLE to it.
+func sdkHTTPClient(client *http.Client) *awshttp.BuildableClient {
+	sdkClient := awshttp.NewBuildableClient().WithTimeout(client.Timeout)
+	if base, ok := client.Transport.(*http.Transport); ok {
+		sdkClient = sdkClient.WithTransportOptions(func(tr *http.Transport) {
+			tr.Proxy = base.Proxy
+			if base.TLSClientConfig != nil {
+				tr.TLSClientConfig = base.TLSClientConfig.Clone()
+			}
+		})
+	}
+	return sdkClient
+}
+
 func getRegionOrDefault(s *service.Service, ctx context.Context, token string) (string, error) {
 	region, err := getAWSRegion(s, ctx, token)
 	if err != nil {
@@ -122,7 +166,7 @@ func getRegionOrDefault(s *service.Service, ctx context.Context, token string) (
 }
 
 func getSecretFromManager(s *service.Service, secretName, region string) (SecretResult, error) {
-	config, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+	config, err := loadAWSConfig(s, context.TODO(), region)
 	if err != nil {
 		return SecretResult{}, fmt.Errorf("error loading default config: %v", err)
 	}
@@ -156,13 +200,14 @@ func requestOidcToken(s *service.Service, ctx context.Context, secretResult Secr
 	oidcUrl = strings.Replace(oidcUrl, "$user_pool_resource_domain", userPoolResourceDomain, 1)
 	s.Logger.Info("oidcUrl: " + oidcUrl)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", oidcUrl, strings.NewReader(data.Encode()))
+	// a client credentials grant can be repeated, retry it like a GET
+	req, err := http.NewRequestWithContext(service.WithReplay(ctx, true), "POST", oidcUrl, strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("error creating OIDC token request: %v", err)
 	}
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := s.Client.Do(req)
+	resp, err := s.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error calling OIDC token API: %v", err)
 	}
@@ -193,7 +238,7 @@ func GetAWSWebIdentityCredentials(s *service.Service, ctx context.Context,
 	s.Logger.Info("running aws web identity auth flow with IRSA")
 
 	// Load AWS config with the specified region
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(s, ctx, region)
 	if err != nil {
 		return &types.Credentials{}, fmt.Errorf("error loading default config: %v", err)
 	}
@@ -221,7 +266,7 @@ func GetAWSWebIdentityCredentials(s *service.Service, ctx context.Context,
 			s.Logger.Info(`"oidc.eks.YOUR_REGION.amazonaws.com/id/YOUR_CLUSTER_ID:aud": "https://kubernetes.default.svc"`)
 		}
 
-		return nil, &CredentialError{"assume role with web identity", err}
+		return nil, &CredentialError{CodeSTSDenied, "assume role with web identity", err}
 	}
 
 	s.Logger.Info("Successfully assumed role with web identity")
@@ -230,17 +275,25 @@ func GetAWSWebIdentityCredentials(s *service.Service, ctx context.Context,
 
 func GetAWSSignedRequest(s *service.Service, ctx context.Context, serviceAccountToken string, awsEnvVariables utils.AWSEnvVariables) (*http.Request, error) {
 	s.Logger.Info("running aws assume role auth flow")
-	// get token from metadata service
-	token, err := getToken(s, ctx)
-	if err != nil {
-		return nil, fmt.Errorf("Error getting aws token, %v", err)
-	}
 	var credentials TempCredentials
+	// the metadata token is only needed for the instance role and the region,
+	// pod level credentials work where IMDS is out of reach of the pods
+	token, tokenErr := "", error(nil)
+	if awsEnvVariables.AWSAuthMethod == "assume_role" || os.Getenv("aws_region") == "" {
+		token, tokenErr = getToken(s, ctx)
+		if tokenErr != nil && awsEnvVariables.AWSAuthMethod == "assume_role" {
+			return nil, fmt.Errorf("Error getting aws token, %w", tokenErr)
+		}
+	}
 
 	// Determine AWS region: prefer explicit env var, then EC2 metadata, then fallback to "*"
+	var err error
 	region := os.Getenv("aws_region")
 	if region != "" {
 		s.Logger.Info("Using AWS region from aws_region env var: " + region)
+	} else if tokenErr != nil {
+		s.Logger.Info("error getting aws token: " + tokenErr.Error() + ", using default region *")
+		region = "*"
 	} else {
 		region, err = getAWSRegion(s, ctx, token)
 		if err != nil {
@@ -252,21 +305,36 @@ func GetAWSSignedRequest(s *service.Service, ctx context.Context, serviceAccount
 	}
 
 	switch awsEnvVariables.AWSAuthMethod {
+	case "pod_identity":
+		credentials, err = getPodIdentityCredentials(s, ctx, awsEnvVariables.ContainerCredentialsURI, awsEnvVariables.ContainerAuthorizationTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("getPodIdentityCredentials returned err %w", err)
+		}
+	case "web_identity_token_file":
+		webIdentityToken, err := readTokenFile(awsEnvVariables.WebIdentityTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		credentialsWebIdentity, err := GetAWSWebIdentityCredentials(s, ctx, webIdentityToken, awsEnvVariables.AWSRoleName, region)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting web identity credentials: %w", err)
+		}
+		credentials = webIdentityTempCredentials(credentialsWebIdentity)
 	case "assume_role":
 		// get temp credentials from metadata service
 		credentials, err = getTempCredentials(s, ctx, token, awsEnvVariables.AWSRoleName)
 		if err != nil {
-			return nil, fmt.Errorf("GetTempCredentials returned err %v", err)
+			return nil, fmt.Errorf("GetTempCredentials returned err %w", err)
 		}
 		s.Logger.Info("GetTempCredentials returned code :" + credentials.Code)
 		if credentials.Code != CREDENTIALS_SUCCESS_CODE {
-			return nil, fmt.Errorf("GetTempCredentials failed with return code %s", credentials.Code)
+			return nil, &CredentialError{CodeIMDSUnreachable, "get instance role credentials", fmt.Errorf("metadata service returned code %s", credentials.Code)}
 		}
 	case "assume_external_role":
 		// get temp credentials by assuming role
-		credentials, err = assumeRoleAndGetCredentials(s, ctx, awsEnvVariables.AWSExternalRoleDurationSeconds, awsEnvVariables.AWSExternalRoleARN, region)
+		credentials, err = assumeRoleAndGetCredentials(s, ctx, awsEnvVariables, region)
 		if err != nil {
-			return nil, fmt.Errorf("assumeRoleAndGetCredentials returned err %v", err)
+			return nil, fmt.Errorf("assumeRoleAndGetCredentials returned err %w", err)
 		}
 		s.Logger.Info("assumeRoleAndGetCredentials returned code :" + credentials.Code)
 		if credentials.Code != CREDENTIALS_SUCCESS_CODE {
@@ -276,29 +344,51 @@ func GetAWSSignedRequest(s *service.Service, ctx context.Context, serviceAccount
 		// Get temporary credentials using WebIdentity
 		credentialsWebIdentity, err := GetAWSWebIdentityCredentials(s, ctx, serviceAccountToken, awsEnvVariables.AWSRoleName, region)
 		if err != nil {
-			return nil, fmt.Errorf("Error getting web identity credentials: %v", err)
+			return nil, fmt.Errorf("Error getting web identity credentials: %w", err)
 		}
-		credentials = TempCredentials{AccessKeyId: *credentialsWebIdentity.AccessKeyId,
-			SecretAccessKey: *credentialsWebIdentity.SecretAccessKey,
-			Token:           *credentialsWebIdentity.SessionToken}
+		credentials = webIdentityTempCredentials(credentialsWebIdentity)
 	}
 
 	creds := signer.AwsCredentials{
 		AccessKey:    credentials.AccessKeyId,
 		SecretKey:    credentials.SecretAccessKey,
-		RegionName:   region,
 		SessionToken: credentials.Token,
 	}
+	// lets the signer keep the SigV4a key derived from them until then
+	if expires, err := time.Parse(time.RFC3339, credentials.Expiration); err == nil {
+		creds.Expires = expires
+	}
+	algorithm, err := signer.ParseAlgorithm(awsEnvVariables.SigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
 	stsGlobalURL := "https://sts.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15"
+	opts := signer.Options{Algorithm: algorithm, Service: "sts", Region: "*"}
+
+	if fips.Enabled() {
+		// FIPS endpoints are regional only, there is no global one to fall back to
+		if region == "*" || region == "" {
+			return nil, ConfigError(CodeConfigInvalid, "FIPS 140-3 mode needs the AWS region of the FIPS STS endpoint, set aws_region")
+		}
+		stsFIPSURL := fmt.Sprintf("https://sts-fips.%s.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15", region)
+		s.Logger.Info("Using FIPS STS endpoint: " + stsFIPSURL + ", signing with " + string(algorithm))
+		opts.Region = region
+		req, err := signer.NewSignedRequest(ctx, "GET", stsFIPSURL, nil, creds, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Error signing the request: %w", err)
+		}
+		return req, nil
+	}
 
 	if region != "*" && region != "" {
 		stsRegionalURL := fmt.Sprintf("https://sts.%s.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15", region)
-		s.Logger.Info("Using regional STS endpoint: " + stsRegionalURL)
-		req, err := signer.SignV4a("GET", stsRegionalURL, "sts", creds)
+		s.Logger.Info("Using regional STS endpoint: " + stsRegionalURL + ", signing with " + string(algorithm))
+		opts.Region = region
+		req, err := signer.NewSignedRequest(ctx, "GET", stsRegionalURL, nil, creds, opts)
 		if err != nil {
 			s.Logger.Info("Regional signing failed, falling back to global STS endpoint: " + stsGlobalURL)
-			creds.RegionName = "*"
-			req, err = signer.SignV4a("GET", stsGlobalURL, "sts", creds)
+			opts.Region = "*"
+			req, err = signer.NewSignedRequest(ctx, "GET", stsGlobalURL, nil, creds, opts)
 			if err != nil {
 				return nil, fmt.Errorf("Error signing the request: %s", err)
 			}
@@ -306,27 +396,43 @@ func GetAWSSignedRequest(s *service.Service, ctx context.Context, serviceAccount
 		return req, nil
 	}
 
-	s.Logger.Info("Using global STS endpoint: " + stsGlobalURL)
-	req, err := signer.SignV4a("GET", stsGlobalURL, "sts", creds)
+	s.Logger.Info("Using global STS endpoint: " + stsGlobalURL + ", signing with " + string(algorithm))
+	req, err := signer.NewSignedRequest(ctx, "GET", stsGlobalURL, nil, creds, opts)
 	if err != nil {
 		return nil, fmt.Errorf("Error signing the request: %s", err)
 	}
 	return req, nil
 }
 
+func webIdentityTempCredentials(credentials *types.Credentials) TempCredentials {
+	tempCredentials := TempCredentials{AccessKeyId: *credentials.AccessKeyId,
+		SecretAccessKey: *credentials.SecretAccessKey,
+		Token:           *credentials.SessionToken}
+	if credentials.Expiration != nil {
+		tempCredentials.Expiration = credentials.Expiration.UTC().Format(time.RFC3339)
+	}
+	return tempCredentials
+}
+
+// awsIMDSURL returns the URL of path on the instance metadata service.
+func awsIMDSURL(s *service.Service, path string) string {
+	return service.EndpointURL(s.Endpoints.AWSIMDS, AWS_IMDS_ENDPOINT, path)
+}
+
 func getToken(s *service.Service, ctx context.Context) (string, error) {
-	s.Logger.Info("TOKEN_URL :" + TOKEN_URL)
+	tokenUrl := awsIMDSURL(s, TOKEN_PATH)
+	s.Logger.Info("TOKEN_URL :" + tokenUrl)
 	// Create a new request
-	req, err := http.NewRequestWithContext(ctx, "PUT", TOKEN_URL, nil)
+	req, err := http.NewRequestWithContext(ctx, "PUT", tokenUrl, nil)
 	if err != nil {
-		return "", &CredentialError{"create token request", err}
+		return "", &CredentialError{CodeIMDSUnreachable, "create token request", err}
 	}
 	// Add headers if needed
 	req.Header.Add("X-aws-ec2-metadata-token-ttl-seconds", "600")
 	// Make the request
-	resp, err := s.Client.Do(req)
+	resp, err := s.Do(req)
 	if err != nil {
-		return "", err
+		return "", &CredentialError{CodeIMDSUnreachable, "get metadata token", err}
 	}
 	defer resp.Body.Close()
 
@@ -337,30 +443,54 @@ func getToken(s *service.Service, ctx context.Context) (string, error) {
 	}
 	// Check if the status code is successful
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GET TOKEN API call failed with status code: %d, body: %s", resp.StatusCode, string(body))
+		return "", &CredentialError{CodeIMDSUnreachable, "get metadata token", fmt.Errorf("GET TOKEN API call failed with status code: %d, body: %s", resp.StatusCode, string(body))}
 	}
 	return string(body), nil
 }
 
-func assumeRoleAndGetCredentials(s *service.Service, ctx context.Context, awsExternalRoleDurationSeconds int, awsRoleArn, region string) (TempCredentials, error) {
-	s.Logger.Info("Handling external assume role flow, role ARN:" + awsRoleArn + ", region:" + region)
+// assumeRoleAndGetCredentials assumes every role of chain in order, the
+// first with the default credential chain and each next one with the
+// credentials of the previous hop, and logs the caller identity of each hop.
+// Every hop uses the same role session name, the source identity is set on
+// the first one and STS carries it along the chain.
+func assumeRoleAndGetCredentials(s *service.Service, ctx context.Context, awsEnvVariables utils.AWSEnvVariables, region string) (TempCredentials, error) {
+	chain := awsEnvVariables.AWSExternalRoleChain
+	s.Logger.Info(fmt.Sprintf("Handling external assume role flow, %d role(s), region:%s", len(chain), region))
 	if region == "" || region == "*" {
 		return TempCredentials{}, fmt.Errorf("assume_external_role requires a valid AWS region; got %q (set aws_region env var)", region)
 	}
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	cfg, err := loadAWSConfig(s, ctx, region)
 	if err != nil {
 		s.Logger.Error("failed to get default config from AWS :" + err.Error())
 		return TempCredentials{}, err
 	}
-	stsClient := sts.NewFromConfig(cfg)
-	provider := stscreds.NewAssumeRoleProvider(stsClient, awsRoleArn, func(o *stscreds.AssumeRoleOptions) {
-		o.RoleSessionName = "jfrog-credential-provider-" + utils.RandString(10)
-		o.Duration = time.Duration(awsExternalRoleDurationSeconds) * time.Second
-	})
-	creds, err := provider.Retrieve(ctx)
-	if err != nil {
-		s.Logger.Error("failed to get creds from STS :" + err.Error())
-		return TempCredentials{}, err
+	var creds aws.Credentials
+	for i, hop := range chain {
+		hopConfig := cfg.Copy()
+		if i > 0 {
+			hopConfig.Credentials = credentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)
+		}
+		duration := awsEnvVariables.AWSExternalRoleDurationSeconds
+		// STS refuses more than an hour for a session assumed by another role
+		if i > 0 && duration > 3600 {
+			s.Logger.Info("chained role sessions are limited to 3600 seconds, using it for " + hop.RoleARN)
+			duration = 3600
+		}
+		s.Logger.Info(fmt.Sprintf("assuming role %d/%d: %s", i+1, len(chain), hop.RoleARN))
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(hopConfig), hop.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = awsEnvVariables.AWSExternalRoleSessionName
+			o.Duration = time.Duration(duration) * time.Second
+			if i == 0 && awsEnvVariables.AWSExternalRoleSourceIdentity != "" {
+				o.SourceIdentity = aws.String(awsEnvVariables.AWSExternalRoleSourceIdentity)
+			}
+			applyRoleHopOptions(o, hop)
+		})
+		creds, err = provider.Retrieve(ctx)
+		if err != nil {
+			s.Logger.Error("failed to get creds from STS :" + err.Error())
+			return TempCredentials{}, &CredentialError{CodeSTSDenied, fmt.Sprintf("assume role %d/%d %s", i+1, len(chain), hop.RoleARN), err}
+		}
+		logCallerIdentity(s, ctx, hopConfig, creds, i+1, len(chain))
 	}
 	return TempCredentials{
 		Code:            CREDENTIALS_SUCCESS_CODE,
@@ -373,10 +503,88 @@ func assumeRoleAndGetCredentials(s *service.Service, ctx context.Context, awsExt
 	}, nil
 
 }
+
+// applyRoleHopOptions sets the external id, session policies and session
+// tags of hop on its AssumeRole call.
+func applyRoleHopOptions(o *stscreds.AssumeRoleOptions, hop utils.AWSRoleHop) {
+	if hop.ExternalID != "" {
+		o.ExternalID = aws.String(hop.ExternalID)
+	}
+	if hop.Policy != "" {
+		o.Policy = aws.String(hop.Policy)
+	}
+	for _, policyARN := range hop.PolicyARNs {
+		o.PolicyARNs = append(o.PolicyARNs, types.PolicyDescriptorType{Arn: aws.String(policyARN)})
+	}
+	for _, key := range slices.Sorted(maps.Keys(hop.Tags)) {
+		o.Tags = append(o.Tags, types.Tag{Key: aws.String(key), Value: aws.String(hop.Tags[key])})
+	}
+	o.TransitiveTagKeys = hop.TransitiveTagKeys
+}
+
+// logCallerIdentity logs who creds authenticate as, so the identity reaching
+// each account of a role chain can be audited. It only logs failures, the
+// hop already succeeded.
+func logCallerIdentity(s *service.Service, ctx context.Context, cfg aws.Config, creds aws.Credentials, hop, hops int) {
+	cfg.Credentials = credentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("could not get the caller identity of role %d/%d: %s", hop, hops, err.Error()))
+		return
+	}
+	s.Logger.Info(fmt.Sprintf("role %d/%d caller identity: %s (account %s)", hop, hops, aws.ToString(identity.Arn), aws.ToString(identity.Account)))
+}
+
+// getPodIdentityCredentials gets the credentials of the pod's IAM role from
+// the EKS Pod Identity agent, authenticating with the token the agent mounts.
+func getPodIdentityCredentials(s *service.Service, ctx context.Context, credentialsURI, authorizationTokenFile string) (TempCredentials, error) {
+	if credentialsURI == "" {
+		credentialsURI = EKS_POD_IDENTITY_CREDENTIALS_URI
+	}
+	authorizationToken, err := readTokenFile(authorizationTokenFile)
+	if err != nil {
+		return TempCredentials{}, err
+	}
+	s.Logger.Info("pod identity credentials url :" + credentialsURI)
+	req, err := http.NewRequestWithContext(ctx, "GET", credentialsURI, nil)
+	if err != nil {
+		return TempCredentials{}, err
+	}
+	req.Header.Add("Authorization", authorizationToken)
+	resp, err := s.Do(req)
+	if err != nil {
+		return TempCredentials{}, &CredentialError{CodeIMDSUnreachable, "get pod identity credentials", err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return TempCredentials{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		code := CodeIMDSUnreachable
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			code = CodeSTSDenied
+		}
+		return TempCredentials{}, &CredentialError{code, "get pod identity credentials", fmt.Errorf("GET pod identity credentials failed with status code: %d, body: %s", resp.StatusCode, string(body))}
+	}
+	var containerCredentials ContainerCredentials
+	if err := json.Unmarshal(body, &containerCredentials); err != nil {
+		return TempCredentials{}, fmt.Errorf("Error unmarshaling JSON: %v", err)
+	}
+	return TempCredentials{
+		Code:            CREDENTIALS_SUCCESS_CODE,
+		TokenType:       CREDENTIALS_TOKEN_TYPE,
+		AccessKeyId:     containerCredentials.AccessKeyId,
+		SecretAccessKey: containerCredentials.SecretAccessKey,
+		Token:           containerCredentials.Token,
+		Expiration:      containerCredentials.Expiration,
+	}, nil
+}
+
 func getTempCredentials(s *service.Service, ctx context.Context, token string, awsRoleName string) (TempCredentials, error) {
-	s.Logger.Info("TEMP_SESSION_URL :" + TOKEN_URL)
 	// Create a new request
-	url := TEMP_SESSION_URL + awsRoleName
+	url := awsIMDSURL(s, TEMP_SESSION_PATH+awsRoleName)
 	s.Logger.Info("role temp session url :" + url)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -385,9 +593,9 @@ func getTempCredentials(s *service.Service, ctx context.Context, token string, a
 	// Add headers if needed
 	req.Header.Add("X-aws-ec2-metadata-token", token)
 	// Make the request
-	resp, err := s.Client.Do(req)
+	resp, err := s.Do(req)
 	if err != nil {
-		return TempCredentials{}, err
+		return TempCredentials{}, &CredentialError{CodeIMDSUnreachable, "get instance role credentials", err}
 	}
 	defer resp.Body.Close()
 
@@ -398,7 +606,7 @@ func getTempCredentials(s *service.Service, ctx context.Context, token string, a
 	}
 	// Check if the status code is successful
 	if resp.StatusCode != http.StatusOK {
-		return TempCredentials{}, fmt.Errorf("PUT temp session API call failed with status code: %d, body: %s", resp.StatusCode, string(body))
+		return TempCredentials{}, &CredentialError{CodeIMDSUnreachable, "get instance role credentials", fmt.Errorf("PUT temp session API call failed with status code: %d, body: %s", resp.StatusCode, string(body))}
 	}
 	var tempCredentials TempCredentials
 	if err := json.Unmarshal(body, &tempCredentials); err != nil {
@@ -409,14 +617,14 @@ func getTempCredentials(s *service.Service, ctx context.Context, token string, a
 
 func getAWSRegion(s *service.Service, ctx context.Context, token string) (string, error) {
 	// Then get the region
-	req, err := http.NewRequestWithContext(ctx, "GET", REGION_URL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", awsIMDSURL(s, REGION_PATH), nil)
 	if err != nil {
 		return "", fmt.Errorf("Error creating request to get AWS region: %v", err)
 	}
 
 	req.Header.Add("X-aws-ec2-metadata-token", token)
 
-	resp, err := s.Client.Do(req)
+	resp, err := s.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("Error getting AWS region: %v", err)
 	}
@@ -532,7 +740,7 @@ func CheckIfAWS(s *service.Service, ctx context.Context) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("Error getting aws token: %v", err)
 	}
-	req, err := http.NewRequestWithContext(ctx, "GET", METADATA_URL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", awsIMDSURL(s, METADATA_PATH), nil)
 	req.Header.Add("X-aws-ec2-metadata-token", token)
 	if err != nil {
 		return false, fmt.Errorf("Error creating request to check if cloud provider is AWS: %v", err)
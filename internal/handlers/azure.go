@@ -20,16 +20,18 @@ import (
 	"fmt"
 	"io"
 	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/utils"
 	"net/http"
 	"net/url"
 	"strings"
 )
 
 const (
-	AZURE_IDENTITY_ENDPOINT = "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2023-11-01&resource=$audience&client_id=$nodepool_client_id"
-	AZURE_GRANT_TYPE        = "client_credentials"
-	AZURE_SCOPE             = "$client_id/.default"
-	AZURE_METADATA_URL      = "http://169.254.169.254/metadata/instance?api-version=2021-02-01"
+	AZURE_IMDS_ENDPOINT = "http://169.254.169.254"
+	AZURE_IDENTITY_PATH = "/metadata/identity/oauth2/token?api-version=2023-11-01&resource=$audience&client_id=$nodepool_client_id"
+	AZURE_GRANT_TYPE    = "client_credentials"
+	AZURE_SCOPE         = "$client_id/.default"
+	AZURE_METADATA_PATH = "/metadata/instance?api-version=2021-02-01"
 )
 
 type OidcResult struct {
@@ -58,21 +60,10 @@ type JWTHeader struct {
 	X5t       string `json:"x5t,omitempty"`
 }
 
-// getAzureADEndpoint returns the Azure Active Directory endpoint based on the cloud name
-func getAzureADEndpoint(cloudName string) string {
-	switch cloudName {
-	case "AzureCloud":
-		return "https://login.microsoftonline.com"
-	case "AzureChinaCloud":
-		return "https://login.partner.microsoftonline.cn"
-	default:
-		return "https://login.microsoftonline.com"
-	}
-}
-
 // GetAzureClusterIdentity retrieves the identity token from the kubelet managed identity
 func GetAzureClusterIdentity(s *service.Service, ctx context.Context, azureAppAudience, azureNodepoolClientId string) (string, error) {
-	tokenEndpoint := strings.Replace(AZURE_IDENTITY_ENDPOINT, "$audience", azureAppAudience, 1)
+	tokenEndpoint := service.EndpointURL(s.Endpoints.AzureIMDS, AZURE_IMDS_ENDPOINT, AZURE_IDENTITY_PATH)
+	tokenEndpoint = strings.Replace(tokenEndpoint, "$audience", azureAppAudience, 1)
 	tokenEndpoint = strings.Replace(tokenEndpoint, "$nodepool_client_id", azureNodepoolClientId, 1)
 
 	tokenReq, err := http.NewRequestWithContext(ctx, "GET", tokenEndpoint, nil)
@@ -80,9 +71,9 @@ func GetAzureClusterIdentity(s *service.Service, ctx context.Context, azureAppAu
 		return "", fmt.Errorf("NewRequestWithContext from azure identity token fetching failed: %v", err)
 	}
 	tokenReq.Header.Add("Metadata", "true")
-	tokenResp, err := s.Client.Do(tokenReq)
+	tokenResp, err := s.Do(tokenReq)
 	if err != nil {
-		return "", fmt.Errorf("Calling azure identity token failed: %v" + err.Error())
+		return "", &CredentialError{CodeIMDSUnreachable, "get azure identity token", err}
 	}
 	defer tokenResp.Body.Close()
 
@@ -93,7 +84,7 @@ func GetAzureClusterIdentity(s *service.Service, ctx context.Context, azureAppAu
 	}
 	// Check if the status code is successful
 	if tokenResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GET identity token API call failed with status code: %d, body: %s", tokenResp.StatusCode, string(tokenBody))
+		return "", &CredentialError{CodeIMDSUnreachable, "get azure identity token", fmt.Errorf("GET identity token API call failed with status code: %d, body: %s", tokenResp.StatusCode, string(tokenBody))}
 	}
 
 	s.Logger.Info("constructing identityTokenResult")
@@ -110,7 +101,7 @@ func GetAzureClusterIdentity(s *service.Service, ctx context.Context, azureAppAu
 
 // GetAzureOIDCToken retrieves an OIDC token from Azure using managed identity
 func GetAzureOIDCToken(s *service.Service, ctx context.Context,
-	tenantId, clientId, azureNodepoolClientId, azureAppAudience, cloudName string) (string, error) {
+	tenantId, clientId, azureNodepoolClientId, azureAppAudience, cloudName, authorityHost string) (string, error) {
 
 	identityTokenAssertion, err := GetAzureClusterIdentity(s, ctx, azureAppAudience, azureNodepoolClientId)
 	if err != nil {
@@ -118,8 +109,19 @@ func GetAzureOIDCToken(s *service.Service, ctx context.Context,
 		return "", err
 	}
 
+	return ExchangeAzureClientAssertion(s, ctx, tenantId, clientId, identityTokenAssertion, cloudName, authorityHost)
+}
+
+// ExchangeAzureClientAssertion gets an Azure AD access token for clientId
+// with the client credentials grant, authenticating with a federated
+// assertion (the node identity token or a workload identity token).
+func ExchangeAzureClientAssertion(s *service.Service, ctx context.Context,
+	tenantId, clientId, assertion, cloudName, authorityHost string) (string, error) {
 	// Get Azure AD endpoint based on cloud name
-	azureADEndpoint := getAzureADEndpoint(cloudName)
+	azureADEndpoint, err := utils.AzureAuthorityHost(cloudName, authorityHost)
+	if err != nil {
+		return "", &CredentialError{CodeConfigInvalid, "get azure ad endpoint", err}
+	}
 	s.Logger.Info(fmt.Sprintf("Using Azure AD endpoint: %s for cloud: %s", azureADEndpoint, cloudName))
 	oidcURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", azureADEndpoint, tenantId)
 
@@ -127,23 +129,24 @@ func GetAzureOIDCToken(s *service.Service, ctx context.Context,
 	data := url.Values{}
 	data.Set("client_id", clientId)
 	data.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
-	data.Set("client_assertion", identityTokenAssertion)
+	data.Set("client_assertion", assertion)
 	data.Set("grant_type", AZURE_GRANT_TYPE)
 	data.Set("scope", strings.Replace(AZURE_SCOPE, "$client_id", clientId, 1))
 	data.Set("subject_token_type", "urn:ietf:params:oauth:token-type:jwt")
 
 	// Get oidc token
-	req, err := http.NewRequestWithContext(ctx, "POST", oidcURL, strings.NewReader(data.Encode()))
+	// the client assertion grant can be repeated, retry it like a GET
+	req, err := http.NewRequestWithContext(service.WithReplay(ctx, true), "POST", oidcURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("NewRequestWithContext from azure oidc token failed: %v" + err.Error())
+		return "", fmt.Errorf("NewRequestWithContext from azure oidc token failed: %v", err)
 	}
 	// Add headers if needed
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	// Make the request
-	resp, err := s.Client.Do(req)
+	resp, err := s.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("Calling azure oidc token failed: %v" + err.Error())
+		return "", fmt.Errorf("Calling azure oidc token failed: %v", err)
 	}
 	defer resp.Body.Close()
 
@@ -167,7 +170,7 @@ func GetAzureOIDCToken(s *service.Service, ctx context.Context,
 
 func CheckIfAzure(s *service.Service, ctx context.Context) (bool, error) {
 	s.Logger.Info("Checking if cloud provider is Azure")
-	req, err := http.NewRequestWithContext(ctx, "GET", AZURE_METADATA_URL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", service.EndpointURL(s.Endpoints.AzureIMDS, AZURE_IMDS_ENDPOINT, AZURE_METADATA_PATH), nil)
 	if err != nil {
 		return false, fmt.Errorf("Error creating request to check if cloud provider is Azure: %v", err)
 	}
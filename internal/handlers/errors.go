@@ -0,0 +1,147 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode classifies why the credential flow failed. Codes are part of the
+// plugin's output (exit code and stderr line) and must stay stable.
+type ErrorCode string
+
+const (
+	// CodeConfigMissing: a required provider env variable is not set
+	CodeConfigMissing ErrorCode = "CONFIG_MISSING"
+	// CodeConfigInvalid: a provider env variable has an unsupported value
+	CodeConfigInvalid ErrorCode = "CONFIG_INVALID"
+	// CodeRequestInvalid: the kubelet request could not be read or lacks a
+	// required field (e.g. the service account token)
+	CodeRequestInvalid ErrorCode = "REQUEST_INVALID"
+	// CodeCloudAmbiguous: cloud_provider is not set and more than one cloud
+	// metadata service answered during detection
+	CodeCloudAmbiguous ErrorCode = "CLOUD_AMBIGUOUS"
+	// CodeIMDSUnreachable: the cloud metadata service could not be queried
+	CodeIMDSUnreachable ErrorCode = "IMDS_UNREACHABLE"
+	// CodeSTSDenied: AWS STS refused to issue credentials for the role
+	CodeSTSDenied ErrorCode = "STS_DENIED"
+	// CodeCloudAuthFailed: the cloud identity token could not be obtained
+	// (Azure AD, Google IAM, Cognito, request signing)
+	CodeCloudAuthFailed ErrorCode = "CLOUD_AUTH_FAILED"
+	// CodeRTExchangeFailed: Artifactory refused or failed the token exchange
+	CodeRTExchangeFailed ErrorCode = "RT_EXCHANGE_FAILED"
+	// CodeTokenFileInvalid: a mounted token file is missing, empty or unusable
+	CodeTokenFileInvalid ErrorCode = "TOKEN_FILE_INVALID"
+	// CodeInternal: anything not classified above
+	CodeInternal ErrorCode = "INTERNAL"
+)
+
+var exitCodes = map[ErrorCode]int{
+	CodeConfigMissing:    2,
+	CodeConfigInvalid:    3,
+	CodeRequestInvalid:   4,
+	CodeCloudAmbiguous:   5,
+	CodeIMDSUnreachable:  10,
+	CodeSTSDenied:        11,
+	CodeCloudAuthFailed:  12,
+	CodeRTExchangeFailed: 13,
+	CodeTokenFileInvalid: 14,
+}
+
+// ExitCode returns the process exit code reported for c, 1 for CodeInternal
+// and unknown codes.
+func (c ErrorCode) ExitCode() int {
+	if code, ok := exitCodes[c]; ok {
+		return code
+	}
+	return 1
+}
+
+// CredentialError is a failure of one step of the credential flow.
+type CredentialError struct {
+	Code      ErrorCode
+	Operation string
+	Err       error
+}
+
+func (e *CredentialError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Operation, e.Err)
+}
+
+func (e *CredentialError) Unwrap() error {
+	return e.Err
+}
+
+// NewCredentialError wraps err as a failure of operation. A code already
+// carried by err is more specific than the caller's and is kept, so e.g. an
+// IMDS timeout deep inside request signing is still reported as such.
+func NewCredentialError(code ErrorCode, operation string, err error) *CredentialError {
+	if inner := ErrorCodeOf(err); inner != CodeInternal {
+		code = inner
+	}
+	return &CredentialError{Code: code, Operation: operation, Err: err}
+}
+
+// ConfigError reports a missing or invalid provider setting.
+func ConfigError(code ErrorCode, format string, args ...any) *CredentialError {
+	return &CredentialError{Code: code, Operation: "validate provider config", Err: fmt.Errorf(format, args...)}
+}
+
+// ErrorCodeOf returns the code of the first CredentialError in err's chain,
+// CodeInternal if there is none.
+func ErrorCodeOf(err error) ErrorCode {
+	var credentialErr *CredentialError
+	if errors.As(err, &credentialErr) && credentialErr.Code != "" {
+		return credentialErr.Code
+	}
+	return CodeInternal
+}
+
+// ErrorLine is the machine-readable error the plugin writes to stderr as a
+// single JSON line.
+type ErrorLine struct {
+	Source   string    `json:"source"`
+	Code     ErrorCode `json:"code"`
+	ExitCode int       `json:"exit_code"`
+	Message  string    `json:"message"`
+}
+
+const errorLineSource = "jfrog-credentials-provider"
+
+// NewErrorLine describes err for the stderr error line.
+func NewErrorLine(err error) ErrorLine {
+	code := ErrorCodeOf(err)
+	return ErrorLine{Source: errorLineSource, Code: code, ExitCode: code.ExitCode(), Message: err.Error()}
+}
+
+// ParseErrorLine finds the error line in the stderr output of the plugin.
+func ParseErrorLine(stderr string) (ErrorLine, bool) {
+	for _, text := range strings.Split(stderr, "\n") {
+		var line ErrorLine
+		if json.Unmarshal([]byte(text), &line) == nil && line.Source == errorLineSource && line.Code != "" {
+			return line, true
+		}
+	}
+	return ErrorLine{}, false
+}
+
+// Err turns the error line back into a CredentialError, e.g. one reported by
+// another process.
+func (l ErrorLine) Err(operation string) *CredentialError {
+	return &CredentialError{Code: l.Code, Operation: operation, Err: errors.New(l.Message)}
+}
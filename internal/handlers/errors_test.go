@@ -0,0 +1,76 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewCredentialErrorKeepsInnerCode(t *testing.T) {
+	imds := &CredentialError{CodeIMDSUnreachable, "get metadata token", errors.New("connection refused")}
+	err := NewCredentialError(CodeCloudAuthFailed, "get aws signed request", fmt.Errorf("Error getting aws token, %w", imds))
+
+	if code := ErrorCodeOf(err); code != CodeIMDSUnreachable {
+		t.Fatalf("expected %s, got %s", CodeIMDSUnreachable, code)
+	}
+	if code := ErrorCodeOf(NewCredentialError(CodeCloudAuthFailed, "get aws oidc token", errors.New("denied"))); code != CodeCloudAuthFailed {
+		t.Fatalf("expected %s, got %s", CodeCloudAuthFailed, code)
+	}
+	if code := ErrorCodeOf(errors.New("unclassified")); code != CodeInternal {
+		t.Fatalf("expected %s, got %s", CodeInternal, code)
+	}
+}
+
+func TestErrorCodeExitCodes(t *testing.T) {
+	seen := map[int]ErrorCode{}
+	for code := range exitCodes {
+		exit := code.ExitCode()
+		if exit <= 1 {
+			t.Fatalf("%s must not share the generic exit code, got %d", code, exit)
+		}
+		if other, ok := seen[exit]; ok {
+			t.Fatalf("%s and %s share exit code %d", code, other, exit)
+		}
+		seen[exit] = code
+	}
+	if CodeInternal.ExitCode() != 1 || ErrorCode("UNKNOWN").ExitCode() != 1 {
+		t.Fatal("expected internal and unknown codes to exit with 1")
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	line, err := json.Marshal(NewErrorLine(ConfigError(CodeConfigMissing, "environment variables missing: %s", "artifactory_url")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := "some unrelated output\n" + string(line) + "\n"
+
+	parsed, ok := ParseErrorLine(stderr)
+	if !ok {
+		t.Fatal("expected to find the error line")
+	}
+	if parsed.Code != CodeConfigMissing || parsed.ExitCode != 2 {
+		t.Fatalf("unexpected error line %+v", parsed)
+	}
+	if ErrorCodeOf(parsed.Err("validate new binary")) != CodeConfigMissing {
+		t.Fatal("expected the code to survive the round trip")
+	}
+	if _, ok := ParseErrorLine(`{"code":"CONFIG_MISSING"}`); ok {
+		t.Fatal("expected lines from other sources to be ignored")
+	}
+}
@@ -22,13 +22,20 @@ import (
 	"io"
 	service "jfrog-credential-provider/internal"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
 const (
-	GOOGLE_OIDC_TOKEN_URL                    = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/$serviceaccount:generateIdToken"
-	GOOGLE_SERVICE_ACCOUNT_IMPERSONATION_URL = "http://169.254.169.254/computeMetadata/v1/instance/service-accounts/$serviceaccount/token"
-	GOOGLE_METADATA_URL                      = "http://169.254.169.254/computeMetadata/v1/instance/id"
+	GOOGLE_IAM_CREDENTIALS_ENDPOINT           = "https://iamcredentials.googleapis.com"
+	GOOGLE_OIDC_TOKEN_PATH                    = "/v1/projects/-/serviceAccounts/$serviceaccount:generateIdToken"
+	GOOGLE_METADATA_ENDPOINT                  = "http://169.254.169.254"
+	GOOGLE_SERVICE_ACCOUNT_IMPERSONATION_PATH = "/computeMetadata/v1/instance/service-accounts/$serviceaccount/token"
+	GOOGLE_METADATA_PATH                      = "/computeMetadata/v1/instance/id"
+	GOOGLE_METADATA_IDENTITY_PATH             = "/computeMetadata/v1/instance/service-accounts/$serviceaccount/identity?audience=$audience&format=full"
+	GOOGLE_STS_ENDPOINT                       = "https://sts.googleapis.com"
+	GOOGLE_STS_TOKEN_PATH                     = "/v1/token"
+	GOOGLE_CLOUD_PLATFORM_SCOPE               = "https://www.googleapis.com/auth/cloud-platform"
 )
 
 type GoogleOidcResult struct {
@@ -40,6 +47,16 @@ type GoogleOidcRequest struct {
 	IncludeEmail bool   `json:"includeEmail"`
 }
 
+// GoogleSTSRequest is the token exchange request of sts.googleapis.com/v1/token.
+type GoogleSTSRequest struct {
+	GrantType          string `json:"grantType"`
+	Audience           string `json:"audience"`
+	Scope              string `json:"scope"`
+	RequestedTokenType string `json:"requestedTokenType"`
+	SubjectToken       string `json:"subjectToken"`
+	SubjectTokenType   string `json:"subjectTokenType"`
+}
+
 type GoogleTokenResult struct {
 	TokenType  string `json:"token_type"`
 	Token      string `json:"access_token"`
@@ -60,10 +77,103 @@ func GetGoogleOIDCToken(s *service.Service, ctx context.Context,
 	return oidcToken, nil
 }
 
+// GetGoogleWorkloadIdentityOIDCToken exchanges a Kubernetes service account
+// token for a federated access token with Google STS, then uses it to get an
+// ID token for the Google service account bound to the pod.
+func GetGoogleWorkloadIdentityOIDCToken(s *service.Service, ctx context.Context,
+	subjectToken, workloadIdentityAudience, google_service_account_email, audience string) (string, error) {
+	federatedToken, err := exchangeGoogleSTSToken(s, ctx, subjectToken, workloadIdentityAudience)
+	if err != nil {
+		return "", err
+	}
+	return getServiceAccountOidcToken(s, ctx, federatedToken, google_service_account_email, audience)
+}
+
+// exchangeGoogleSTSToken trades subjectToken for a federated access token of
+// the workload identity pool or provider named by workloadIdentityAudience.
+func exchangeGoogleSTSToken(s *service.Service, ctx context.Context, subjectToken, workloadIdentityAudience string) (string, error) {
+	stsUrl := service.EndpointURL(s.Endpoints.GoogleSTS, GOOGLE_STS_ENDPOINT, GOOGLE_STS_TOKEN_PATH)
+	s.Logger.Info("stsUrl :" + stsUrl)
+
+	tokenRequestBody, err := json.Marshal(GoogleSTSRequest{
+		GrantType:          "urn:ietf:params:oauth:grant-type:token-exchange",
+		Audience:           workloadIdentityAudience,
+		Scope:              GOOGLE_CLOUD_PLATFORM_SCOPE,
+		RequestedTokenType: "urn:ietf:params:oauth:token-type:access_token",
+		SubjectToken:       subjectToken,
+		SubjectTokenType:   "urn:ietf:params:oauth:token-type:jwt",
+	})
+	if err != nil {
+		return "", fmt.Errorf("error marshaling request: %v", err)
+	}
+	// the token exchange can be repeated with the same subject token
+	req, err := http.NewRequestWithContext(service.WithReplay(ctx, true), "POST", stsUrl, bytes.NewBuffer(tokenRequestBody))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := s.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending google sts token request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Error exchanging the service account token with google sts for audience %s, returned %d response body %s",
+			workloadIdentityAudience, resp.StatusCode, string(body))
+	}
+	var tokenResult GoogleTokenResult
+	if err := json.Unmarshal(body, &tokenResult); err != nil {
+		return "", fmt.Errorf("error unmarshaling JSON: %v", err)
+	}
+	if tokenResult.Token == "" {
+		return "", fmt.Errorf("google sts returned no access token")
+	}
+	return tokenResult.Token, nil
+}
+
+// GetGoogleMetadataIdentityToken gets an ID token for a service account
+// attached to the node, "default" for the node's own, straight from the
+// metadata server. Unlike GetGoogleOIDCToken it needs no IAM Credentials call,
+// so no roles/iam.serviceAccountOpenIdTokenCreator binding.
+func GetGoogleMetadataIdentityToken(s *service.Service, ctx context.Context,
+	google_service_account_email string, audience string) (string, error) {
+	identityUrl := service.EndpointURL(s.Endpoints.GoogleMetadata, GOOGLE_METADATA_ENDPOINT, GOOGLE_METADATA_IDENTITY_PATH)
+	identityUrl = strings.Replace(identityUrl, "$serviceaccount", url.PathEscape(google_service_account_email), 1)
+	identityUrl = strings.Replace(identityUrl, "$audience", url.QueryEscape(audience), 1)
+	s.Logger.Info("identityUrl :" + identityUrl)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", identityUrl, nil)
+	if err != nil {
+		return "", fmt.Errorf("NewRequestWithContext from google metadata identity failed: %v", err)
+	}
+	req.Header.Add("Metadata-Flavor", "Google")
+	resp, err := s.Do(req)
+	if err != nil {
+		return "", &CredentialError{CodeIMDSUnreachable, "get google metadata identity token", err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &CredentialError{CodeIMDSUnreachable, "get google metadata identity token", fmt.Errorf("GET identity API call failed with status code: %d, body: %s", resp.StatusCode, string(body))}
+	}
+	// the identity endpoint answers with the bare JWT
+	return strings.TrimSpace(string(body)), nil
+}
+
 func getGoogleServiceAccountToken(s *service.Service, ctx context.Context,
 	google_service_account_email string) (string, error) {
 
-	token_url := strings.Replace(GOOGLE_SERVICE_ACCOUNT_IMPERSONATION_URL, "$serviceaccount", google_service_account_email, 1)
+	token_url := strings.Replace(service.EndpointURL(s.Endpoints.GoogleMetadata, GOOGLE_METADATA_ENDPOINT, GOOGLE_SERVICE_ACCOUNT_IMPERSONATION_PATH), "$serviceaccount", google_service_account_email, 1)
 	s.Logger.Info("token_url :" + token_url)
 
 	// Get service account token
@@ -74,9 +184,9 @@ func getGoogleServiceAccountToken(s *service.Service, ctx context.Context,
 	// Add header "Metadata-Flavor: Google"
 	req.Header.Add("Metadata-Flavor", "Google")
 	// Make the token impersonation request to get service account token
-	resp, err := s.Client.Do(req)
+	resp, err := s.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("Calling google get impersonation token failed: %v", err)
+		return "", &CredentialError{CodeIMDSUnreachable, "get google service account token", err}
 	}
 	defer resp.Body.Close()
 
@@ -87,7 +197,7 @@ func getGoogleServiceAccountToken(s *service.Service, ctx context.Context,
 	}
 	// Check if the status code is successful
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GET token API call failed with status code: %d, body: %s", resp.StatusCode, string(body))
+		return "", &CredentialError{CodeIMDSUnreachable, "get google service account token", fmt.Errorf("GET token API call failed with status code: %d, body: %s", resp.StatusCode, string(body))}
 	}
 	var tokenResult GoogleTokenResult
 	if err := json.Unmarshal(body, &tokenResult); err != nil {
@@ -100,7 +210,7 @@ func getGoogleServiceAccountToken(s *service.Service, ctx context.Context,
 func getServiceAccountOidcToken(s *service.Service, ctx context.Context,
 	token string, google_service_account_email string, audience string) (string, error) {
 	// get oidc token
-	oidcUrl := strings.Replace(GOOGLE_OIDC_TOKEN_URL, "$serviceaccount", google_service_account_email, 1)
+	oidcUrl := strings.Replace(service.EndpointURL(s.Endpoints.GoogleIAMCredentials, GOOGLE_IAM_CREDENTIALS_ENDPOINT, GOOGLE_OIDC_TOKEN_PATH), "$serviceaccount", google_service_account_email, 1)
 	s.Logger.Info("oidcUrl :" + oidcUrl)
 
 	requestData := GoogleOidcRequest{
@@ -112,7 +222,8 @@ func getServiceAccountOidcToken(s *service.Service, ctx context.Context,
 		return "", fmt.Errorf("error marshaling request: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", oidcUrl, bytes.NewBuffer(tokenRequestBody))
+	// generateIdToken has no side effect, retry it like a GET
+	req, err := http.NewRequestWithContext(service.WithReplay(ctx, true), "POST", oidcUrl, bytes.NewBuffer(tokenRequestBody))
 	if err != nil {
 		return "", fmt.Errorf("error creating request: %v", err)
 	}
@@ -121,7 +232,7 @@ func getServiceAccountOidcToken(s *service.Service, ctx context.Context,
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
 
-	oidcRequestResponse, err := s.Client.Do(req)
+	oidcRequestResponse, err := s.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error sending google oidc token request: %v", err)
 	}
@@ -152,7 +263,7 @@ func getServiceAccountOidcToken(s *service.Service, ctx context.Context,
 
 func CheckIfGoogle(s *service.Service, ctx context.Context) (bool, error) {
 	s.Logger.Info("Checking if cloud provider is Google")
-	req, err := http.NewRequestWithContext(ctx, "GET", GOOGLE_METADATA_URL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", service.EndpointURL(s.Endpoints.GoogleMetadata, GOOGLE_METADATA_ENDPOINT, GOOGLE_METADATA_PATH), nil)
 	if err != nil {
 		return false, fmt.Errorf("Error creating request to check if cloud provider is Google: %v", err)
 	}
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	service "jfrog-credential-provider/internal"
+	signer "jfrog-credential-provider/internal/sign"
 	"jfrog-credential-provider/internal/utils"
 	"net/http"
 )
@@ -26,26 +27,64 @@ import (
 const (
 	AWS_TOKEN_ENDPOINT = "/access/api/v1/aws/token"
 	OIDC_ENDPOINT      = "/access/api/v1/oidc/token"
+	TOKENS_ENDPOINT    = "/access/api/v1/tokens"
 )
 
 // AccessResponse JFrog token response
 type AwsRoleAccessResponse struct {
-	TokenId     string `json:"token_id"`
-	AccessToken string `json:"access_token"`
-	Scope       string `json:"scope"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
-	Username    string `json:"username"`
+	TokenId      string `json:"token_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Username     string `json:"username"`
 }
 
 // AccessResponse JFrog token response
 type OidcAccessResponse struct {
 	AccessToken     string `json:"access_token"`
+	RefreshToken    string `json:"refresh_token"`
 	TokenType       string `json:"token_type"`
 	ExpiresIn       int    `json:"expires_in"`
 	IssuedTokenType string `json:"issued_token_type"`
 	Username        string `json:"username"`
 }
+
+// AccessResponse JFrog token response
+type RefreshAccessResponse struct {
+	TokenId      string `json:"token_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshTokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+}
+
+// ArtifactoryToken is the result of an Artifactory token exchange.
+type ArtifactoryToken struct {
+	Username    string
+	AccessToken string
+	// RefreshToken is set when Artifactory issued a refreshable token
+	RefreshToken string
+	// ExpiresIn is the token lifetime in seconds as reported by Artifactory, 0 if not reported
+	ExpiresIn int
+}
+
+// TokenOptions narrows the Artifactory token requested by an exchange.
+type TokenOptions struct {
+	// Scope is the requested token scope, e.g. applied-permissions/groups:readers; empty requests the default scope
+	Scope string
+	// Refreshable is sent as-is when set; nil leaves the Artifactory default
+	Refreshable *bool
+}
+
 type OidcTokenRequest struct {
 	GrantType        string `json:"grant_type"`
 	ProviderName     string `json:"provider_name"`
@@ -53,10 +92,18 @@ type OidcTokenRequest struct {
 	SubjectToken     string `json:"subject_token"`
 	ProviderType     string `json:"provider_type"`
 	Audience         string `json:"audience"`
+	Scope            string `json:"scope,omitempty"`
+	Refreshable      *bool  `json:"refreshable,omitempty"`
+}
+
+type AwsTokenRequest struct {
+	ExpiresIn   json.Number `json:"expires_in"`
+	Scope       string      `json:"scope,omitempty"`
+	Refreshable *bool       `json:"refreshable,omitempty"`
 }
 
 func ExchangeOidcArtifactoryToken(s *service.Service, ctx context.Context,
-	token string, artifactoryUrl string, providerName string, audience string) (string, string, error) {
+	token string, artifactoryUrl string, providerName string, audience string, options TokenOptions) (ArtifactoryToken, error) {
 	url := fmt.Sprintf("%s%s%s", "https://", artifactoryUrl, OIDC_ENDPOINT)
 	s.Logger.Info("RT oidc token url :" + url)
 
@@ -67,46 +114,90 @@ func ExchangeOidcArtifactoryToken(s *service.Service, ctx context.Context,
 		SubjectToken:     token,
 		ProviderType:     "Generic OpenID Connect",
 		Audience:         audience,
+		Scope:            options.Scope,
+		Refreshable:      options.Refreshable,
 	}
 	body, err := json.Marshal(requestData)
 	if err != nil {
-		return "", "", fmt.Errorf("error marshaling request: %v", err)
+		return ArtifactoryToken{}, fmt.Errorf("error marshaling request: %v", err)
 	}
 
-	resp, err := utils.HttpReq(s, ctx, url, body, nil)
+	// an exchange that reached Artifactory may have issued a token, never resend it
+	resp, err := utils.HttpReq(s, service.WithReplay(ctx, false), url, body, nil)
 	if err != nil {
-		return "", "", fmt.Errorf("error calling oidc token api: %v", err)
+		return ArtifactoryToken{}, &CredentialError{CodeRTExchangeFailed, "exchange oidc token with artifactory", err}
 	}
 	myResponse := &OidcAccessResponse{}
 	err = json.NewDecoder(resp.Body).Decode(myResponse)
 	if err != nil {
-		return "", "", fmt.Errorf("error reading artifactory response")
+		return ArtifactoryToken{}, fmt.Errorf("error reading artifactory response")
 	}
 	resp.Body.Close()
-	return myResponse.Username, myResponse.AccessToken, nil
+	return ArtifactoryToken{Username: myResponse.Username, AccessToken: myResponse.AccessToken, RefreshToken: myResponse.RefreshToken, ExpiresIn: myResponse.ExpiresIn}, nil
 }
 
-func ExchangeAssumedRoleArtifactoryToken(s *service.Service, ctx context.Context, request *http.Request, artifactoryUrl string, secretTTL string) (string, string, error) {
+func ExchangeAssumedRoleArtifactoryToken(s *service.Service, ctx context.Context, request *http.Request, artifactoryUrl string, secretTTL string, options TokenOptions) (ArtifactoryToken, error) {
 	url := fmt.Sprintf("%s%s%s", "https://", artifactoryUrl, AWS_TOKEN_ENDPOINT)
 	if request != nil {
-		if region := request.Header.Get("X-Amz-Region-Set"); region != "" && region != "*" {
+		if region := signer.SigningRegion(request.Header); region != "*" {
 			url += "?region=" + region
 		}
 	}
 	s.Logger.Info("RT token url: " + url)
-	requestBody := fmt.Sprintf("%s%s%s", "{\"expires_in\": ", secretTTL, "}")
-	s.Logger.Info("RT requestBody: " + requestBody)
-	body := []byte(requestBody)
+	body, err := json.Marshal(AwsTokenRequest{
+		ExpiresIn:   json.Number(secretTTL),
+		Scope:       options.Scope,
+		Refreshable: options.Refreshable,
+	})
+	if err != nil {
+		return ArtifactoryToken{}, fmt.Errorf("error marshaling request: %v", err)
+	}
+	s.Logger.Info("RT requestBody: " + string(body))
 
-	resp, err := utils.HttpReq(s, ctx, url, body, request)
+	resp, err := utils.HttpReq(s, service.WithReplay(ctx, false), url, body, request)
 	if err != nil {
-		return "", "", err
+		return ArtifactoryToken{}, &CredentialError{CodeRTExchangeFailed, "exchange aws identity with artifactory", err}
 	}
 	myResponse := &AwsRoleAccessResponse{}
 	err = json.NewDecoder(resp.Body).Decode(myResponse)
 	if err != nil {
-		return "", "", fmt.Errorf("Error reading artifactory response")
+		return ArtifactoryToken{}, fmt.Errorf("Error reading artifactory response")
 	}
 	resp.Body.Close() // Close the response body to prevent resource leaks
-	return myResponse.Username, myResponse.AccessToken, nil
+	return ArtifactoryToken{Username: myResponse.Username, AccessToken: myResponse.AccessToken, RefreshToken: myResponse.RefreshToken, ExpiresIn: myResponse.ExpiresIn}, nil
+}
+
+// RefreshArtifactoryToken renews an access token with the refresh token grant.
+// Refresh tokens are single use, the returned token carries the new one.
+func RefreshArtifactoryToken(s *service.Service, ctx context.Context, artifactoryUrl string, accessToken string, refreshToken string) (ArtifactoryToken, error) {
+	url := fmt.Sprintf("%s%s%s", "https://", artifactoryUrl, TOKENS_ENDPOINT)
+	s.Logger.Info("RT refresh token url: " + url)
+
+	body, err := json.Marshal(RefreshTokenRequest{
+		GrantType:    "refresh_token",
+		RefreshToken: refreshToken,
+		AccessToken:  accessToken,
+	})
+	if err != nil {
+		return ArtifactoryToken{}, fmt.Errorf("error marshaling request: %v", err)
+	}
+
+	// the refresh token is spent once the request reaches Artifactory, a
+	// resend after a lost response would fail with it
+	resp, err := utils.HttpReq(s, service.WithReplay(ctx, false), url, body, nil)
+	if err != nil {
+		return ArtifactoryToken{}, &CredentialError{CodeRTExchangeFailed, "refresh artifactory token", err}
+	}
+	defer resp.Body.Close()
+	myResponse := &RefreshAccessResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(myResponse); err != nil {
+		return ArtifactoryToken{}, fmt.Errorf("error reading artifactory response")
+	}
+	// the refresh response carries no username, it is part of the token subject
+	return ArtifactoryToken{
+		Username:     TokenClaimsFromJWT(myResponse.AccessToken).Username(),
+		AccessToken:  myResponse.AccessToken,
+		RefreshToken: myResponse.RefreshToken,
+		ExpiresIn:    myResponse.ExpiresIn,
+	}, nil
 }
@@ -0,0 +1,93 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/logger"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestService(client *http.Client) *service.Service {
+	return service.NewService(client, logger.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+}
+
+func TestExchangeAssumedRoleArtifactoryTokenSendsScope(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != AWS_TOKEN_ENDPOINT {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"access_token":"rt-token","username":"rt-user","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	refreshable := false
+	token, err := ExchangeAssumedRoleArtifactoryToken(newTestService(server.Client()), context.Background(), nil,
+		strings.TrimPrefix(server.URL, "https://"), "3600",
+		TokenOptions{Scope: "applied-permissions/groups:readers", Refreshable: &refreshable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.Username != "rt-user" || token.AccessToken != "rt-token" || token.ExpiresIn != 3600 {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	if got["expires_in"] != float64(3600) {
+		t.Fatalf("expected expires_in 3600, got %v", got["expires_in"])
+	}
+	if got["scope"] != "applied-permissions/groups:readers" {
+		t.Fatalf("expected scope to be sent, got %v", got["scope"])
+	}
+	if got["refreshable"] != false {
+		t.Fatalf("expected refreshable=false to be sent, got %v", got["refreshable"])
+	}
+}
+
+func TestExchangeOidcArtifactoryTokenOmitsEmptyScope(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"access_token":"rt-token","username":"rt-user","expires_in":600}`))
+	}))
+	defer server.Close()
+
+	token, err := ExchangeOidcArtifactoryToken(newTestService(server.Client()), context.Background(), "id-token",
+		strings.TrimPrefix(server.URL, "https://"), "oidc-provider", "*@*", TokenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ExpiresIn != 600 {
+		t.Fatalf("expected expires_in 600, got %d", token.ExpiresIn)
+	}
+	if _, ok := got["scope"]; ok {
+		t.Fatal("expected scope to be omitted")
+	}
+	if _, ok := got["refreshable"]; ok {
+		t.Fatal("expected refreshable to be omitted")
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const staticStateFileName = "static-token-state.json"
+
+// StaticCredentials points at an Artifactory access token, a refresh token,
+// or both, mounted on the node (e.g. from a secret). Only the paths are
+// configured so the tokens never appear in the kubelet config. Without an
+// access token file the first access token comes from the refresh grant, and
+// Username must be set.
+type StaticCredentials struct {
+	AccessTokenFile  string
+	RefreshTokenFile string
+	// Username overrides the username taken from the access token subject
+	Username string
+	// StateDir keeps the token pair rotated by the last refresh, since the
+	// refresh token in the mounted file is consumed by its first use
+	StateDir string
+}
+
+// staticTokenState is the token pair rotated by the last refresh, tied to
+// the refresh token file content it was derived from.
+type staticTokenState struct {
+	SourceHash   string `json:"source_hash"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenClaims are the unverified JWT claims the provider needs to read from
+// tokens it already trusts (Artifactory access tokens, projected service
+// account tokens).
+type TokenClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenClaimsFromJWT decodes the claims of token without verifying it, and
+// returns empty claims if token is not a JWT.
+func TokenClaimsFromJWT(token string) TokenClaims {
+	var claims TokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims
+	}
+	json.Unmarshal(payload, &claims)
+	return claims
+}
+
+// Username returns the Artifactory user of an access token subject,
+// e.g. jfac@01h2.../users/ci-puller -> ci-puller.
+func (c TokenClaims) Username() string {
+	if _, user, ok := strings.Cut(c.Subject, "/users/"); ok {
+		return user
+	}
+	return ""
+}
+
+// ExpiresIn returns the remaining token lifetime in seconds, 0 if unknown.
+func (c TokenClaims) ExpiresIn() int {
+	if c.ExpiresAt == 0 {
+		return 0
+	}
+	remaining := time.Until(time.Unix(c.ExpiresAt, 0))
+	if remaining <= 0 {
+		return 0
+	}
+	return int(remaining.Seconds())
+}
+
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", &CredentialError{CodeTokenFileInvalid, "read token file " + path, err}
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", &CredentialError{CodeTokenFileInvalid, "read token file " + path, fmt.Errorf("file is empty")}
+	}
+	return token, nil
+}
+
+// GetStaticArtifactoryToken returns the mounted access token, or when a
+// refresh token file is configured, a token renewed with the refresh grant.
+func GetStaticArtifactoryToken(s *service.Service, ctx context.Context, artifactoryUrl string, creds StaticCredentials) (ArtifactoryToken, error) {
+	if creds.AccessTokenFile == "" && (creds.RefreshTokenFile == "" || creds.Username == "") {
+		return ArtifactoryToken{}, &CredentialError{CodeTokenFileInvalid, "read static token", fmt.Errorf("set jfrog_access_token_file, or jfrog_refresh_token_file and artifactory_user")}
+	}
+	var accessToken string
+	if creds.AccessTokenFile != "" {
+		var err error
+		if accessToken, err = readTokenFile(creds.AccessTokenFile); err != nil {
+			return ArtifactoryToken{}, err
+		}
+	}
+
+	var token ArtifactoryToken
+	var err error
+	if creds.RefreshTokenFile == "" {
+		s.Logger.Info("Using static access token from " + creds.AccessTokenFile)
+		claims := TokenClaimsFromJWT(accessToken)
+		token = ArtifactoryToken{Username: claims.Username(), AccessToken: accessToken, ExpiresIn: claims.ExpiresIn()}
+	} else {
+		token, err = refreshStaticToken(s, ctx, artifactoryUrl, creds, accessToken)
+		if err != nil {
+			return ArtifactoryToken{}, err
+		}
+	}
+
+	if creds.Username != "" {
+		token.Username = creds.Username
+	}
+	if token.Username == "" {
+		return ArtifactoryToken{}, &CredentialError{CodeTokenFileInvalid, "read static token", fmt.Errorf("could not determine the Artifactory username from the access token, set artifactory_user")}
+	}
+	return token, nil
+}
+
+func refreshStaticToken(s *service.Service, ctx context.Context, artifactoryUrl string, creds StaticCredentials, accessToken string) (ArtifactoryToken, error) {
+	refreshToken, err := readTokenFile(creds.RefreshTokenFile)
+	if err != nil {
+		return ArtifactoryToken{}, err
+	}
+	sum := sha256.Sum256([]byte(refreshToken))
+	sourceHash := hex.EncodeToString(sum[:])
+
+	if err := os.MkdirAll(creds.StateDir, 0700); err != nil {
+		return ArtifactoryToken{}, &CredentialError{CodeTokenFileInvalid, "create static token state dir", err}
+	}
+	// the state file is replaced by a rename, so the lock is on a file of its own
+	statePath := filepath.Join(creds.StateDir, staticStateFileName)
+	lockFile, err := os.OpenFile(statePath+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return ArtifactoryToken{}, &CredentialError{CodeTokenFileInvalid, "open static token state lock", err}
+	}
+	defer lockFile.Close()
+	if err := utils.GetLock(&s.Logger, lockFile, syscall.LOCK_EX); err != nil {
+		return ArtifactoryToken{}, &CredentialError{CodeInternal, "lock static token state", err}
+	}
+	defer utils.ReleaseLock(&s.Logger, lockFile)
+
+	// prefer the pair rotated by the last refresh, unless the mounted secret
+	// has been replaced since
+	var state staticTokenState
+	if data, err := os.ReadFile(statePath); err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			s.Logger.Info("Static token state is unreadable, using the mounted tokens: " + err.Error())
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		s.Logger.Info("Static token state is unreadable, using the mounted tokens: " + err.Error())
+	}
+
+	var token ArtifactoryToken
+	refreshed := false
+	if state.SourceHash == sourceHash && state.RefreshToken != "" {
+		s.Logger.Info("Refreshing with the token pair rotated by the last refresh")
+		token, err = RefreshArtifactoryToken(s, ctx, artifactoryUrl, state.AccessToken, state.RefreshToken)
+		if err == nil {
+			refreshed = true
+		} else {
+			// a corrupt or revoked state must not break the node for good
+			s.Logger.Info("Refreshing with the rotated token pair failed, retrying with the mounted tokens: " + err.Error())
+		}
+	}
+	if !refreshed {
+		s.Logger.Info("Refreshing with the mounted token pair from " + creds.RefreshTokenFile)
+		token, err = RefreshArtifactoryToken(s, ctx, artifactoryUrl, accessToken, refreshToken)
+		if err != nil {
+			return ArtifactoryToken{}, err
+		}
+	}
+
+	// the refresh token used above is spent, the new one must be on disk
+	// before the access token is handed out
+	if err := writeStaticTokenState(statePath, staticTokenState{SourceHash: sourceHash, AccessToken: token.AccessToken, RefreshToken: token.RefreshToken}); err != nil {
+		s.Logger.Error("The rotated refresh token could not be saved, replace the secret mounted at " + creds.RefreshTokenFile + " if the next refresh fails")
+		return ArtifactoryToken{}, &CredentialError{CodeTokenFileInvalid, "save rotated refresh token", err}
+	}
+	return token, nil
+}
+
+// writeStaticTokenState replaces the state file with state, through a temp
+// file renamed over it so a failed write never leaves a truncated state.
+func writeStaticTokenState(path string, state staticTokenState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal static token state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), staticStateFileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create static token state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set static token state permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write static token state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync static token state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write static token state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace static token state: %w", err)
+	}
+	return nil
+}
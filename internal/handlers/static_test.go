@@ -0,0 +1,239 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testJWT(subject string, expiresAt time.Time) string {
+	payload, _ := json.Marshal(TokenClaims{Subject: subject, ExpiresAt: expiresAt.Unix()})
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func writeTokenFile(t *testing.T, dir, name, token string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetStaticArtifactoryTokenWithoutRefresh(t *testing.T) {
+	dir := t.TempDir()
+	accessToken := testJWT("jfac@01h2/users/ci-puller", time.Now().Add(time.Hour))
+	creds := StaticCredentials{AccessTokenFile: writeTokenFile(t, dir, "access-token", accessToken)}
+
+	token, err := GetStaticArtifactoryToken(newTestService(http.DefaultClient), context.Background(), "unused", creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Username != "ci-puller" || token.AccessToken != accessToken {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	if token.ExpiresIn <= 3500 || token.ExpiresIn > 3600 {
+		t.Fatalf("expected ~3600s lifetime, got %d", token.ExpiresIn)
+	}
+
+	creds.AccessTokenFile = writeTokenFile(t, dir, "opaque-token", "not-a-jwt")
+	if _, err := GetStaticArtifactoryToken(newTestService(http.DefaultClient), context.Background(), "unused", creds); err == nil {
+		t.Fatal("expected error when the username cannot be determined")
+	}
+	creds.Username = "ci-puller"
+	if _, err := GetStaticArtifactoryToken(newTestService(http.DefaultClient), context.Background(), "unused", creds); err != nil {
+		t.Fatalf("expected artifactory_user to be used, got %v", err)
+	}
+}
+
+func TestGetStaticArtifactoryTokenRotatesRefreshToken(t *testing.T) {
+	calls := 0
+	var usedRefreshTokens []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != TOKENS_ENDPOINT {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req RefreshTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if req.GrantType != "refresh_token" {
+			t.Errorf("unexpected grant type %s", req.GrantType)
+		}
+		usedRefreshTokens = append(usedRefreshTokens, req.RefreshToken)
+		calls++
+		fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"refresh-%d","expires_in":3600}`,
+			testJWT("jfac@01h2/users/ci-puller", time.Now().Add(time.Hour)), calls)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	creds := StaticCredentials{
+		AccessTokenFile:  writeTokenFile(t, dir, "access-token", "mounted-access"),
+		RefreshTokenFile: writeTokenFile(t, dir, "refresh-token", "mounted-refresh"),
+		StateDir:         filepath.Join(dir, "state"),
+	}
+	svc := newTestService(server.Client())
+	artifactoryUrl := strings.TrimPrefix(server.URL, "https://")
+
+	for i := 0; i < 2; i++ {
+		token, err := GetStaticArtifactoryToken(svc, context.Background(), artifactoryUrl, creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Username != "ci-puller" || token.ExpiresIn != 3600 {
+			t.Fatalf("unexpected token %+v", token)
+		}
+	}
+	// a replaced secret wins over the rotated state
+	writeTokenFile(t, dir, "refresh-token", "replaced-refresh")
+	if _, err := GetStaticArtifactoryToken(svc, context.Background(), artifactoryUrl, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"mounted-refresh", "refresh-1", "replaced-refresh"}
+	if strings.Join(usedRefreshTokens, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected refresh tokens %v, got %v", want, usedRefreshTokens)
+	}
+	info, err := os.Stat(filepath.Join(creds.StateDir, staticStateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected state file mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestGetStaticArtifactoryTokenFailsWhenTheRotatedTokenIsNotSaved(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"rotated-refresh","expires_in":3600}`,
+			testJWT("jfac@01h2/users/ci-puller", time.Now().Add(time.Hour)))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	creds := StaticCredentials{
+		AccessTokenFile:  writeTokenFile(t, dir, "access-token", "mounted-access"),
+		RefreshTokenFile: writeTokenFile(t, dir, "refresh-token", "mounted-refresh"),
+		StateDir:         filepath.Join(dir, "state"),
+	}
+	// a directory in place of the state file makes the rename fail
+	if err := os.MkdirAll(filepath.Join(creds.StateDir, staticStateFileName, "blocked"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := GetStaticArtifactoryToken(newTestService(server.Client()), context.Background(), strings.TrimPrefix(server.URL, "https://"), creds)
+	if code := ErrorCodeOf(err); code != CodeTokenFileInvalid {
+		t.Fatalf("expected %s, got token %+v, %v", CodeTokenFileInvalid, token, err)
+	}
+	if token.AccessToken != "" {
+		t.Fatal("expected no access token to be handed out")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(creds.StateDir, "*.tmp")); len(matches) > 0 {
+		t.Fatalf("expected the temp state file to be removed, got %v", matches)
+	}
+}
+
+func TestGetStaticArtifactoryTokenWithOnlyARefreshToken(t *testing.T) {
+	var requests []RefreshTokenRequest
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req RefreshTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+		fmt.Fprintf(w, `{"access_token":"opaque-access-%d","refresh_token":"refresh-%d","expires_in":3600}`, len(requests), len(requests))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	creds := StaticCredentials{
+		RefreshTokenFile: writeTokenFile(t, dir, "refresh-token", "mounted-refresh"),
+		StateDir:         filepath.Join(dir, "state"),
+	}
+	svc := newTestService(server.Client())
+	artifactoryUrl := strings.TrimPrefix(server.URL, "https://")
+
+	if _, err := GetStaticArtifactoryToken(svc, context.Background(), artifactoryUrl, creds); err == nil {
+		t.Fatal("expected error without artifactory_user")
+	}
+	creds.Username = "ci-puller"
+	token, err := GetStaticArtifactoryToken(svc, context.Background(), artifactoryUrl, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Username != "ci-puller" || token.AccessToken != "opaque-access-1" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	if len(requests) != 1 || requests[0].RefreshToken != "mounted-refresh" || requests[0].AccessToken != "" {
+		t.Fatalf("expected one refresh grant with only the mounted refresh token, got %+v", requests)
+	}
+}
+
+func TestGetStaticArtifactoryTokenFallsBackToTheMountedTokens(t *testing.T) {
+	var usedRefreshTokens []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req RefreshTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		usedRefreshTokens = append(usedRefreshTokens, req.RefreshToken)
+		if req.RefreshToken != "mounted-refresh" {
+			http.Error(w, `{"errors":[{"message":"invalid refresh token"}]}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"rotated-refresh","expires_in":3600}`,
+			testJWT("jfac@01h2/users/ci-puller", time.Now().Add(time.Hour)))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	creds := StaticCredentials{
+		AccessTokenFile:  writeTokenFile(t, dir, "access-token", "mounted-access"),
+		RefreshTokenFile: writeTokenFile(t, dir, "refresh-token", "mounted-refresh"),
+		StateDir:         filepath.Join(dir, "state"),
+	}
+	if err := os.MkdirAll(creds.StateDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("mounted-refresh"))
+	corrupt := staticTokenState{SourceHash: hex.EncodeToString(sum[:]), AccessToken: "garbage", RefreshToken: "garbage"}
+	if err := writeStaticTokenState(filepath.Join(creds.StateDir, staticStateFileName), corrupt); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := GetStaticArtifactoryToken(newTestService(server.Client()), context.Background(), strings.TrimPrefix(server.URL, "https://"), creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Username != "ci-puller" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	want := []string{"garbage", "mounted-refresh"}
+	if strings.Join(usedRefreshTokens, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected refresh tokens %v, got %v", want, usedRefreshTokens)
+	}
+}
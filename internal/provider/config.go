@@ -19,11 +19,13 @@ import (
 	"encoding/json"
 	"fmt"
 	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
 	"jfrog-credential-provider/internal/logger"
 	"jfrog-credential-provider/internal/utils"
 	"log"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 
@@ -46,11 +48,12 @@ type EnvVar struct {
 }
 
 type ProviderConfig struct {
-	Name                 string   `json:"name"`
-	MatchImages          []string `json:"matchImages"`
-	DefaultCacheDuration string   `json:"defaultCacheDuration"`
-	APIVersion           string   `json:"apiVersion"`
-	Env                  []EnvVar `json:"env"`
+	Name                 string                 `json:"name"`
+	MatchImages          []string               `json:"matchImages"`
+	DefaultCacheDuration string                 `json:"defaultCacheDuration"`
+	APIVersion           string                 `json:"apiVersion"`
+	TokenAttributes      *utils.TokenAttributes `json:"tokenAttributes,omitempty"`
+	Env                  []EnvVar               `json:"env"`
 }
 
 func ProcessProviderConfigEnvs(providerHome string, providerConfigFileName string) (string, string) {
@@ -110,6 +113,25 @@ func configContainsJfrogProvider(configPath string, isYaml bool) (bool, error) {
 	return false, nil
 }
 
+// configuredCloudProvider returns the cloud_provider env of the JFrog provider
+// config file, or "" if the file can't be read or does not set it.
+func configuredCloudProvider(jfrogConfigPath string, isYaml bool) string {
+	data, err := os.ReadFile(jfrogConfigPath)
+	if err != nil {
+		return ""
+	}
+	var provider utils.Provider
+	if isYaml {
+		err = yaml.Unmarshal(data, &provider)
+	} else {
+		err = json.Unmarshal(data, &provider)
+	}
+	if err != nil {
+		return ""
+	}
+	return utils.GetEnvVarValue(provider.Env, "cloud_provider")
+}
+
 // BackupConfig is config-aware: it reads the kubelet credential provider config,
 // checks whether the JFrog provider already exists, and decides which backup to create:
 //   - JFrog NOT in config (first install) --> saves to <config>.backup
@@ -152,6 +174,43 @@ func CreateProviderConfigFromEnv(isYaml bool, providerHome string, providerConfi
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 
+	providerConfig, err := providerConfigFromEnv()
+	if err != nil {
+		logs.Exit(err.Error(), 1)
+	}
+
+	// Marshal the config to JSON
+	data, err := json.MarshalIndent(providerConfig, "", "  ")
+	if err != nil {
+		logs.Exit(fmt.Sprintf("failed to marshal provider config: %v", err), 1)
+	}
+
+	var jfrogConfigFileName string
+	if isYaml {
+		jfrogConfigFileName = providerHome + providerConfigFileName + ".yaml"
+	} else {
+		jfrogConfigFileName = providerHome + providerConfigFileName + ".json"
+	}
+	// Write the JSON to the output file
+	if err := os.WriteFile(jfrogConfigFileName, data, 0644); err != nil {
+		logs.Exit(fmt.Sprintf("failed to write provider config to file: %v", err), 1)
+	}
+
+	logs.Info(fmt.Sprintf("Provider config written to %s\n", jfrogConfigFileName))
+
+}
+
+// ambientEnv lists the provider env also set in the node setup env by the
+// AWS SDK, EKS or the runtime for reasons of their own. A generated config
+// never copies them so they are not baked into the kubelet config.
+var ambientEnv = []string{
+	"aws_web_identity_token_file", "aws_container_credentials_full_uri",
+	"aws_container_authorization_token_file", "log_level",
+}
+
+// providerConfigFromEnv builds the JFrog provider config from the upper case
+// env of the node setup, checked against the schema of the selected source.
+func providerConfigFromEnv() (ProviderConfig, error) {
 	envVars := []EnvVar{}
 	addEnvVar := func(name, value string) {
 		if value != "" {
@@ -159,21 +218,12 @@ func CreateProviderConfigFromEnv(isYaml bool, providerHome string, providerConfi
 		}
 	}
 
-	addEnvVar("artifactory_url", os.Getenv("ARTIFACTORY_URL"))
-	addEnvVar("artifactory_user", os.Getenv("ARTIFACTORY_USER"))
-	addEnvVar("aws_auth_method", os.Getenv("AWS_AUTH_METHOD"))
-	addEnvVar("aws_region", os.Getenv("AWS_REGION"))
-	addEnvVar("aws_role_name", os.Getenv("AWS_ROLE_NAME"))
-	addEnvVar("aws_external_role_arn", os.Getenv("AWS_EXTERNAL_ROLE_ARN"))
-	addEnvVar("aws_external_role_session_duration_seconds", os.Getenv("AWS_EXTERNAL_ROLE_SESSION_DURATION_SECONDS"))
-	addEnvVar("secret_name", os.Getenv("SECRET_NAME"))
-	addEnvVar("secret_ttl_seconds", os.Getenv("SECRET_TTL_SECONDS"))
-	addEnvVar("jfrog_oidc_provider_name", os.Getenv("JFROG_OIDC_PROVIDER_NAME"))
-	addEnvVar("user_pool_name", os.Getenv("USER_POOL_NAME"))
-	addEnvVar("user_pool_resource_scope", os.Getenv("USER_POOL_RESOURCE_SCOPE"))
-	addEnvVar("resource_server_name", os.Getenv("RESOURCE_SERVER_NAME"))
-	addEnvVar("google_service_account_email", os.Getenv("GOOGLE_SERVICE_ACCOUNT_EMAIL"))
-	addEnvVar("jfrog_oidc_audience", os.Getenv("JFROG_OIDC_AUDIENCE"))
+	// each provider env var is read from its upper case form
+	for _, name := range utils.ProviderEnvNames() {
+		if !slices.Contains(ambientEnv, name) {
+			addEnvVar(name, os.Getenv(strings.ToUpper(name)))
+		}
+	}
 
 	// Read MatchImages and DefaultCacheDuration from environment variables
 	matchImages := os.Getenv("MATCH_IMAGES")
@@ -185,61 +235,65 @@ func CreateProviderConfigFromEnv(isYaml bool, providerHome string, providerConfi
 		defaultCacheDuration = "4h" // Default value
 	}
 
-	// Validate conditions
+	// IAM_ROLE_ARN and ARTIFACTORY_USER are inputs of the node setup rather
+	// than provider env read by a credential source
+	cloudProvider := os.Getenv("CLOUD_PROVIDER")
 	authMethod := os.Getenv("AWS_AUTH_METHOD")
-	if authMethod == "assume_role" || authMethod == "" {
+	if (cloudProvider == "" || cloudProvider == utils.CloudProviderAWS) && (authMethod == "assume_role" || authMethod == "") {
 		iamRoleArn := os.Getenv("IAM_ROLE_ARN")
 		if iamRoleArn == "" {
-			logs.Exit("if authentication_method is 'assume_role', then 'IAM_ROLE_ARN' must be provided and be a non-empty string", 1)
+			return ProviderConfig{}, fmt.Errorf("if authentication_method is 'assume_role', then 'IAM_ROLE_ARN' must be provided and be a non-empty string")
 		}
 	}
-	if authMethod == "assume_external_role" && os.Getenv("AWS_EXTERNAL_ROLE_ARN") == "" {
-		logs.Exit("if authentication_method is 'assume_external_role', then 'AWS_EXTERNAL_ROLE_ARN' must be provided and be a non-empty string", 1)
-	}
-	if authMethod == "cognito_oidc" {
-		requiredVars := map[string]string{
-			"JFROG_OIDC_PROVIDER_NAME": os.Getenv("JFROG_OIDC_PROVIDER_NAME"),
-			"SECRET_NAME":              os.Getenv("SECRET_NAME"),
-			"USER_POOL_NAME":           os.Getenv("USER_POOL_NAME"),
-			"RESOURCE_SERVER_NAME":     os.Getenv("RESOURCE_SERVER_NAME"),
-			"USER_POOL_RESOURCE_SCOPE": os.Getenv("USER_POOL_RESOURCE_SCOPE"),
-			"ARTIFACTORY_USER":         os.Getenv("ARTIFACTORY_USER"),
+	if authMethod == "cognito_oidc" && os.Getenv("ARTIFACTORY_USER") == "" {
+		return ProviderConfig{}, fmt.Errorf("if authentication_method is 'cognito_oidc', then 'ARTIFACTORY_USER' must be provided and be a non-empty string")
+	}
+
+	// the env of the selected credential source is checked against its
+	// schema; without cloud_provider the source is only known once the
+	// config is merged on the node
+	schemaProvider := cloudProvider
+	if schemaProvider == "" && authMethod != "" {
+		schemaProvider = utils.CloudProviderAWS
+	}
+	var tokenAttributes *utils.TokenAttributes
+	if schema, ok := utils.GetConfigSchema(schemaProvider); ok {
+		method := ""
+		if schema.MethodEnv != "" {
+			method = os.Getenv(strings.ToUpper(schema.MethodEnv))
 		}
-		for key, value := range requiredVars {
-			if value == "" {
-				logs.Exit(fmt.Sprintf("if authentication_method is 'cognito_oidc', then '%s' must be provided and be a non-empty string", key), 1)
+		// flows exchanging the pod's own token need the kubelet to send it
+		if template, ok := schema.ServiceAccountToken[method]; ok {
+			audience := os.Getenv("SERVICE_ACCOUNT_TOKEN_AUDIENCE")
+			if audience == "" {
+				return ProviderConfig{}, fmt.Errorf("%s requires 'SERVICE_ACCOUNT_TOKEN_AUDIENCE' to be provided and be a non-empty string", strings.Trim(schemaProvider+" "+method, " "))
+			}
+			tokenAttributes = &utils.TokenAttributes{
+				ServiceAccountTokenAudience:          audience,
+				CacheType:                            "ServiceAccount",
+				RequireServiceAccount:                true,
+				RequiredServiceAccountAnnotationKeys: template.RequiredServiceAccountAnnotationKeys,
+				OptionalServiceAccountAnnotationKeys: template.OptionalServiceAccountAnnotationKeys,
 			}
 		}
+
+		env := make([]utils.EnvVar, 0, len(envVars))
+		for _, v := range envVars {
+			env = append(env, utils.EnvVar{Name: v.Name, Value: v.Value})
+		}
+		if err := schema.ValidateEnv(utils.Provider{Env: env, TokenAttributes: tokenAttributes}); err != nil {
+			return ProviderConfig{}, fmt.Errorf("invalid %s provider config: %v", schemaProvider, err)
+		}
 	}
 
-	// Create the provider config
-	providerConfig := ProviderConfig{
+	return ProviderConfig{
 		Name:                 "jfrog-credential-provider",
 		MatchImages:          []string{matchImages},
 		DefaultCacheDuration: defaultCacheDuration,
 		APIVersion:           "credentialprovider.kubelet.k8s.io/v1",
+		TokenAttributes:      tokenAttributes,
 		Env:                  envVars,
-	}
-
-	// Marshal the config to JSON
-	data, err := json.MarshalIndent(providerConfig, "", "  ")
-	if err != nil {
-		logs.Exit(fmt.Sprintf("failed to marshal provider config: %v", err), 1)
-	}
-
-	var jfrogConfigFileName string
-	if isYaml {
-		jfrogConfigFileName = providerHome + providerConfigFileName + ".yaml"
-	} else {
-		jfrogConfigFileName = providerHome + providerConfigFileName + ".json"
-	}
-	// Write the JSON to the output file
-	if err := os.WriteFile(jfrogConfigFileName, data, 0644); err != nil {
-		logs.Exit(fmt.Sprintf("failed to write provider config to file: %v", err), 1)
-	}
-
-	logs.Info(fmt.Sprintf("Provider config written to %s\n", jfrogConfigFileName))
-
+	}, nil
 }
 
 func MergeConfig(dryRun, isYaml bool, providerHome string, providerConfigFileName string) {
@@ -257,8 +311,19 @@ func MergeConfig(dryRun, isYaml bool, providerHome string, providerConfigFileNam
 	}
 	client := newProviderHTTPClient(60 * time.Second)
 	svc := service.NewService(client, *logs)
+	if svc.Endpoints, err = endpointsFromEnv(logs); err != nil {
+		logs.Exit(err, handlers.ErrorCodeOf(err).ExitCode())
+	}
 	ctx := context.Background()
-	cloudProvider := getCloudProvider(svc, ctx, logs)
+	// an explicit cloud_provider in the JFrog provider config skips detection,
+	// clusters without a cloud metadata server would otherwise fail here
+	cloudProvider := configuredCloudProvider(jfrogConfigFileName, isYaml)
+	if cloudProvider == "" {
+		cloudProvider, err = getCloudProvider(svc, ctx, logs)
+		if err != nil {
+			logs.Exit(err, handlers.ErrorCodeOf(err).ExitCode())
+		}
+	}
 
 	// Before merge, backup the current config (config-aware: picks .backup or .jfrog)
 	if err := BackupConfig(isYaml, providerHome, providerConfigFileName, false, logs); err != nil {
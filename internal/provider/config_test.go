@@ -0,0 +1,125 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"jfrog-credential-provider/internal/utils"
+	"slices"
+	"testing"
+)
+
+// validateGenerated checks a generated config the way the merge on the node does.
+func validateGenerated(t *testing.T, config ProviderConfig) {
+	t.Helper()
+	provider := utils.Provider{
+		Name:                 config.Name,
+		MatchImages:          config.MatchImages,
+		DefaultCacheDuration: config.DefaultCacheDuration,
+		TokenAttributes:      config.TokenAttributes,
+	}
+	for _, env := range config.Env {
+		provider.Env = append(provider.Env, utils.EnvVar{Name: env.Name, Value: env.Value})
+	}
+	if err := utils.ValidateJfrogProviderConfig(provider, ""); err != nil {
+		t.Fatalf("expected the generated config to be valid, got %v", err)
+	}
+}
+
+func TestGenerateConfigAzureWorkloadIdentityExchange(t *testing.T) {
+	t.Setenv("ARTIFACTORY_URL", "example.jfrog.io")
+	t.Setenv("CLOUD_PROVIDER", utils.CloudProviderAzure)
+	t.Setenv("AZURE_AUTH_METHOD", "workload_identity_exchange")
+	t.Setenv("JFROG_OIDC_PROVIDER_NAME", "entra-oidc")
+	t.Setenv("SERVICE_ACCOUNT_TOKEN_AUDIENCE", "")
+
+	if _, err := providerConfigFromEnv(); err == nil {
+		t.Fatal("expected an error without SERVICE_ACCOUNT_TOKEN_AUDIENCE")
+	}
+
+	t.Setenv("SERVICE_ACCOUNT_TOKEN_AUDIENCE", "api://AzureADTokenExchange")
+	config, err := providerConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TokenAttributes == nil || config.TokenAttributes.ServiceAccountTokenAudience != "api://AzureADTokenExchange" ||
+		!slices.Contains(config.TokenAttributes.RequiredServiceAccountAnnotationKeys, utils.AzureWorkloadIdentityClientIDAnnotation) {
+		t.Fatalf("unexpected tokenAttributes %+v", config.TokenAttributes)
+	}
+	validateGenerated(t, config)
+}
+
+func TestGenerateConfigGoogleWorkloadIdentityFederation(t *testing.T) {
+	t.Setenv("ARTIFACTORY_URL", "example.jfrog.io")
+	t.Setenv("CLOUD_PROVIDER", utils.CloudProviderGoogle)
+	t.Setenv("GOOGLE_AUTH_METHOD", "workload_identity_federation")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_AUDIENCE", "identitynamespace:project.svc.id.goog:https://container.googleapis.com/v1/projects/project/locations/us-central1/clusters/jfrog")
+	t.Setenv("JFROG_OIDC_PROVIDER_NAME", "google-oidc")
+	t.Setenv("JFROG_OIDC_AUDIENCE", "jfrog")
+	t.Setenv("SERVICE_ACCOUNT_TOKEN_AUDIENCE", "project.svc.id.goog")
+
+	config, err := providerConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TokenAttributes == nil || config.TokenAttributes.ServiceAccountTokenAudience != "project.svc.id.goog" ||
+		!slices.Contains(config.TokenAttributes.RequiredServiceAccountAnnotationKeys, utils.GoogleServiceAccountAnnotation) {
+		t.Fatalf("unexpected tokenAttributes %+v", config.TokenAttributes)
+	}
+	validateGenerated(t, config)
+
+	// the node's own identity needs no pod token
+	t.Setenv("GOOGLE_AUTH_METHOD", "metadata_identity")
+	config, err = providerConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TokenAttributes != nil {
+		t.Fatalf("expected no tokenAttributes for metadata_identity, got %+v", config.TokenAttributes)
+	}
+}
+
+func TestGenerateConfigSkipsAmbientEnv(t *testing.T) {
+	t.Setenv("ARTIFACTORY_URL", "example.jfrog.io")
+	t.Setenv("CLOUD_PROVIDER", utils.CloudProviderAWS)
+	t.Setenv("AWS_AUTH_METHOD", "web_identity_token_file")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/secrets/eks.amazonaws.com/serviceaccount/token")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "http://169.254.170.23/v1/credentials")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("HTTP_RETRY_MAX_ATTEMPTS", "5")
+	t.Setenv("AUTOUPDATE_CHANNEL", "patch")
+
+	config, err := providerConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range config.Env {
+		switch env.Name {
+		case "aws_web_identity_token_file", "aws_container_credentials_full_uri", "log_level":
+			t.Fatalf("expected ambient %s not to be generated, got %+v", env.Name, config.Env)
+		}
+	}
+	for _, want := range []EnvVar{
+		{Name: "aws_auth_method", Value: "web_identity_token_file"},
+		{Name: "aws_region", Value: "us-east-1"},
+		{Name: "http_retry_max_attempts", Value: "5"},
+		{Name: "autoupdate_channel", Value: "patch"},
+	} {
+		if !slices.Contains(config.Env, want) {
+			t.Fatalf("expected %s to be generated, got %+v", want.Name, config.Env)
+		}
+	}
+	validateGenerated(t, config)
+}
@@ -0,0 +1,321 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jfrog-credential-provider/internal/cache"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDaemonSocket          = "/run/jfrog-credentials-provider/provider.sock"
+	daemonCredentialsPath        = "/v1/credentials"
+	defaultDaemonRequestTimeout  = 10 * time.Second
+	daemonRefreshInterval        = time.Minute
+	daemonTrackedRequestIdleTime = 24 * time.Hour
+	daemonMaxTrackedRequests     = 256
+)
+
+var errDaemonNotConfigured = errors.New("credential_daemon_socket is not set")
+
+// daemonRequest is what the exec plugin sends to the credential daemon.
+type daemonRequest struct {
+	Request utils.CredentialProviderRequest `json:"request"`
+	// ConfigFingerprint must match the daemon's, so the daemon never answers
+	// for a provider config it was not started with
+	ConfigFingerprint string `json:"config_fingerprint"`
+}
+
+// configFingerprint hashes the provider settings, the env names declared by
+// the credential source schemas and the common ones. Any other env (the
+// kubelet's own, e.g. http_proxy) is left out, as are the settings of the
+// plugin's side of the socket: the daemon may get the socket path from the
+// -socket flag instead.
+func configFingerprint() string {
+	var env []string
+	for _, name := range utils.ProviderEnvNames() {
+		if name == "credential_daemon_socket" || name == "credential_daemon_timeout_seconds" {
+			continue
+		}
+		if value := os.Getenv(name); value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+	return cache.Key(env...)
+}
+
+// requestFromDaemon asks the credential daemon listening on
+// credential_daemon_socket to resolve the request. A *handlers.CredentialError
+// means the daemon ran the credential flow and it failed; any other error
+// means the caller should resolve the credentials itself.
+func requestFromDaemon(ctx context.Context, logs *logger.Logger, request utils.CredentialProviderRequest) (utils.CredentialProviderResponse, error) {
+	var response utils.CredentialProviderResponse
+	socket := os.Getenv("credential_daemon_socket")
+	if socket == "" {
+		return response, errDaemonNotConfigured
+	}
+	timeout := defaultDaemonRequestTimeout
+	if v := os.Getenv("credential_daemon_timeout_seconds"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			timeout = time.Duration(n) * time.Second
+		} else {
+			logs.Info("bad value for credential_daemon_timeout_seconds, defaulting to " + timeout.String())
+		}
+	}
+
+	body, err := json.Marshal(daemonRequest{Request: request, ConfigFingerprint: configFingerprint()})
+	if err != nil {
+		return response, fmt.Errorf("error marshaling daemon request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://daemon"+daemonCredentialsPath, bytes.NewReader(body))
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if line, ok := handlers.ParseErrorLine(string(msg)); ok {
+			return response, line.Err("credential daemon")
+		}
+		return response, fmt.Errorf("daemon responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response, fmt.Errorf("error reading daemon response: %w", err)
+	}
+	logs.Info("Credentials served by the credential daemon")
+	return response, nil
+}
+
+// trackedRequest is a request the daemon keeps refreshing before its token expires.
+type trackedRequest struct {
+	request   utils.CredentialProviderRequest
+	expiresAt time.Time
+	lastUsed  time.Time
+}
+
+type daemon struct {
+	logs           *logger.Logger
+	client         *http.Client
+	fingerprint    string
+	requestTimeout time.Duration
+
+	mu      sync.Mutex
+	tracked map[string]*trackedRequest
+}
+
+// Serve runs the credential daemon on a unix socket until ctx is done. It
+// answers the exec plugin with the same flow as direct mode, reusing one
+// HTTP client across requests, and refreshes the tokens of recently served
+// requests before they expire so pulls rarely wait for an exchange.
+func Serve(ctx context.Context, logs *logger.Logger, socket string, requestTimeout time.Duration) error {
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return fmt.Errorf("failed to create socket dir: %w", err)
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("a credential daemon is already listening on %s", socket)
+	}
+	// a socket left behind by a daemon that did not shut down cleanly
+	os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	defer os.Remove(socket)
+	if err := os.Chmod(socket, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	d := &daemon{
+		logs:           logs,
+		client:         newProviderHTTPClient(defaultHTTPTimeout),
+		fingerprint:    configFingerprint(),
+		requestTimeout: requestTimeout,
+		tracked:        map[string]*trackedRequest{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+daemonCredentialsPath, d.handleCredentials)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go d.prefetch(ctx)
+	go d.refreshLoop(ctx)
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	logs.Info("Credential daemon listening on " + socket)
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logs.Info("Credential daemon stopped")
+	return nil
+}
+
+func (d *daemon) handleCredentials(w http.ResponseWriter, r *http.Request) {
+	var req daemonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ConfigFingerprint != d.fingerprint {
+		d.logs.Info("Rejecting request, the provider config differs from the daemon's")
+		http.Error(w, "provider config differs from the daemon's", http.StatusConflict)
+		return
+	}
+
+	response, expiresAt, err := d.resolve(r.Context(), req.Request)
+	if err != nil {
+		// the shim reports the daemon's error line as its own
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(handlers.NewErrorLine(err))
+		return
+	}
+	d.track(req.Request, response, expiresAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// resolve runs resolveCredentials for one request.
+func (d *daemon) resolve(ctx context.Context, request utils.CredentialProviderRequest) (utils.CredentialProviderResponse, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+	logs := &logger.Logger{Logger: d.logs.Logger.With("image", request.Image)}
+	return resolveCredentials(ctx, logs, d.client, request)
+}
+
+// trackingKey groups requests that resolve to the same token: the registry
+// keys answered, the pod service account and its annotations.
+func trackingKey(request utils.CredentialProviderRequest, response utils.CredentialProviderResponse) string {
+	keys := make([]string, 0, len(response.Auth.Registry))
+	for key := range response.Auth.Registry {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	// fmt prints maps with sorted keys
+	return cache.Key(strings.Join(keys, ","), handlers.TokenClaimsFromJWT(request.ServiceAccountToken).Subject, fmt.Sprint(request.ServiceAccountAnnotations))
+}
+
+func (d *daemon) track(request utils.CredentialProviderRequest, response utils.CredentialProviderResponse, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		return
+	}
+	key := trackingKey(request, response)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.tracked[key]; !ok && len(d.tracked) >= daemonMaxTrackedRequests {
+		return
+	}
+	d.tracked[key] = &trackedRequest{request: request, expiresAt: expiresAt, lastUsed: time.Now()}
+}
+
+// prefetch warms the token cache at startup for flows that do not depend on
+// a pod service account token.
+func (d *daemon) prefetch(ctx context.Context) {
+	artifactoryUrl := os.Getenv("artifactory_url")
+	if artifactoryUrl == "" || os.Getenv("cloud_provider") == utils.CloudProviderKubernetes {
+		return
+	}
+	request := utils.CredentialProviderRequest{Image: artifactoryUrl}
+	response, expiresAt, err := d.resolve(ctx, request)
+	if err != nil {
+		d.logs.Error("Credential daemon prefetch failed: " + err.Error())
+		return
+	}
+	d.logs.Info("Credential daemon prefetched a token for " + artifactoryUrl)
+	d.track(request, response, expiresAt)
+}
+
+func (d *daemon) refreshLoop(ctx context.Context) {
+	if utils.GetEnvsBool(d.logs, "disable_token_cache", false) {
+		d.logs.Info("Token cache is disabled, the credential daemon will not refresh tokens ahead of expiry")
+		return
+	}
+	ticker := time.NewTicker(daemonRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.refreshExpiring(ctx)
+		}
+	}
+}
+
+// refreshExpiring re-resolves tracked requests whose token entered the cache
+// safety margin, so the token cache holds a fresh token before kubelet asks
+// again. Requests that fail (e.g. an expired service account token) or have
+// been idle for a day are dropped.
+func (d *daemon) refreshExpiring(ctx context.Context) {
+	margin := tokenCacheSafetyMargin(d.logs)
+	d.mu.Lock()
+	due := map[string]*trackedRequest{}
+	for key, tracked := range d.tracked {
+		if time.Since(tracked.lastUsed) > daemonTrackedRequestIdleTime {
+			delete(d.tracked, key)
+		} else if time.Until(tracked.expiresAt) <= margin {
+			due[key] = tracked
+		}
+	}
+	d.mu.Unlock()
+
+	for key, tracked := range due {
+		_, expiresAt, err := d.resolve(ctx, tracked.request)
+		d.mu.Lock()
+		if err != nil || expiresAt.IsZero() {
+			d.logs.Info("Credential daemon stopped refreshing a token for " + tracked.request.Image)
+			delete(d.tracked, key)
+		} else {
+			tracked.expiresAt = expiresAt
+		}
+		d.mu.Unlock()
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestDaemon runs the daemon with static credentials, which resolve
+// without any network access.
+func startTestDaemon(t *testing.T) string {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "access-token")
+	if err := os.WriteFile(tokenFile, []byte("static-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "provider.sock")
+	t.Setenv("artifactory_url", "example.jfrog.io")
+	t.Setenv("cloud_provider", "static")
+	t.Setenv("jfrog_access_token_file", tokenFile)
+	t.Setenv("artifactory_user", "ci-puller")
+	t.Setenv("token_cache_dir", filepath.Join(dir, "cache"))
+	t.Setenv("credential_daemon_socket", socket)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, testLogger(), socket, 5*time.Second) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			return tokenFile
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("daemon did not start listening")
+	return ""
+}
+
+func TestDaemonServesCredentials(t *testing.T) {
+	tokenFile := startTestDaemon(t)
+	request := utils.CredentialProviderRequest{Image: "example.jfrog.io/docker/nginx:latest"}
+
+	response, err := requestFromDaemon(context.Background(), testLogger(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, ok := response.Auth.Registry["example.jfrog.io"]
+	if !ok || auth.Username != "ci-puller" || auth.Password != "static-token" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	// a failing flow ends the request, not the daemon
+	if err := os.Remove(tokenFile); err != nil {
+		t.Fatal(err)
+	}
+	_, err = requestFromDaemon(context.Background(), testLogger(), request)
+	if code := handlers.ErrorCodeOf(err); code != handlers.CodeTokenFileInvalid {
+		t.Fatalf("expected the daemon to report %s, got %v", handlers.CodeTokenFileInvalid, err)
+	}
+	if err := os.WriteFile(tokenFile, []byte("static-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := requestFromDaemon(context.Background(), testLogger(), request); err != nil {
+		t.Fatalf("expected daemon to keep serving, got %v", err)
+	}
+}
+
+func TestDaemonRejectsDifferentConfig(t *testing.T) {
+	startTestDaemon(t)
+	t.Setenv("jfrog_token_scope", "applied-permissions/groups:readers")
+
+	_, err := requestFromDaemon(context.Background(), testLogger(), utils.CredentialProviderRequest{Image: "example.jfrog.io/nginx"})
+	if err == nil || !strings.Contains(err.Error(), "409") {
+		t.Fatalf("expected config mismatch to be rejected, got %v", err)
+	}
+}
+
+func TestDaemonIgnoresTheKubeletEnv(t *testing.T) {
+	startTestDaemon(t)
+	// the kubelet passes its own env to the plugin, not to the daemon
+	t.Setenv("http_proxy", "http://proxy.internal:3128")
+	t.Setenv("no_proxy", "169.254.169.254,.svc")
+
+	if _, err := requestFromDaemon(context.Background(), testLogger(), utils.CredentialProviderRequest{Image: "example.jfrog.io/nginx"}); err != nil {
+		t.Fatalf("expected env outside of the provider settings not to change the fingerprint, got %v", err)
+	}
+}
+
+func TestRequestFromDaemonNotConfigured(t *testing.T) {
+	t.Setenv("credential_daemon_socket", "")
+	if _, err := requestFromDaemon(context.Background(), testLogger(), utils.CredentialProviderRequest{}); !errors.Is(err, errDaemonNotConfigured) {
+		t.Fatalf("expected errDaemonNotConfigured, got %v", err)
+	}
+}
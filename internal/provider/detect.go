@@ -0,0 +1,154 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/cache"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDetectionProbeTimeout bounds each cloud metadata probe, a metadata
+	// server answers within milliseconds on its own cloud
+	defaultDetectionProbeTimeout = 2 * time.Second
+	// detectedCloudProviderFileName is written next to the binary so the
+	// detection runs once per node, not on every image pull
+	detectedCloudProviderFileName = "jfrog-credentials-provider.cloud"
+)
+
+type probeResult struct {
+	name     string
+	detected bool
+	err      error
+}
+
+// detectCloudProvider probes the metadata service of every detectable
+// credential source concurrently, each with its own timeout. It fails with
+// CodeCloudAmbiguous when more than one answers and with CodeIMDSUnreachable
+// when none could be queried, and returns "" when none is detected.
+func detectCloudProvider(svc *service.Service, ctx context.Context, logs *logger.Logger, timeout time.Duration) (string, error) {
+	// a probe failing fast is the answer on other clouds, don't retry it
+	probe := *svc
+	probe.Retry.MaxAttempts = 1
+
+	results := make(chan probeResult)
+	probes := 0
+	for _, name := range credentialSourceNames() {
+		source, _ := newCredentialSource(name)
+		probes++
+		go func() {
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			detected, err := source.Detect(&probe, probeCtx)
+			results <- probeResult{name: name, detected: detected, err: err}
+		}()
+	}
+
+	var probed, detected []string
+	var errs []error
+	for range probes {
+		result := <-results
+		if errors.Is(result.err, errNotDetectable) {
+			continue
+		}
+		probed = append(probed, result.name)
+		if result.err != nil {
+			logs.Debug("cloud provider probe " + result.name + " failed: " + result.err.Error())
+			errs = append(errs, result.err)
+		} else if result.detected {
+			detected = append(detected, result.name)
+		}
+	}
+	slices.Sort(probed)
+	slices.Sort(detected)
+
+	switch {
+	case len(detected) > 1:
+		return "", handlers.NewCredentialError(handlers.CodeCloudAmbiguous, "detect cloud provider", fmt.Errorf("the metadata services of %s all answered, set cloud_provider", strings.Join(detected, ", ")))
+	case len(detected) == 1:
+		logs.Info("detected cloud provider: " + detected[0])
+		return detected[0], nil
+	case len(errs) == len(probed):
+		return "", handlers.NewCredentialError(handlers.CodeIMDSUnreachable, "detect cloud provider", fmt.Errorf("could not check if cloud provider is %s, set cloud_provider: %w", strings.Join(probed, ", "), errors.Join(errs...)))
+	}
+	return "", nil
+}
+
+// detectionProbeTimeout reads cloud_detection_timeout_ms.
+func detectionProbeTimeout(logs *logger.Logger) time.Duration {
+	if n, ok := positiveIntEnv(logs, "cloud_detection_timeout_ms"); ok {
+		return time.Duration(n) * time.Millisecond
+	}
+	return defaultDetectionProbeTimeout
+}
+
+// detectedCloudProviderFile returns where the detected cloud provider is
+// persisted: cloud_detection_cache_file, next to the binary by default.
+func detectedCloudProviderFile(logs *logger.Logger) string {
+	if path := utils.GetEnvs(logs, "cloud_detection_cache_file", ""); path != "" {
+		return path
+	}
+	binaryPath, err := os.Executable()
+	if err != nil {
+		return filepath.Join(cache.DefaultCacheDir, detectedCloudProviderFileName)
+	}
+	return filepath.Join(filepath.Dir(binaryPath), detectedCloudProviderFileName)
+}
+
+// readDetectedCloudProvider returns the persisted cloud provider, "" when
+// there is none or it names a credential source no longer registered.
+func readDetectedCloudProvider(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	cloudProvider := strings.TrimSpace(string(data))
+	if _, ok := newCredentialSource(cloudProvider); !ok {
+		return ""
+	}
+	return cloudProvider
+}
+
+// writeDetectedCloudProvider persists cloudProvider atomically, concurrent
+// plugin runs may detect at the same time.
+func writeDetectedCloudProvider(path, cloudProvider string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(cloudProvider + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
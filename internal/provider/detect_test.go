@@ -0,0 +1,105 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/fakemetadata"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func fakeMetadataService(metadata *fakemetadata.Server) *service.Service {
+	svc := service.NewService(metadata.Client(), *testLogger())
+	svc.Endpoints = service.Endpoints{AWSIMDS: metadata.URL, AzureIMDS: metadata.URL, GoogleMetadata: metadata.URL}
+	return svc
+}
+
+func TestDetectCloudProviderAmbiguous(t *testing.T) {
+	metadata := fakemetadata.NewServer()
+	defer metadata.Close()
+	metadata.Azure.Enabled = false
+
+	_, err := detectCloudProvider(fakeMetadataService(metadata), context.Background(), testLogger(), time.Second)
+	if handlers.ErrorCodeOf(err) != handlers.CodeCloudAmbiguous {
+		t.Fatalf("expected %s, got %v", handlers.CodeCloudAmbiguous, err)
+	}
+}
+
+func TestDetectCloudProviderProbeTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	svc := service.NewService(slow.Client(), *testLogger())
+	svc.Endpoints = service.Endpoints{AWSIMDS: slow.URL, AzureIMDS: slow.URL, GoogleMetadata: slow.URL}
+
+	start := time.Now()
+	_, err := detectCloudProvider(svc, context.Background(), testLogger(), 50*time.Millisecond)
+	if handlers.ErrorCodeOf(err) != handlers.CodeIMDSUnreachable {
+		t.Fatalf("expected %s, got %v", handlers.CodeIMDSUnreachable, err)
+	}
+	// the probes run concurrently, so together they take about one timeout
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("detection took %s", elapsed)
+	}
+}
+
+func TestGetCloudProviderPersistsDetection(t *testing.T) {
+	metadata := fakemetadata.NewServer()
+	metadata.AWS.Enabled = false
+	metadata.Azure.Enabled = false
+	cacheFile := filepath.Join(t.TempDir(), detectedCloudProviderFileName)
+	t.Setenv("cloud_provider", "")
+	t.Setenv("cloud_detection_cache_file", cacheFile)
+
+	svc := fakeMetadataService(metadata)
+	cloudProvider, err := getCloudProvider(svc, context.Background(), testLogger())
+	if err != nil || cloudProvider != utils.CloudProviderGoogle {
+		t.Fatalf("expected google, got %q, %v", cloudProvider, err)
+	}
+	if data, _ := os.ReadFile(cacheFile); string(data) != utils.CloudProviderGoogle+"\n" {
+		t.Fatalf("unexpected cache file content %q", data)
+	}
+
+	// later invocations skip detection entirely
+	metadata.Close()
+	requests := len(metadata.Requests())
+	cloudProvider, err = getCloudProvider(svc, context.Background(), testLogger())
+	if err != nil || cloudProvider != utils.CloudProviderGoogle {
+		t.Fatalf("expected the persisted google, got %q, %v", cloudProvider, err)
+	}
+	if len(metadata.Requests()) != requests {
+		t.Fatal("expected no metadata probe once the cloud provider is persisted")
+	}
+
+	// a stale value naming no registered source is ignored
+	os.WriteFile(cacheFile, []byte("openstack\n"), 0644)
+	if readDetectedCloudProvider(cacheFile) != "" {
+		t.Fatal("expected an unknown persisted cloud provider to be ignored")
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/fakemetadata"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeArtifactory answers the AWS and OIDC token exchanges, accepting only
+// the identities the fake metadata server hands out.
+func fakeArtifactory(t *testing.T, metadata *fakemetadata.Server) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+handlers.AWS_TOKEN_ENDPOINT, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-ECDSA-P256-SHA256 Credential="+metadata.AWS.AccessKeyID+"/") {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(handlers.AwsRoleAccessResponse{AccessToken: "rt-aws-user-token", Username: "aws-user", ExpiresIn: 3600})
+	})
+	mux.HandleFunc("POST "+handlers.OIDC_ENDPOINT, func(w http.ResponseWriter, r *http.Request) {
+		var req handlers.OidcTokenRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		users := map[string]string{
+			metadata.Azure.AccessToken: "azure-user",
+			metadata.Google.IDToken:    "google-user",
+		}
+		user, ok := users[req.SubjectToken]
+		if !ok {
+			http.Error(w, "unknown subject token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(handlers.OidcAccessResponse{AccessToken: "rt-" + user + "-token", Username: user, ExpiresIn: 3600})
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	// the proxy env is read once per process, keep it out of these requests
+	transportHook = func(transport *http.Transport) {
+		transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+		transport.Proxy = nil
+	}
+	t.Cleanup(func() { transportHook = nil })
+	return server
+}
+
+// runStartProvider feeds request to StartProvider as kubelet would and
+// returns its response.
+func runStartProvider(t *testing.T, request utils.CredentialProviderRequest) utils.CredentialProviderResponse {
+	t.Helper()
+	if _, err := logger.NewLogger(); err != nil {
+		t.Skip("provider log file is not writable: " + err.Error())
+	}
+	t.Setenv("disable_provider_autoupdate", "true")
+	t.Setenv("disable_token_cache", "true")
+	t.Setenv("credential_daemon_socket", "")
+
+	stdinR, stdinW, _ := os.Pipe()
+	stdoutR, stdoutW, _ := os.Pipe()
+	origStdin, origStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinR, stdoutW
+	defer func() { os.Stdin, os.Stdout = origStdin, origStdout }()
+
+	json.NewEncoder(stdinW).Encode(request)
+	stdinW.Close()
+	StartProvider(context.Background(), "test")
+	stdoutW.Close()
+
+	out, _ := io.ReadAll(stdoutR)
+	var response utils.CredentialProviderResponse
+	if err := json.Unmarshal(out, &response); err != nil {
+		t.Fatalf("unreadable provider output %q: %v", out, err)
+	}
+	return response
+}
+
+func TestStartProviderOffline(t *testing.T) {
+	metadata := fakemetadata.NewServer()
+	defer metadata.Close()
+	artifactory := fakeArtifactory(t, metadata)
+	artifactoryHost := strings.TrimPrefix(artifactory.URL, "https://")
+	tokenDir := t.TempDir()
+	podIdentityTokenFile := filepath.Join(tokenDir, "eks-pod-identity-token")
+	webIdentityTokenFile := filepath.Join(tokenDir, "irsa-token")
+	os.WriteFile(podIdentityTokenFile, []byte(metadata.AWS.PodIdentityToken), 0600)
+	os.WriteFile(webIdentityTokenFile, []byte("projected-irsa-token"), 0600)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		user string
+	}{
+		{"aws assume_role", map[string]string{
+			"cloud_provider":    utils.CloudProviderAWS,
+			"aws_auth_method":   "assume_role",
+			"aws_role_name":     metadata.AWS.RoleName,
+			"aws_imds_endpoint": metadata.URL,
+		}, "aws-user"},
+		{"aws pod identity", map[string]string{
+			"cloud_provider":                         utils.CloudProviderAWS,
+			"aws_auth_method":                        "pod_identity",
+			"aws_region":                             metadata.AWS.Region,
+			"aws_container_credentials_full_uri":     metadata.URL + "/v1/credentials",
+			"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE": podIdentityTokenFile,
+		}, "aws-user"},
+		{"aws web identity token file", map[string]string{
+			"cloud_provider":              utils.CloudProviderAWS,
+			"aws_auth_method":             "web_identity_token_file",
+			"aws_region":                  metadata.AWS.Region,
+			"aws_web_identity_token_file": webIdentityTokenFile,
+			"AWS_ROLE_ARN":                "arn:aws:iam::123456789012:role/jfrog-pod-role",
+			"AWS_ENDPOINT_URL_STS":        metadata.URL,
+		}, "aws-user"},
+		{"azure imds_direct", map[string]string{
+			"cloud_provider":           utils.CloudProviderAzure,
+			"azure_auth_method":        "imds_direct",
+			"azure_app_client_id":      "00000000-0000-0000-0000-000000000001",
+			"azure_nodepool_client_id": "00000000-0000-0000-0000-000000000002",
+			"jfrog_oidc_provider_name": "azure-oidc",
+			"azure_imds_endpoint":      metadata.URL,
+		}, "azure-user"},
+		{"google node identity", map[string]string{
+			"cloud_provider":                 utils.CloudProviderGoogle,
+			"google_service_account_email":   metadata.Google.ServiceAccount,
+			"jfrog_oidc_audience":            "jfrog",
+			"jfrog_oidc_provider_name":       "google-oidc",
+			"google_metadata_endpoint":       metadata.URL,
+			"google_iamcredentials_endpoint": metadata.URL,
+		}, "google-user"},
+		{"google metadata identity", map[string]string{
+			"cloud_provider":           utils.CloudProviderGoogle,
+			"google_auth_method":       "metadata_identity",
+			"jfrog_oidc_audience":      "jfrog",
+			"jfrog_oidc_provider_name": "google-oidc",
+			"google_metadata_endpoint": metadata.URL,
+		}, "google-user"},
+		{"google workload identity federation", map[string]string{
+			"cloud_provider":                    utils.CloudProviderGoogle,
+			"google_auth_method":                "workload_identity_federation",
+			"google_workload_identity_audience": "identitynamespace:project.svc.id.goog:https://container.googleapis.com/v1/projects/project/locations/us-central1/clusters/jfrog",
+			"jfrog_oidc_audience":               "jfrog",
+			"jfrog_oidc_provider_name":          "google-oidc",
+			"google_sts_endpoint":               metadata.URL,
+			"google_iamcredentials_endpoint":    metadata.URL,
+		}, "google-user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("artifactory_url", artifactoryHost)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			response := runStartProvider(t, utils.CredentialProviderRequest{
+				Image:                     artifactoryHost + "/docker-local/nginx:latest",
+				ServiceAccountToken:       "projected-sa-token",
+				ServiceAccountAnnotations: map[string]string{utils.GoogleServiceAccountAnnotation: metadata.Google.ServiceAccount},
+			})
+			auth, ok := response.Auth.Registry[artifactoryHost]
+			if !ok || auth.Username != tt.user || auth.Password != "rt-"+tt.user+"-token" {
+				t.Fatalf("unexpected response %+v", response)
+			}
+		})
+	}
+}
+
+func TestStartProviderAssumeExternalRoleChain(t *testing.T) {
+	metadata := fakemetadata.NewServer()
+	defer metadata.Close()
+	artifactory := fakeArtifactory(t, metadata)
+	artifactoryHost := strings.TrimPrefix(artifactory.URL, "https://")
+	sharedServices := "arn:aws:iam::111111111111:role/shared-services"
+	artifactoryAccess := "arn:aws:iam::222222222222:role/artifactory-access"
+	metadata.AWS.ExternalIDs = map[string]string{sharedServices: "shared-ext-id", artifactoryAccess: "artifactory-ext-id"}
+
+	t.Setenv("artifactory_url", artifactoryHost)
+	t.Setenv("cloud_provider", utils.CloudProviderAWS)
+	t.Setenv("aws_auth_method", "assume_external_role")
+	t.Setenv("aws_region", metadata.AWS.Region)
+	t.Setenv("aws_imds_endpoint", metadata.URL)
+	t.Setenv("AWS_ENDPOINT_URL_STS", metadata.URL)
+	t.Setenv("aws_external_role_arn", `[
+		{"role_arn": "`+sharedServices+`", "external_id": "shared-ext-id"},
+		{"role_arn": "`+artifactoryAccess+`", "tags": {"cluster": "prod"}, "policy_arns": ["arn:aws:iam::aws:policy/ReadOnlyAccess"]}
+	]`)
+	t.Setenv("aws_external_role_external_id", "artifactory-ext-id")
+	t.Setenv("aws_cluster_name", "eks-prod")
+	t.Setenv("aws_external_role_session_tags", "cluster=$cluster,node=$node")
+	t.Setenv("aws_external_role_session_name", "jfrog-$cluster-$node")
+	t.Setenv("aws_external_role_source_identity", "jfrog-$cluster")
+	response := runStartProvider(t, utils.CredentialProviderRequest{Image: artifactoryHost + "/docker-local/nginx:latest"})
+	if auth, ok := response.Auth.Registry[artifactoryHost]; !ok || auth.Username != "aws-user" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	calls := metadata.AssumeRoleCalls()
+	if len(calls) != 2 || calls[0].Get("RoleArn") != sharedServices || calls[1].Get("RoleArn") != artifactoryAccess {
+		t.Fatalf("expected the roles to be assumed in order, got %v", calls)
+	}
+	if calls[1].Get("Tags.member.1.Key") != "cluster" || calls[1].Get("Tags.member.1.Value") != "prod" ||
+		calls[1].Get("PolicyArns.member.1.arn") != "arn:aws:iam::aws:policy/ReadOnlyAccess" {
+		t.Fatalf("expected the session tags and policies of the last hop, got %v", calls[1])
+	}
+	node, _ := os.Hostname()
+	for _, call := range calls {
+		if call.Get("RoleSessionName") != utils.AWSSessionName("jfrog-eks-prod-"+node) {
+			t.Fatalf("expected the templated role session name, got %q", call.Get("RoleSessionName"))
+		}
+	}
+	if calls[0].Get("SourceIdentity") != "jfrog-eks-prod" || calls[0].Get("Tags.member.2.Key") != "node" || calls[0].Get("Tags.member.2.Value") != node {
+		t.Fatalf("expected the source identity and the session tags on the first hop, got %v", calls[0])
+	}
+	if calls[1].Get("DurationSeconds") != "3600" {
+		t.Fatalf("expected the chained session to be capped at an hour, got %s", calls[1].Get("DurationSeconds"))
+	}
+}
+
+func TestCloudProviderDetectionWithFakeMetadata(t *testing.T) {
+	metadata := fakemetadata.NewServer()
+	defer metadata.Close()
+	metadata.Azure.Enabled = false
+	metadata.Google.Enabled = false
+	t.Setenv("cloud_provider", "")
+	t.Setenv("cloud_detection_cache_file", filepath.Join(t.TempDir(), detectedCloudProviderFileName))
+
+	svc := service.NewService(metadata.Client(), *testLogger())
+	svc.Endpoints = service.Endpoints{AWSIMDS: metadata.URL, AzureIMDS: metadata.URL, GoogleMetadata: metadata.URL}
+	cloudProvider, err := getCloudProvider(svc, context.Background(), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cloudProvider != utils.CloudProviderAWS {
+		t.Fatalf("expected aws, got %q", cloudProvider)
+	}
+}
+
+func TestEndpointsFromEnv(t *testing.T) {
+	t.Setenv("aws_imds_endpoint_mode", "ipv6")
+	endpoints, err := endpointsFromEnv(testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoints.AWSIMDS != handlers.AWS_IMDS_IPV6_ENDPOINT {
+		t.Fatalf("expected the IPv6 IMDS endpoint, got %q", endpoints.AWSIMDS)
+	}
+
+	t.Setenv("azure_imds_endpoint", "169.254.169.254")
+	if _, err := endpointsFromEnv(testLogger()); handlers.ErrorCodeOf(err) != handlers.CodeConfigInvalid {
+		t.Fatalf("expected %s for an endpoint without scheme, got %v", handlers.CodeConfigInvalid, err)
+	}
+}
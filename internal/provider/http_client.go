@@ -15,19 +15,102 @@
 package provider
 
 import (
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// transportHook adjusts the transport of newProviderHTTPClient when set,
+// tests use it to trust the certificate of a fake Artifactory.
+var transportHook func(*http.Transport)
+
 func newProviderHTTPClient(timeout time.Duration) *http.Client {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.MaxIdleConns = 100
 	transport.IdleConnTimeout = 10 * time.Second
 	transport.DisableCompression = true
 	transport.Proxy = http.ProxyFromEnvironment
+	if transportHook != nil {
+		transportHook(transport)
+	}
 
 	return &http.Client{
 		Timeout:   timeout,
 		Transport: transport,
 	}
 }
+
+// retryPolicyFromEnv reads the retry policy of metadata, STS, AAD and
+// Artifactory calls. Retries never outlive the http_timeout_seconds deadline
+// of the request, so these only bound how hard a flaky endpoint is tried.
+func retryPolicyFromEnv(logs *logger.Logger) service.RetryPolicy {
+	policy := service.DefaultRetryPolicy()
+	if n, ok := positiveIntEnv(logs, "http_retry_max_attempts"); ok {
+		policy.MaxAttempts = n
+	}
+	if n, ok := positiveIntEnv(logs, "http_retry_base_delay_ms"); ok {
+		policy.BaseDelay = time.Duration(n) * time.Millisecond
+	}
+	if n, ok := positiveIntEnv(logs, "http_retry_max_delay_ms"); ok {
+		policy.MaxDelay = time.Duration(n) * time.Millisecond
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	return policy
+}
+
+func positiveIntEnv(logs *logger.Logger, key string) (int, bool) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		logs.Info("bad value for " + key + ", using the default")
+		return 0, false
+	}
+	return n, true
+}
+
+// endpointsFromEnv reads the metadata service overrides of the provider env.
+// aws_imds_endpoint_mode=ipv6 selects the IPv6 IMDS endpoint, an explicit
+// aws_imds_endpoint wins over it.
+func endpointsFromEnv(logs *logger.Logger) (service.Endpoints, error) {
+	var endpoints service.Endpoints
+	switch mode := utils.GetEnvs(logs, "aws_imds_endpoint_mode", ""); strings.ToLower(mode) {
+	case "", "ipv4":
+	case "ipv6":
+		endpoints.AWSIMDS = handlers.AWS_IMDS_IPV6_ENDPOINT
+	default:
+		return endpoints, handlers.ConfigError(handlers.CodeConfigInvalid, "aws_imds_endpoint_mode must be ipv4 or ipv6, got: %s", mode)
+	}
+	for _, override := range []struct {
+		env    string
+		target *string
+	}{
+		{"aws_imds_endpoint", &endpoints.AWSIMDS},
+		{"azure_imds_endpoint", &endpoints.AzureIMDS},
+		{"google_metadata_endpoint", &endpoints.GoogleMetadata},
+		{"google_iamcredentials_endpoint", &endpoints.GoogleIAMCredentials},
+		{"google_sts_endpoint", &endpoints.GoogleSTS},
+	} {
+		value := utils.GetEnvs(logs, override.env, "")
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return endpoints, handlers.ConfigError(handlers.CodeConfigInvalid, "%s must be an http(s) URL, got: %s", override.env, value)
+		}
+		logs.Info("Using " + override.env + ": " + value)
+		*override.target = value
+	}
+	return endpoints, nil
+}
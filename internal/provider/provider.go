@@ -17,13 +17,16 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	service "jfrog-credential-provider/internal"
 	"jfrog-credential-provider/internal/autoupdate"
+	"jfrog-credential-provider/internal/fips"
 	"jfrog-credential-provider/internal/handlers"
 	"jfrog-credential-provider/internal/logger"
 	"jfrog-credential-provider/internal/utils"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -34,32 +37,40 @@ import (
 const (
 	defaultSecretTTL   = "18000" // 5 hours
 	defaultHTTPTimeout = 10 * time.Second
-	logFileLocation    = "/var/log/jfrog-credentials-provider/jfrog-credentials-provider.log"
-	logPrefix          = "[JFROG CREDENTIALS PROVIDER] "
+	// defaultCacheDurationSkew is subtracted from the token lifetime reported to kubelet
+	defaultCacheDurationSkew = 60 * time.Second
+	logFileLocation          = "/var/log/jfrog-credentials-provider/jfrog-credentials-provider.log"
+	logPrefix                = "[JFROG CREDENTIALS PROVIDER] "
 )
 
-func extractRepository(imagePath string) string {
-	if imagePath == "" {
-		return ""
+// resolveImageScope reads the registry key settings from the provider env
+// and resolves them against the requested image.
+func resolveImageScope(logs *logger.Logger, image string) (utils.ImageScope, string, error) {
+	prefixScopes, err := utils.ParseImagePrefixScopes(utils.GetEnvs(logs, "image_prefix_scopes", ""))
+	if err != nil {
+		return utils.ImageScope{}, "", handlers.NewCredentialError(handlers.CodeConfigInvalid, "parse image_prefix_scopes", err)
 	}
-	parts := strings.Split(imagePath, "/")
-	if len(parts) == 0 {
-		return ""
+	cacheKeyType, err := utils.ResolveCacheKeyType(utils.GetEnvs(logs, "cache_key_type", ""), prefixScopes)
+	if err != nil {
+		return utils.ImageScope{}, "", handlers.NewCredentialError(handlers.CodeConfigInvalid, "resolve cache_key_type", err)
 	}
-	return parts[0]
+	var additionalKeys []string
+	if v := utils.GetEnvs(logs, "additional_registry_keys", ""); v != "" {
+		additionalKeys = strings.Split(v, ",")
+	}
+
+	imageScope := utils.ResolveImageScope(image, prefixScopes, additionalKeys)
+	if imageScope.Prefix != "" {
+		logs.Info("Image matched path prefix " + imageScope.Prefix + ", using scope: " + imageScope.Scope)
+	}
+	return imageScope, cacheKeyType, nil
 }
 
 func StartProvider(ctx context.Context, Version string) {
 	logs, request := initializeLoggerAndParseRequest()
-	artifactoryUrl := validateRTRequiredEnvVariables(logs)
-
-	secretTTL := os.Getenv("secret_ttl_seconds")
-	if secretTTL == "" {
-		secretTTL = defaultSecretTTL
-	}
+	logs.Info("JFrog credential provider version " + Version + ", " + fips.Mode())
 
 	client := newProviderHTTPClient(defaultHTTPTimeout)
-	svc := service.NewService(client, *logs)
 	// wait group for autoupdate goroutine
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -69,317 +80,211 @@ func StartProvider(ctx context.Context, Version string) {
 		autoupdate.AutoUpdate(request, logs, client, ctx, Version)
 	}()
 
-	rtUsername, rtToken := cloudProviderAuth(svc, ctx, logs, artifactoryUrl, secretTTL, request)
-	// rtUsername, rtToken := handleAWSAuth(svc, ctx, logs, awsAuthMethod, awsRoleName, artifactoryUrl, secretTTL)
-	logs.Info("JFrog Username used for pull :" + rtUsername)
-
-	generateAndOutputResponse(logs, request, rtUsername, rtToken)
+	response, err := requestFromDaemon(ctx, logs, request)
+	var flowErr *handlers.CredentialError
+	if errors.As(err, &flowErr) {
+		// the daemon ran the credential flow and it failed, running it again
+		// here would fail the same way
+		exitWithError(logs, err)
+	} else if err != nil {
+		if !errors.Is(err, errDaemonNotConfigured) {
+			logs.Info("Credential daemon unavailable, resolving credentials directly: " + err.Error())
+		}
+		response, _, err = resolveCredentials(ctx, logs, client, request)
+		if err != nil {
+			exitWithError(logs, err)
+		}
+	}
+	outputResponse(logs, response)
 	// wait until autoupdate is finished before terminating main process
 	wg.Wait()
 }
 
-func initializeLoggerAndParseRequest() (*logger.Logger, utils.CredentialProviderRequest) {
-	logs, err := logger.NewLogger()
+// resolveCredentials runs the whole credential flow for one kubelet request
+// and returns the response along with the token expiry (zero if unknown).
+func resolveCredentials(ctx context.Context, logs *logger.Logger, client *http.Client, request utils.CredentialProviderRequest) (utils.CredentialProviderResponse, time.Time, error) {
+	artifactoryUrl, err := validateRTRequiredEnvVariables(logs)
 	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
+		return utils.CredentialProviderResponse{}, time.Time{}, err
 	}
-	logs.Info("Running JFrog Credentials provider...")
-
-	var request utils.CredentialProviderRequest
-	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
-		logs.Exit("Error reading stdin :"+err.Error(), 1)
+	imageScope, cacheKeyType, err := resolveImageScope(logs, request.Image)
+	if err != nil {
+		return utils.CredentialProviderResponse{}, time.Time{}, err
 	}
-	logs.Info("request.Image :" + request.Image)
-
-	return logs, request
-}
-
-func getCloudProvider(svc *service.Service, ctx context.Context, logs *logger.Logger) string {
-	cloudProvider := utils.GetEnvs(logs, "cloud_provider", "")
-	logs.Info("cloud_provider from env:" + cloudProvider)
-	if cloudProvider == "" {
-		// if cloud_provider is not set, check if the cloud provider is AWS, Azure, or Google
-		isAWS, errAWS := handlers.CheckIfAWS(svc, ctx)
-		if isAWS {
-			cloudProvider = utils.CloudProviderAWS
-		}
-		isAzure, errAzure := handlers.CheckIfAzure(svc, ctx)
-		if isAzure {
-			cloudProvider = utils.CloudProviderAzure
-		}
-		isGoogle, errGoogle := handlers.CheckIfGoogle(svc, ctx)
-		if isGoogle {
-			cloudProvider = utils.CloudProviderGoogle
-		}
-
-		if errAWS != nil && errAzure != nil && errGoogle != nil {
-			logs.Exit("ERROR in JFrog Credentials provider, could not check if cloud provider is AWS, Azure, or Google", 1)
-		}
+	tokenOptions, err := resolveTokenOptions(logs, imageScope)
+	if err != nil {
+		return utils.CredentialProviderResponse{}, time.Time{}, err
 	}
-	return cloudProvider
-}
 
-func cloudProviderAuth(svc *service.Service, ctx context.Context, logs *logger.Logger, artifactoryUrl, secretTTL string, request utils.CredentialProviderRequest) (string, string) {
-	var rtUsername, rtToken string
-
-	cloudProvider := getCloudProvider(svc, ctx, logs)
+	secretTTL := os.Getenv("secret_ttl_seconds")
+	if secretTTL == "" {
+		secretTTL = defaultSecretTTL
+	}
 
-	switch cloudProvider {
-	case utils.CloudProviderAWS:
-		logs.Debug("Detected AWS cloud provider")
-		awsEnvVariables := validateAWSEnvVariables(logs, request)
-		rtUsername, rtToken = handleAWSAuth(svc, ctx, logs, awsEnvVariables, artifactoryUrl, secretTTL, request)
-		return rtUsername, rtToken
-	case utils.CloudProviderAzure:
-		logs.Debug("Detected Azure cloud provider")
-		rtUsername, rtToken = handleAzureAuth(svc, ctx, logs, artifactoryUrl, request)
-		return rtUsername, rtToken
-	case utils.CloudProviderGoogle:
-		logs.Debug("Detected Google cloud provider")
-		rtUsername, rtToken = handleGoogleAuth(svc, ctx, logs, artifactoryUrl, request)
-		return rtUsername, rtToken
-	default:
-		logs.Exit("ERROR in JFrog Credentials provider, cloud_provider value should be either aws, azure, or google", 1)
+	svc := service.NewService(client, *logs)
+	svc.Retry = retryPolicyFromEnv(logs)
+	if svc.Endpoints, err = endpointsFromEnv(logs); err != nil {
+		return utils.CredentialProviderResponse{}, time.Time{}, err
 	}
-	return rtUsername, rtToken
+	entry, err := cachedCloudProviderAuth(svc, ctx, logs, artifactoryUrl, secretTTL, request, tokenOptions)
+	if err != nil {
+		return utils.CredentialProviderResponse{}, time.Time{}, err
+	}
+	logs.Info("JFrog Username used for pull :" + entry.Username)
+
+	return generateResponse(imageScope.RegistryKeys, cacheKeyType, entry.Username, entry.Token, cacheDuration(logs, entry.ExpiresAt)), entry.ExpiresAt, nil
 }
 
-func validateRTRequiredEnvVariables(logs *logger.Logger) string {
-	artifactoryUrl := os.Getenv("artifactory_url")
-	if artifactoryUrl == "" {
-		logs.Exit("ERROR in JFrog Credentials provider, environment vars configured in the plugin: artifactory_url was empty", 1)
-	} else {
-		logs.Info("getting envs - " + "artifactoryUrl :" + artifactoryUrl)
-	}
-	return artifactoryUrl
+// exitWithError reports a failed credential flow and exits. Besides the log,
+// a single JSON line goes to stderr, which kubelet includes in the events of
+// the pod whose image pull failed, and the exit code reflects its ErrorCode.
+func exitWithError(logs *logger.Logger, err error) {
+	code := handlers.ErrorCodeOf(err)
+	line, _ := json.Marshal(handlers.NewErrorLine(err))
+	fmt.Fprintln(os.Stderr, string(line))
+	logs.Exit("ERROR in JFrog Credentials provider ["+string(code)+"]: "+err.Error(), code.ExitCode())
 }
 
-func validateAWSEnvVariables(logs *logger.Logger, request utils.CredentialProviderRequest) utils.AWSEnvVariables {
-	awsAuthMethod := os.Getenv("aws_auth_method")
-	if awsAuthMethod == "" {
-		logs.Info("awsAuthMethod not set, will default to Assume role")
-		awsAuthMethod = "assume_role"
-	} else if awsAuthMethod != "cognito_oidc" && awsAuthMethod != "assume_role" && awsAuthMethod != "assume_external_role" {
-		logs.Exit("wrong aws_auth_method value :"+awsAuthMethod, 1)
+// resolveTokenOptions returns the scope and refreshability of the requested
+// Artifactory token. A scope configured for the image path prefix takes
+// precedence over the provider-wide jfrog_token_scope.
+func resolveTokenOptions(logs *logger.Logger, imageScope utils.ImageScope) (handlers.TokenOptions, error) {
+	options := handlers.TokenOptions{Scope: utils.GetEnvs(logs, "jfrog_token_scope", "")}
+	if imageScope.Scope != "" {
+		options.Scope = imageScope.Scope
 	}
-
-	// aws_role_name is only required for assume_role / web_identity;
-	// cognito_oidc and assume_external_role do not use it.
-	awsRoleName := os.Getenv("aws_role_name")
-	awsExternalRoleARN := os.Getenv("aws_external_role_arn")
-	awsExternalRoleSessionDurationVal := os.Getenv("aws_external_role_session_duration_seconds")
-	awsExternalRoleSessionDurationSeconds := 3600
-	if awsExternalRoleSessionDurationVal != "" {
-		duration, err := strconv.Atoi(awsExternalRoleSessionDurationVal)
-		if err != nil || duration > 43200 {
-			logs.Info("bad value for aws_external_role_session_duration_seconds, defaulting to 3600")
-		} else {
-			awsExternalRoleSessionDurationSeconds = duration
+	if v := utils.GetEnvs(logs, "jfrog_token_refreshable", ""); v != "" {
+		refreshable, err := strconv.ParseBool(v)
+		if err != nil {
+			return handlers.TokenOptions{}, handlers.ConfigError(handlers.CodeConfigInvalid, "jfrog_token_refreshable must be true or false, got: %s", v)
 		}
+		options.Refreshable = &refreshable
 	}
-
-	if request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"] != "" {
-		awsRoleName = request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"]
-	} else {
-		logs.Info("Service account annotation for eks.amazonaws.com/role-arn not found, using aws_role_name")
-	}
-
-	if awsRoleName == "" && awsAuthMethod != "cognito_oidc" && awsAuthMethod != "assume_external_role" {
-		logs.Exit("error in JFrog Credentials provider, environment var: awsRoleName configured in the plugin aws_role_name was empty", 1)
-	} else if awsRoleName != "" {
-		logs.Info("getting envs - " + "awsRoleName :" + awsRoleName)
+	if options.Scope != "" {
+		logs.Info("Requesting Artifactory token with scope: " + options.Scope)
 	}
+	return options, nil
+}
 
-	if awsAuthMethod == "assume_external_role" && awsExternalRoleARN == "" {
-		logs.Exit("error in JFrog Credentials provider, environment var: aws_external_role_arn must be configured when aws_auth_method is assume_external_role", 1)
+func initializeLoggerAndParseRequest() (*logger.Logger, utils.CredentialProviderRequest) {
+	logs, err := logger.NewLogger()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
 	}
+	logs.Info("Running JFrog Credentials provider...")
 
-	jfrogOIDCProviderName := os.Getenv("jfrog_oidc_provider_name")
-	secretName := os.Getenv("secret_name")
-	resourceServerName := os.Getenv("resource_server_name")
-	userPoolName := os.Getenv("user_pool_name")
-	userPoolResourceScope := os.Getenv("user_pool_resource_scope")
-
-	if awsAuthMethod == "cognito_oidc" {
-		if jfrogOIDCProviderName == "" || secretName == "" || userPoolName == "" || resourceServerName == "" || userPoolResourceScope == "" {
-			logs.Exit("ERROR in JFrog Credentials provider, environment variables missing: jfrog_oidc_provider_name, secret_name, user_pool_name, resource_server_name, user_pool_resource_scope", 1)
-		}
-		logs.Info(fmt.Sprintf("getting envs - jfrogOidcProviderName: %s, secretName: %s, userPoolName: %s, resourceServerName: %s, scope: %s",
-			jfrogOIDCProviderName, secretName, userPoolName, resourceServerName, userPoolResourceScope))
+	var request utils.CredentialProviderRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		exitWithError(logs, handlers.NewCredentialError(handlers.CodeRequestInvalid, "read kubelet request from stdin", err))
 	}
+	logs.Info("request.Image :" + request.Image)
 
-	return utils.AWSEnvVariables{
-		AWSAuthMethod:                  awsAuthMethod,
-		AWSRoleName:                    awsRoleName,
-		AWSExternalRoleARN:             awsExternalRoleARN,
-		AWSExternalRoleDurationSeconds: awsExternalRoleSessionDurationSeconds,
-		JFrogOIDCProviderName:          jfrogOIDCProviderName,
-		SecretName:                     secretName,
-		ResourceServerName:             resourceServerName,
-		UserPoolName:                   userPoolName,
-		UserPoolResourceScope:          userPoolResourceScope,
-	}
+	return logs, request
 }
 
-func handleAWSAuth(svc *service.Service, ctx context.Context, logs *logger.Logger, awsEnvVariables utils.AWSEnvVariables, artifactoryUrl, secretTTL string, request utils.CredentialProviderRequest) (string, string) {
-	var rtUsername, rtToken string
-	var useServiceAccount = false
-
-	if request.ServiceAccountAnnotations["JFrogExchange"] == "true" && request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"] != "" {
-		useServiceAccount = true
-		awsEnvVariables.AWSRoleName = request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"]
+func getCloudProvider(svc *service.Service, ctx context.Context, logs *logger.Logger) (string, error) {
+	cloudProvider := utils.GetEnvs(logs, "cloud_provider", "")
+	logs.Info("cloud_provider from env:" + cloudProvider)
+	if cloudProvider != "" {
+		return cloudProvider, nil
 	}
 
-	if useServiceAccount {
-		awsEnvVariables.AWSAuthMethod = "web_identity"
-		logs.Info("Using web_identity aws auth method based on service account annotation")
+	cacheFile := detectedCloudProviderFile(logs)
+	if cloudProvider := readDetectedCloudProvider(cacheFile); cloudProvider != "" {
+		logs.Debug("cloud_provider from " + cacheFile + ":" + cloudProvider)
+		return cloudProvider, nil
 	}
-
-	if awsEnvVariables.AWSAuthMethod == "assume_role" || awsEnvVariables.AWSAuthMethod == "web_identity" || awsEnvVariables.AWSAuthMethod == "assume_external_role" {
-		req, err := handlers.GetAWSSignedRequest(svc, ctx, request.ServiceAccountToken, awsEnvVariables)
-		if err != nil {
-			logs.Exit("ERROR in JFrog Credentials provider, could not get aws signed request :"+err.Error(), 1)
-		}
-		rtUsername, rtToken, err = handlers.ExchangeAssumedRoleArtifactoryToken(svc, ctx, req, artifactoryUrl, secretTTL)
-		if err != nil {
-			logs.Exit("Error in createArtifactoryToken: "+err.Error(), 1)
-		}
-	} else {
-		token, err := handlers.GetAwsOidcToken(svc, ctx, awsEnvVariables.AWSRoleName, awsEnvVariables.SecretName, awsEnvVariables.UserPoolName, awsEnvVariables.ResourceServerName, awsEnvVariables.UserPoolResourceScope)
-		if err != nil {
-			logs.Exit("ERROR in JFrog Credentials provider, could not get aws oidc token :"+err.Error(), 1)
-		}
-		rtUsername, rtToken, err = handlers.ExchangeOidcArtifactoryToken(svc, ctx, token, artifactoryUrl, awsEnvVariables.JFrogOIDCProviderName, "")
-		if err != nil {
-			logs.Exit("Error in createArtifactoryToken: "+err.Error(), 1)
+	cloudProvider, err := detectCloudProvider(svc, ctx, logs, detectionProbeTimeout(logs))
+	if err != nil {
+		return "", err
+	}
+	if cloudProvider != "" {
+		if err := writeDetectedCloudProvider(cacheFile, cloudProvider); err != nil {
+			logs.Info("Warning: could not persist the detected cloud provider: " + err.Error())
 		}
 	}
-	return rtUsername, rtToken
+	return cloudProvider, nil
 }
 
-func handleAzureAuth(svc *service.Service, ctx context.Context, logs *logger.Logger, artifactoryUrl string, request utils.CredentialProviderRequest) (string, string) {
-
-	var token string
-	var err error
-
-	azureAuthMethod := os.Getenv("azure_auth_method")
-	if azureAuthMethod == "" {
-		logs.Info("azureAuthMethod not set, will default to legacy federated credentials or projected tokens if tokenAttributes is enabled")
-	} else if azureAuthMethod == "imds_direct" {
-		logs.Info("azureAuthMethod set to imds_direct, will use IMDS to get app's access token")
-	} else {
-		logs.Exit("wrong azure_auth_method value :"+azureAuthMethod, 1)
-	}
-
-	// get required env variables
-	azureAppClientId := utils.GetEnvs(logs, "azure_app_client_id", "")
-	azureAppCloudName := utils.GetEnvs(logs, "azure_cloud_name", "AzureCloud")
-	azureAppTenantId := utils.GetEnvs(logs, "azure_tenant_id", "")
-	azureAppAudience := utils.GetEnvs(logs, "azure_app_audience", "")
-	azureAppURI := utils.GetEnvs(logs, "azure_app_uri", "api://"+azureAppClientId)
-	azureNodepoolClientId := utils.GetEnvs(logs, "azure_nodepool_client_id", "")
-	jfrogOidcProviderName := utils.GetEnvs(logs, "jfrog_oidc_provider_name", "")
-	jfrogTokenAudience := utils.GetEnvs(logs, "jfrog_token_audience", "")
-
-	if azureAuthMethod == "imds_direct" && request.ServiceAccountAnnotations["JFrogExchange"] != "true" {
-		if azureAppClientId == "" || azureNodepoolClientId == "" || azureAppURI == "" || jfrogOidcProviderName == "" {
-			logs.Exit("ERROR in JFrog Credentials provider, environment variables missing: azure_app_client_id, azure_nodepool_client_id, azureAppURI, jfrog_oidc_provider_name", 1)
-		}
-		logs.Info(fmt.Sprintf("getting envs - azureAppClientId: %s, azureNodepoolClientId: %s, azureAppURI: %s, jfrogOidcProviderName: %s",
-			azureAppClientId, azureNodepoolClientId, azureAppURI, jfrogOidcProviderName))
-		logs.Info("Service Account Token obtained using Node Managed Identity (IMDS direct app access token)")
-		token, err = handlers.GetAzureClusterIdentity(svc, ctx, azureAppURI, azureNodepoolClientId)
-		if err != nil {
-			logs.Exit("ERROR in GetAzureClusterIdentity :"+err.Error(), 1)
-		}
-	} else if request.ServiceAccountAnnotations["JFrogExchange"] != "true" {
-		if azureAppClientId == "" || azureAppTenantId == "" || azureNodepoolClientId == "" || azureAppAudience == "" || jfrogOidcProviderName == "" {
-			logs.Exit("ERROR in JFrog Credentials provider, environment variables missing: azure_app_client_id, azure_tenant_id, azure_nodepool_client_id, azureAppAudience, jfrog_oidc_provider_name", 1)
-		} else {
-			logs.Info(fmt.Sprintf("getting envs - azureAppClientId: %s, azureAppCloudName: %s, azureNodepoolClientId: %s, azureAppAudience: %s, azureAppTenantId: %s, jfrogOidcProviderName: %s",
-				azureAppClientId, azureAppCloudName, azureNodepoolClientId, azureAppAudience, azureAppTenantId, jfrogOidcProviderName))
-		}
-		logs.Info("Service Account Token obtained using Node Identity (VM Service Account)")
-		// Get Azure OIDC token
-		token, err = handlers.GetAzureOIDCToken(svc, ctx, azureAppTenantId, azureAppClientId, azureNodepoolClientId, azureAppAudience, azureAppCloudName)
-	} else {
-		if azureAppAudience == "" || jfrogOidcProviderName == "" {
-			logs.Exit("ERROR in JFrog Credentials provider, environment variables missing: azureAppAudience, jfrog_oidc_provider_name", 1)
-		} else {
-			logs.Info(fmt.Sprintf("getting envs - azureAppAudience: %s, jfrogOidcProviderName: %s",
-				azureAppAudience, jfrogOidcProviderName))
-		}
-		logs.Info("Service Account Token obtained using Pod Identity (Kubernetes Workload Identity)")
-		token = request.ServiceAccountToken
-	}
+// cloudProviderAuth runs the flow of the credential source registered for
+// the configured or detected cloud provider.
+func cloudProviderAuth(svc *service.Service, ctx context.Context, logs *logger.Logger, artifactoryUrl, secretTTL string, request utils.CredentialProviderRequest, tokenOptions handlers.TokenOptions) (handlers.ArtifactoryToken, error) {
+	cloudProvider, err := getCloudProvider(svc, ctx, logs)
 	if err != nil {
-		logs.Exit("ERROR in GetAzureOIDCToken :"+err.Error(), 1)
+		return handlers.ArtifactoryToken{}, err
 	}
-
-	if jfrogTokenAudience == "" {
-		jfrogTokenAudience = "*@*"
+	source, ok := newCredentialSource(cloudProvider)
+	if !ok {
+		return handlers.ArtifactoryToken{}, handlers.ConfigError(handlers.CodeConfigInvalid, "cloud_provider value should be one of %s, got: %s", strings.Join(credentialSourceNames(), ", "), cloudProvider)
 	}
+	logs.Debug("Using " + cloudProvider + " credential source")
 
-	// Exchange Azure OIDC token with JFrog Artifactory token
-	rtUsername, rtToken, err := handlers.ExchangeOidcArtifactoryToken(svc, ctx, token, artifactoryUrl, jfrogOidcProviderName, jfrogTokenAudience)
+	if err := source.ValidateConfig(logs, request); err != nil {
+		return handlers.ArtifactoryToken{}, err
+	}
+	subject, err := source.ObtainSubjectToken(svc, ctx, logs, request)
 	if err != nil {
-		logs.Exit("ERROR in JFrog Credentials provider, error in createArtifactoryToken :"+err.Error(), 1)
+		return handlers.ArtifactoryToken{}, err
 	}
-
-	return rtUsername, rtToken
+	return source.Exchange(svc, ctx, subject, artifactoryUrl, secretTTL, tokenOptions)
 }
 
-func handleGoogleAuth(svc *service.Service, ctx context.Context, logs *logger.Logger, artifactoryUrl string, request utils.CredentialProviderRequest) (string, string) {
-	// get required env variables
-	googleServiceAccountEmail := utils.GetEnvs(logs, "google_service_account_email", "")
-	jfrogOidcProviderAudience := utils.GetEnvs(logs, "jfrog_oidc_audience", "")
-	jfrogOidcProviderName := utils.GetEnvs(logs, "jfrog_oidc_provider_name", "")
-	var token string
-	var err error
-	if googleServiceAccountEmail == "" || jfrogOidcProviderAudience == "" || jfrogOidcProviderName == "" {
-		logs.Exit("ERROR in JFrog Credentials provider, environment variables missing: google_service_account_email, jfrog_oidc_audience, jfrog_oidc_provider_name", 1)
-	} else {
-		logs.Info(fmt.Sprintf("getting envs - googleServiceAccountEmail: %s, jfrogOidcProviderAudience: %s, jfrogOidcProviderName: %s",
-			googleServiceAccountEmail, jfrogOidcProviderAudience, jfrogOidcProviderName))
+func validateRTRequiredEnvVariables(logs *logger.Logger) (string, error) {
+	artifactoryUrl := os.Getenv("artifactory_url")
+	if artifactoryUrl == "" {
+		return "", handlers.ConfigError(handlers.CodeConfigMissing, "environment vars configured in the plugin: artifactory_url was empty")
 	}
+	logs.Info("getting envs - " + "artifactoryUrl :" + artifactoryUrl)
+	return artifactoryUrl, nil
+}
 
-	if request.ServiceAccountAnnotations["JFrogExchange"] == "true" {
-		logs.Info("Service Account Token obtained using Pod Identity (Kubernetes Workload Identity)")
-		token = request.ServiceAccountToken
-	} else {
-		// Get Google OIDC token
-		logs.Info("Service Account Token obtained using Node Identity (VM Service Account)")
-		token, err = handlers.GetGoogleOIDCToken(svc, ctx, googleServiceAccountEmail, jfrogOidcProviderAudience)
-		if err != nil {
-			logs.Exit("ERROR in GetGoogleOIDCToken :"+err.Error(), 1)
+// cacheDuration returns how long kubelet may cache a token expiring at
+// expiresAt, minus cache_duration_skew_seconds so kubelet drops the
+// credentials before Artifactory does. Returns "" when the expiry is unknown
+// so kubelet keeps using defaultCacheDuration.
+func cacheDuration(logs *logger.Logger, expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return ""
+	}
+	skew := defaultCacheDurationSkew
+	if v := utils.GetEnvs(logs, "cache_duration_skew_seconds", ""); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			skew = time.Duration(n) * time.Second
+		} else {
+			logs.Info("bad value for cache_duration_skew_seconds, defaulting to " + skew.String())
 		}
 	}
-
-	// Exchange Google OIDC token with JFrog Artifactory token
-	rtUsername, rtToken, err := handlers.ExchangeOidcArtifactoryToken(svc, ctx, token, artifactoryUrl, jfrogOidcProviderName, jfrogOidcProviderAudience)
-	if err != nil {
-		logs.Exit("ERROR in JFrog Credentials provider, error in createArtifactoryToken :"+err.Error(), 1)
+	remaining := time.Until(expiresAt) - skew
+	if remaining < 0 {
+		// a zero duration tells kubelet not to cache the credentials at all
+		remaining = 0
 	}
-	return rtUsername, rtToken
+	return remaining.Truncate(time.Second).String()
 }
 
-func generateAndOutputResponse(logs *logger.Logger, request utils.CredentialProviderRequest, rtUsername, rtToken string) {
-	response := utils.CredentialProviderResponse{
-		ApiVersion:   "credentialprovider.kubelet.k8s.io/v1",
-		Kind:         "CredentialProviderResponse",
-		CacheKeyType: "Registry",
+func generateResponse(registryKeys []string, cacheKeyType, rtUsername, rtToken, cacheDuration string) utils.CredentialProviderResponse {
+	registry := map[string]utils.AuthCredential{}
+	for _, key := range registryKeys {
+		registry[key] = utils.AuthCredential{
+			Username: rtUsername,
+			Password: rtToken,
+		}
+	}
+	return utils.CredentialProviderResponse{
+		ApiVersion:    "credentialprovider.kubelet.k8s.io/v1",
+		Kind:          "CredentialProviderResponse",
+		CacheKeyType:  cacheKeyType,
+		CacheDuration: cacheDuration,
 		Auth: utils.AuthSection{
-			Registry: map[string]utils.AuthCredential{
-				extractRepository(request.Image): {
-					Username: rtUsername,
-					Password: rtToken,
-				},
-			},
+			Registry: registry,
 		},
 	}
+}
+
+func outputResponse(logs *logger.Logger, response utils.CredentialProviderResponse) {
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
-		logs.Exit("Error marshaling JSON :"+err.Error(), 1)
+		exitWithError(logs, handlers.NewCredentialError(handlers.CodeInternal, "marshal kubelet response", err))
 	}
 	os.Stdout.Write(jsonBytes)
 }
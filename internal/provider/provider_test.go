@@ -0,0 +1,71 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"io"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testLogger() *logger.Logger {
+	return &logger.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func TestCacheDurationSubtractsSkew(t *testing.T) {
+	t.Setenv("cache_duration_skew_seconds", "300")
+
+	got, err := time.ParseDuration(cacheDuration(testLogger(), time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got > 55*time.Minute || got < 54*time.Minute {
+		t.Fatalf("expected ~55m, got %s", got)
+	}
+}
+
+func TestCacheDurationUnknownExpiry(t *testing.T) {
+	if got := cacheDuration(testLogger(), time.Time{}); got != "" {
+		t.Fatalf("expected empty cache duration, got %q", got)
+	}
+}
+
+func TestCacheDurationNeverNegative(t *testing.T) {
+	if got := cacheDuration(testLogger(), time.Now().Add(10*time.Second)); got != "0s" {
+		t.Fatalf("expected 0s, got %q", got)
+	}
+}
+
+func TestResolveCredentialsReportsConfigErrors(t *testing.T) {
+	t.Setenv("artifactory_url", "")
+	_, _, err := resolveCredentials(context.Background(), testLogger(), http.DefaultClient, utils.CredentialProviderRequest{Image: "example.jfrog.io/nginx"})
+	if code := handlers.ErrorCodeOf(err); code != handlers.CodeConfigMissing {
+		t.Fatalf("expected %s, got %v", handlers.CodeConfigMissing, err)
+	}
+
+	t.Setenv("artifactory_url", "example.jfrog.io")
+	t.Setenv("cloud_provider", "openstack")
+	t.Setenv("disable_token_cache", "true")
+	_, _, err = resolveCredentials(context.Background(), testLogger(), http.DefaultClient, utils.CredentialProviderRequest{Image: "example.jfrog.io/nginx"})
+	if code := handlers.ErrorCodeOf(err); code != handlers.CodeConfigInvalid {
+		t.Fatalf("expected %s, got %v", handlers.CodeConfigInvalid, err)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"errors"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"net/http"
+	"sync"
+)
+
+// errNotDetectable is returned by Detect for sources that can only be
+// selected with an explicit cloud_provider.
+var errNotDetectable = errors.New("credential source cannot be detected")
+
+// SubjectToken is the identity a credential source presents to Artifactory,
+// either an OIDC token or a signed AWS GetCallerIdentity request.
+type SubjectToken struct {
+	Token         string
+	SignedRequest *http.Request
+}
+
+// CredentialSource is one way of obtaining an Artifactory token, selected by
+// cloud_provider or by detection. A new source is created for every request
+// by its registered factory: ValidateConfig reads the provider env into it,
+// then ObtainSubjectToken and Exchange run the flow.
+type CredentialSource interface {
+	// Detect reports whether the node runs where this source works
+	Detect(svc *service.Service, ctx context.Context) (bool, error)
+	// ValidateConfig reads and checks the provider env for the request
+	ValidateConfig(logs *logger.Logger, request utils.CredentialProviderRequest) error
+	// ObtainSubjectToken gets the identity to exchange with Artifactory
+	ObtainSubjectToken(svc *service.Service, ctx context.Context, logs *logger.Logger, request utils.CredentialProviderRequest) (SubjectToken, error)
+	// Exchange trades the subject token for an Artifactory token
+	Exchange(svc *service.Service, ctx context.Context, subject SubjectToken, artifactoryUrl, secretTTL string, tokenOptions handlers.TokenOptions) (handlers.ArtifactoryToken, error)
+}
+
+type credentialSourceEntry struct {
+	name    string
+	factory func() CredentialSource
+}
+
+var (
+	credentialSourcesMu sync.RWMutex
+	// credentialSources keeps registration order, which is also the order
+	// of detection
+	credentialSources []credentialSourceEntry
+)
+
+// RegisterCredentialSource makes a credential source available as a
+// cloud_provider value, replacing any source registered under that name,
+// and registers the schema of its provider env.
+func RegisterCredentialSource(name string, schema utils.ConfigSchema, factory func() CredentialSource) {
+	utils.RegisterConfigSchema(name, schema)
+	credentialSourcesMu.Lock()
+	defer credentialSourcesMu.Unlock()
+	for i, entry := range credentialSources {
+		if entry.name == name {
+			credentialSources[i].factory = factory
+			return
+		}
+	}
+	credentialSources = append(credentialSources, credentialSourceEntry{name: name, factory: factory})
+}
+
+func newCredentialSource(name string) (CredentialSource, bool) {
+	credentialSourcesMu.RLock()
+	defer credentialSourcesMu.RUnlock()
+	for _, entry := range credentialSources {
+		if entry.name == name {
+			return entry.factory(), true
+		}
+	}
+	return nil, false
+}
+
+func credentialSourceNames() []string {
+	credentialSourcesMu.RLock()
+	defer credentialSourcesMu.RUnlock()
+	names := make([]string, 0, len(credentialSources))
+	for _, entry := range credentialSources {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// oidcExchange is the Exchange of sources whose subject is an OIDC token.
+type oidcExchange struct {
+	providerName string
+	audience     string
+}
+
+func (o oidcExchange) Exchange(svc *service.Service, ctx context.Context, subject SubjectToken, artifactoryUrl, _ string, tokenOptions handlers.TokenOptions) (handlers.ArtifactoryToken, error) {
+	return handlers.ExchangeOidcArtifactoryToken(svc, ctx, subject.Token, artifactoryUrl, o.providerName, o.audience, tokenOptions)
+}
+
+// notDetectable is the Detect of sources that need an explicit cloud_provider.
+type notDetectable struct{}
+
+func (notDetectable) Detect(*service.Service, context.Context) (bool, error) {
+	return false, errNotDetectable
+}
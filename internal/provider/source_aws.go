@@ -0,0 +1,290 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	signer "jfrog-credential-provider/internal/sign"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterCredentialSource(utils.CloudProviderAWS, awsSchema, func() CredentialSource { return &awsSource{} })
+}
+
+// awsSchema is the provider env of awsSource.
+var awsSchema = utils.ConfigSchema{
+	Env: []string{
+		"aws_auth_method", "aws_region", "aws_role_name", "aws_external_role_arn",
+		"aws_external_role_session_duration_seconds", "aws_external_role_external_id",
+		"aws_external_role_session_tags", "aws_external_role_session_name",
+		"aws_external_role_source_identity", "aws_cluster_name", "secret_name", "jfrog_oidc_provider_name",
+		"user_pool_name", "user_pool_resource_scope", "resource_server_name",
+		"aws_imds_endpoint", "aws_imds_endpoint_mode", "aws_web_identity_token_file",
+		"aws_container_credentials_full_uri", "aws_container_authorization_token_file",
+		"aws_signing_algorithm",
+	},
+	MethodEnv: "aws_auth_method",
+	Methods: map[string][]string{
+		"assume_role":          nil,
+		"assume_external_role": {"aws_external_role_arn"},
+		"cognito_oidc":         {"jfrog_oidc_provider_name", "secret_name", "user_pool_name", "resource_server_name", "user_pool_resource_scope"},
+		// EKS Pod Identity agent, the token file may also come from the
+		// AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE the agent sets
+		"pod_identity": nil,
+		// IRSA token file, or AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN
+		"web_identity_token_file": nil,
+	},
+	Validate: func(config utils.Provider) error {
+		if _, err := signer.ParseAlgorithm(utils.GetEnvVarValue(config.Env, "aws_signing_algorithm")); err != nil {
+			return err
+		}
+		if utils.GetEnvVarValue(config.Env, "aws_auth_method") == "assume_external_role" {
+			if _, err := utils.ParseAWSRoleChain(utils.GetEnvVarValue(config.Env, "aws_external_role_arn")); err != nil {
+				return err
+			}
+			if externalID := utils.GetEnvVarValue(config.Env, "aws_external_role_external_id"); externalID != "" && !utils.ValidAWSExternalID(externalID) {
+				return fmt.Errorf("aws_external_role_external_id is not a valid ExternalId")
+			}
+			if _, err := utils.ParseAWSSessionTags(utils.GetEnvVarValue(config.Env, "aws_external_role_session_tags")); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// awsSource signs a GetCallerIdentity request with the node role, an assumed
+// role, the pod's IRSA role (kubelet token or token file) or its EKS Pod
+// Identity role, or exchanges a Cognito OIDC token.
+type awsSource struct {
+	env utils.AWSEnvVariables
+}
+
+func (a *awsSource) Detect(svc *service.Service, ctx context.Context) (bool, error) {
+	return handlers.CheckIfAWS(svc, ctx)
+}
+
+func (a *awsSource) ValidateConfig(logs *logger.Logger, request utils.CredentialProviderRequest) error {
+	env, err := validateAWSEnvVariables(logs, request)
+	if err != nil {
+		return err
+	}
+	// pod_identity and web_identity_token_file bring their own pod credentials
+	podCredentials := env.AWSAuthMethod == "pod_identity" || env.AWSAuthMethod == "web_identity_token_file"
+	if !podCredentials && request.ServiceAccountAnnotations["JFrogExchange"] == "true" && request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"] != "" {
+		env.AWSRoleName = request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"]
+		env.AWSAuthMethod = "web_identity"
+		logs.Info("Using web_identity aws auth method based on service account annotation")
+	}
+	a.env = env
+	return nil
+}
+
+func (a *awsSource) ObtainSubjectToken(svc *service.Service, ctx context.Context, logs *logger.Logger, request utils.CredentialProviderRequest) (SubjectToken, error) {
+	if a.env.AWSAuthMethod != "cognito_oidc" {
+		req, err := handlers.GetAWSSignedRequest(svc, ctx, request.ServiceAccountToken, a.env)
+		if err != nil {
+			return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "get aws signed request", err)
+		}
+		return SubjectToken{SignedRequest: req}, nil
+	}
+	token, err := handlers.GetAwsOidcToken(svc, ctx, a.env.AWSRoleName, a.env.SecretName, a.env.UserPoolName, a.env.ResourceServerName, a.env.UserPoolResourceScope)
+	if err != nil {
+		return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "get aws oidc token", err)
+	}
+	return SubjectToken{Token: token}, nil
+}
+
+func (a *awsSource) Exchange(svc *service.Service, ctx context.Context, subject SubjectToken, artifactoryUrl, secretTTL string, tokenOptions handlers.TokenOptions) (handlers.ArtifactoryToken, error) {
+	if subject.SignedRequest != nil {
+		return handlers.ExchangeAssumedRoleArtifactoryToken(svc, ctx, subject.SignedRequest, artifactoryUrl, secretTTL, tokenOptions)
+	}
+	return handlers.ExchangeOidcArtifactoryToken(svc, ctx, subject.Token, artifactoryUrl, a.env.JFrogOIDCProviderName, "", tokenOptions)
+}
+
+// applyExternalRoleSessionSettings gives every hop of chain without its own
+// the aws_external_role_external_id and aws_external_role_session_tags, and
+// returns the expanded role session name and source identity.
+func applyExternalRoleSessionSettings(logs *logger.Logger, chain []utils.AWSRoleHop) (string, string, error) {
+	cluster := os.Getenv("aws_cluster_name")
+	node, err := os.Hostname()
+	if err != nil {
+		logs.Info("could not get the node name for the role session: " + err.Error())
+	}
+
+	externalID := os.Getenv("aws_external_role_external_id")
+	if externalID != "" && !utils.ValidAWSExternalID(externalID) {
+		return "", "", handlers.ConfigError(handlers.CodeConfigInvalid, "environment var: aws_external_role_external_id is not a valid ExternalId")
+	}
+	tags, err := utils.ParseAWSSessionTags(os.Getenv("aws_external_role_session_tags"))
+	if err != nil {
+		return "", "", handlers.ConfigError(handlers.CodeConfigInvalid, "%v", err)
+	}
+	for i := range chain {
+		if chain[i].ExternalID == "" {
+			chain[i].ExternalID = externalID
+		}
+		for key, value := range tags {
+			if _, ok := chain[i].Tags[key]; ok {
+				continue
+			}
+			if chain[i].Tags == nil {
+				chain[i].Tags = map[string]string{}
+			}
+			chain[i].Tags[key] = utils.ExpandAWSSessionTemplate(value, cluster, node)
+		}
+	}
+
+	sessionNameTemplate := os.Getenv("aws_external_role_session_name")
+	if sessionNameTemplate == "" {
+		sessionNameTemplate = utils.DefaultAWSRoleSessionName
+	}
+	sessionName := utils.AWSSessionName(utils.ExpandAWSSessionTemplate(sessionNameTemplate, cluster, node))
+	if len(sessionName) < 2 {
+		return "", "", handlers.ConfigError(handlers.CodeConfigInvalid, "environment var: aws_external_role_session_name expands to %q, it should be at least 2 characters", sessionName)
+	}
+	var sourceIdentity string
+	if template := os.Getenv("aws_external_role_source_identity"); template != "" {
+		sourceIdentity = utils.AWSSessionName(utils.ExpandAWSSessionTemplate(template, cluster, node))
+		if len(sourceIdentity) < 2 || strings.HasPrefix(strings.ToLower(sourceIdentity), "aws:") {
+			return "", "", handlers.ConfigError(handlers.CodeConfigInvalid, "environment var: aws_external_role_source_identity expands to %q, it should be at least 2 characters and not start with aws:", sourceIdentity)
+		}
+	}
+	logs.Info("role session name: " + sessionName + ", source identity: " + sourceIdentity)
+	return sessionName, sourceIdentity, nil
+}
+
+func validateAWSEnvVariables(logs *logger.Logger, request utils.CredentialProviderRequest) (utils.AWSEnvVariables, error) {
+	awsAuthMethod := os.Getenv("aws_auth_method")
+	if awsAuthMethod == "" {
+		logs.Info("awsAuthMethod not set, will default to Assume role")
+		awsAuthMethod = "assume_role"
+	} else if !slices.Contains([]string{"cognito_oidc", "assume_role", "assume_external_role", "pod_identity", "web_identity_token_file"}, awsAuthMethod) {
+		return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigInvalid, "wrong aws_auth_method value :%s", awsAuthMethod)
+	}
+
+	// aws_role_name is only required for assume_role / web_identity;
+	// cognito_oidc and assume_external_role do not use it.
+	awsRoleName := os.Getenv("aws_role_name")
+	awsExternalRoleARN := os.Getenv("aws_external_role_arn")
+	awsExternalRoleSessionDurationVal := os.Getenv("aws_external_role_session_duration_seconds")
+	awsExternalRoleSessionDurationSeconds := 3600
+	if awsExternalRoleSessionDurationVal != "" {
+		duration, err := strconv.Atoi(awsExternalRoleSessionDurationVal)
+		if err != nil || duration > 43200 {
+			logs.Info("bad value for aws_external_role_session_duration_seconds, defaulting to 3600")
+		} else {
+			awsExternalRoleSessionDurationSeconds = duration
+		}
+	}
+
+	if request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"] != "" {
+		awsRoleName = request.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"]
+	} else {
+		logs.Info("Service account annotation for eks.amazonaws.com/role-arn not found, using aws_role_name")
+	}
+
+	// the pod's IRSA role, as the SDK reads it, when the plugin runs with the pod env
+	if awsRoleName == "" && awsAuthMethod == "web_identity_token_file" {
+		awsRoleName = os.Getenv("AWS_ROLE_ARN")
+	}
+	webIdentityTokenFile := envOrSDKEnv("aws_web_identity_token_file", "AWS_WEB_IDENTITY_TOKEN_FILE")
+	containerCredentialsURI := envOrSDKEnv("aws_container_credentials_full_uri", "AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	containerAuthorizationTokenFile := envOrSDKEnv("aws_container_authorization_token_file", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE")
+	if awsAuthMethod == "web_identity_token_file" && webIdentityTokenFile == "" {
+		return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigMissing, "environment var: aws_web_identity_token_file (or AWS_WEB_IDENTITY_TOKEN_FILE) must be configured when aws_auth_method is web_identity_token_file")
+	}
+	if awsAuthMethod == "pod_identity" && containerAuthorizationTokenFile == "" {
+		return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigMissing, "environment var: aws_container_authorization_token_file (or AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE) must be configured when aws_auth_method is pod_identity")
+	}
+
+	if awsRoleName == "" && awsAuthMethod != "cognito_oidc" && awsAuthMethod != "assume_external_role" && awsAuthMethod != "pod_identity" {
+		return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigMissing, "environment var: awsRoleName configured in the plugin aws_role_name was empty")
+	} else if awsRoleName != "" {
+		logs.Info("getting envs - " + "awsRoleName :" + awsRoleName)
+	}
+
+	var awsExternalRoleChain []utils.AWSRoleHop
+	var awsExternalRoleSessionName, awsExternalRoleSourceIdentity string
+	if awsAuthMethod == "assume_external_role" {
+		if awsExternalRoleARN == "" {
+			return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigMissing, "environment var: aws_external_role_arn must be configured when aws_auth_method is assume_external_role")
+		}
+		chain, err := utils.ParseAWSRoleChain(awsExternalRoleARN)
+		if err != nil {
+			return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigInvalid, "%v", err)
+		}
+		awsExternalRoleChain = chain
+		awsExternalRoleSessionName, awsExternalRoleSourceIdentity, err = applyExternalRoleSessionSettings(logs, awsExternalRoleChain)
+		if err != nil {
+			return utils.AWSEnvVariables{}, err
+		}
+	}
+
+	signingAlgorithm := os.Getenv("aws_signing_algorithm")
+	if _, err := signer.ParseAlgorithm(signingAlgorithm); err != nil {
+		return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigInvalid, "environment var: aws_signing_algorithm: %v", err)
+	}
+
+	jfrogOIDCProviderName := os.Getenv("jfrog_oidc_provider_name")
+	secretName := os.Getenv("secret_name")
+	resourceServerName := os.Getenv("resource_server_name")
+	userPoolName := os.Getenv("user_pool_name")
+	userPoolResourceScope := os.Getenv("user_pool_resource_scope")
+
+	if awsAuthMethod == "cognito_oidc" {
+		if jfrogOIDCProviderName == "" || secretName == "" || userPoolName == "" || resourceServerName == "" || userPoolResourceScope == "" {
+			return utils.AWSEnvVariables{}, handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: jfrog_oidc_provider_name, secret_name, user_pool_name, resource_server_name, user_pool_resource_scope")
+		}
+		logs.Info(fmt.Sprintf("getting envs - jfrogOidcProviderName: %s, secretName: %s, userPoolName: %s, resourceServerName: %s, scope: %s",
+			jfrogOIDCProviderName, secretName, userPoolName, resourceServerName, userPoolResourceScope))
+	}
+
+	return utils.AWSEnvVariables{
+		AWSAuthMethod:                   awsAuthMethod,
+		AWSRoleName:                     awsRoleName,
+		AWSExternalRoleChain:            awsExternalRoleChain,
+		AWSExternalRoleDurationSeconds:  awsExternalRoleSessionDurationSeconds,
+		AWSExternalRoleSessionName:      awsExternalRoleSessionName,
+		AWSExternalRoleSourceIdentity:   awsExternalRoleSourceIdentity,
+		SigningAlgorithm:                signingAlgorithm,
+		JFrogOIDCProviderName:           jfrogOIDCProviderName,
+		SecretName:                      secretName,
+		ResourceServerName:              resourceServerName,
+		UserPoolName:                    userPoolName,
+		UserPoolResourceScope:           userPoolResourceScope,
+		WebIdentityTokenFile:            webIdentityTokenFile,
+		ContainerCredentialsURI:         containerCredentialsURI,
+		ContainerAuthorizationTokenFile: containerAuthorizationTokenFile,
+	}, nil
+}
+
+// envOrSDKEnv reads a provider env var, falling back to the variable of the
+// same meaning the AWS SDKs read.
+func envOrSDKEnv(name, sdkName string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(sdkName)
+}
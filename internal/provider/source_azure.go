@@ -0,0 +1,199 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+	"slices"
+)
+
+func init() {
+	RegisterCredentialSource(utils.CloudProviderAzure, azureSchema, func() CredentialSource { return &azureSource{} })
+}
+
+// azureSchema is the provider env of azureSource.
+var azureSchema = utils.ConfigSchema{
+	Env: []string{
+		"azure_auth_method", "azure_app_client_id", "azure_cloud_name", "azure_tenant_id",
+		"azure_app_audience", "azure_app_uri", "azure_nodepool_client_id",
+		"jfrog_oidc_provider_name", "jfrog_token_audience", "azure_imds_endpoint",
+		"azure_authority_host",
+	},
+	Required:  []string{"jfrog_oidc_provider_name"},
+	MethodEnv: "azure_auth_method",
+	Methods: map[string][]string{
+		"": {"azure_app_client_id", "azure_tenant_id", "azure_app_audience", "azure_nodepool_client_id"},
+		// fetches an app-scoped access token from IMDS with the app client id,
+		// no Azure AD impersonation so no tenant id or audience
+		"imds_direct": {"azure_app_client_id", "azure_nodepool_client_id"},
+		// exchanges the pod's projected token with Azure AD for the app named in
+		// its azure.workload.identity/client-id annotation
+		"workload_identity_exchange": nil,
+	},
+	ServiceAccountToken: map[string]utils.TokenAttributes{
+		"workload_identity_exchange": {
+			RequiredServiceAccountAnnotationKeys: []string{utils.AzureWorkloadIdentityClientIDAnnotation},
+			OptionalServiceAccountAnnotationKeys: []string{utils.AzureWorkloadIdentityTenantIDAnnotation},
+		},
+	},
+	Annotated: map[string][]string{
+		"JFrogExchange": {"azure_app_audience"},
+	},
+	Validate: func(config utils.Provider) error {
+		if _, err := utils.AzureAuthorityHost(utils.GetEnvVarValue(config.Env, "azure_cloud_name"), utils.GetEnvVarValue(config.Env, "azure_authority_host")); err != nil {
+			return err
+		}
+		if utils.GetEnvVarValue(config.Env, "azure_auth_method") == "workload_identity_exchange" {
+			if config.TokenAttributes == nil || config.TokenAttributes.ServiceAccountTokenAudience == "" ||
+				!slices.Contains(config.TokenAttributes.RequiredServiceAccountAnnotationKeys, utils.AzureWorkloadIdentityClientIDAnnotation) {
+				return fmt.Errorf("azure_auth_method workload_identity_exchange requires tokenAttributes with serviceAccountTokenAudience and the %s annotation in requiredServiceAccountAnnotationKeys", utils.AzureWorkloadIdentityClientIDAnnotation)
+			}
+		}
+		return nil
+	},
+}
+
+// azureSource gets an Azure AD token for the app registration trusted by the
+// JFrog OIDC provider, using the node pool identity, or exchanges the pod's
+// own workload identity token.
+type azureSource struct {
+	oidcExchange
+	authMethod       string
+	podIdentity      bool
+	appClientId      string
+	cloudName        string
+	authorityHost    string
+	tenantId         string
+	appAudience      string
+	appURI           string
+	nodepoolClientId string
+}
+
+func (a *azureSource) Detect(svc *service.Service, ctx context.Context) (bool, error) {
+	return handlers.CheckIfAzure(svc, ctx)
+}
+
+func (a *azureSource) ValidateConfig(logs *logger.Logger, request utils.CredentialProviderRequest) error {
+	a.authMethod = os.Getenv("azure_auth_method")
+	switch a.authMethod {
+	case "":
+		logs.Info("azureAuthMethod not set, will default to legacy federated credentials or projected tokens if tokenAttributes is enabled")
+	case "imds_direct":
+		logs.Info("azureAuthMethod set to imds_direct, will use IMDS to get app's access token")
+	case "workload_identity_exchange":
+		logs.Info("azureAuthMethod set to workload_identity_exchange, will exchange the service account token with Azure AD")
+	default:
+		return handlers.ConfigError(handlers.CodeConfigInvalid, "wrong azure_auth_method value :%s", a.authMethod)
+	}
+
+	// get required env variables
+	a.appClientId = utils.GetEnvs(logs, "azure_app_client_id", "")
+	a.cloudName = utils.GetEnvs(logs, "azure_cloud_name", utils.DefaultAzureCloudName)
+	a.authorityHost = utils.GetEnvs(logs, "azure_authority_host", "")
+	if _, err := utils.AzureAuthorityHost(a.cloudName, a.authorityHost); err != nil {
+		return handlers.ConfigError(handlers.CodeConfigInvalid, "%v", err)
+	}
+	a.tenantId = utils.GetEnvs(logs, "azure_tenant_id", "")
+	a.appAudience = utils.GetEnvs(logs, "azure_app_audience", "")
+	a.appURI = utils.GetEnvs(logs, "azure_app_uri", "api://"+a.appClientId)
+	a.nodepoolClientId = utils.GetEnvs(logs, "azure_nodepool_client_id", "")
+	a.providerName = utils.GetEnvs(logs, "jfrog_oidc_provider_name", "")
+	a.audience = utils.GetEnvs(logs, "jfrog_token_audience", "")
+	if a.audience == "" {
+		a.audience = "*@*"
+	}
+	a.podIdentity = request.ServiceAccountAnnotations["JFrogExchange"] == "true"
+
+	if a.authMethod == "workload_identity_exchange" {
+		return a.validateWorkloadIdentityExchange(logs, request)
+	}
+	if a.authMethod == "imds_direct" && !a.podIdentity {
+		if a.appClientId == "" || a.nodepoolClientId == "" || a.appURI == "" || a.providerName == "" {
+			return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: azure_app_client_id, azure_nodepool_client_id, azureAppURI, jfrog_oidc_provider_name")
+		}
+		logs.Info(fmt.Sprintf("getting envs - azureAppClientId: %s, azureNodepoolClientId: %s, azureAppURI: %s, jfrogOidcProviderName: %s",
+			a.appClientId, a.nodepoolClientId, a.appURI, a.providerName))
+	} else if !a.podIdentity {
+		if a.appClientId == "" || a.tenantId == "" || a.nodepoolClientId == "" || a.appAudience == "" || a.providerName == "" {
+			return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: azure_app_client_id, azure_tenant_id, azure_nodepool_client_id, azureAppAudience, jfrog_oidc_provider_name")
+		}
+		logs.Info(fmt.Sprintf("getting envs - azureAppClientId: %s, azureAppCloudName: %s, azureNodepoolClientId: %s, azureAppAudience: %s, azureAppTenantId: %s, jfrogOidcProviderName: %s",
+			a.appClientId, a.cloudName, a.nodepoolClientId, a.appAudience, a.tenantId, a.providerName))
+	} else {
+		if a.appAudience == "" || a.providerName == "" {
+			return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: azureAppAudience, jfrog_oidc_provider_name")
+		}
+		logs.Info(fmt.Sprintf("getting envs - azureAppAudience: %s, jfrogOidcProviderName: %s",
+			a.appAudience, a.providerName))
+	}
+	return nil
+}
+
+// validateWorkloadIdentityExchange reads the app and tenant of the pod's
+// Azure workload identity, the annotations win over the provider env.
+func (a *azureSource) validateWorkloadIdentityExchange(logs *logger.Logger, request utils.CredentialProviderRequest) error {
+	if request.ServiceAccountToken == "" {
+		return handlers.NewCredentialError(handlers.CodeRequestInvalid, "read service account token", fmt.Errorf("no service account token in the request, tokenAttributes must be configured for azure_auth_method workload_identity_exchange"))
+	}
+	if clientId := request.ServiceAccountAnnotations[utils.AzureWorkloadIdentityClientIDAnnotation]; clientId != "" {
+		a.appClientId = clientId
+	} else {
+		return handlers.NewCredentialError(handlers.CodeRequestInvalid, "read service account annotations", fmt.Errorf("service account has no %s annotation", utils.AzureWorkloadIdentityClientIDAnnotation))
+	}
+	if tenantId := request.ServiceAccountAnnotations[utils.AzureWorkloadIdentityTenantIDAnnotation]; tenantId != "" {
+		a.tenantId = tenantId
+	}
+	if a.tenantId == "" || a.providerName == "" {
+		return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: azure_tenant_id (or the %s annotation), jfrog_oidc_provider_name", utils.AzureWorkloadIdentityTenantIDAnnotation)
+	}
+	logs.Info(fmt.Sprintf("getting envs - azureAppClientId: %s, azureAppCloudName: %s, azureAppTenantId: %s, jfrogOidcProviderName: %s",
+		a.appClientId, a.cloudName, a.tenantId, a.providerName))
+	return nil
+}
+
+func (a *azureSource) ObtainSubjectToken(svc *service.Service, ctx context.Context, logs *logger.Logger, request utils.CredentialProviderRequest) (SubjectToken, error) {
+	if a.authMethod == "workload_identity_exchange" {
+		logs.Info("Service Account Token exchanged with Azure AD using Azure Workload Identity")
+		token, err := handlers.ExchangeAzureClientAssertion(svc, ctx, a.tenantId, a.appClientId, request.ServiceAccountToken, a.cloudName, a.authorityHost)
+		if err != nil {
+			return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "ExchangeAzureClientAssertion", err)
+		}
+		return SubjectToken{Token: token}, nil
+	}
+	if a.podIdentity {
+		logs.Info("Service Account Token obtained using Pod Identity (Kubernetes Workload Identity)")
+		return SubjectToken{Token: request.ServiceAccountToken}, nil
+	}
+	if a.authMethod == "imds_direct" {
+		logs.Info("Service Account Token obtained using Node Managed Identity (IMDS direct app access token)")
+		token, err := handlers.GetAzureClusterIdentity(svc, ctx, a.appURI, a.nodepoolClientId)
+		if err != nil {
+			return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "GetAzureClusterIdentity", err)
+		}
+		return SubjectToken{Token: token}, nil
+	}
+	logs.Info("Service Account Token obtained using Node Identity (VM Service Account)")
+	token, err := handlers.GetAzureOIDCToken(svc, ctx, a.tenantId, a.appClientId, a.nodepoolClientId, a.appAudience, a.cloudName, a.authorityHost)
+	if err != nil {
+		return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "GetAzureOIDCToken", err)
+	}
+	return SubjectToken{Token: token}, nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"slices"
+)
+
+func init() {
+	RegisterCredentialSource(utils.CloudProviderGoogle, googleSchema, func() CredentialSource { return &googleSource{} })
+}
+
+// googleSchema is the provider env of googleSource.
+var googleSchema = utils.ConfigSchema{
+	Env: []string{
+		"google_auth_method", "google_service_account_email", "google_workload_identity_audience",
+		"jfrog_oidc_provider_name", "jfrog_oidc_audience",
+		"google_metadata_endpoint", "google_iamcredentials_endpoint", "google_sts_endpoint",
+	},
+	Required:  []string{"jfrog_oidc_provider_name", "jfrog_oidc_audience"},
+	MethodEnv: "google_auth_method",
+	Methods: map[string][]string{
+		"": {"google_service_account_email"},
+		// exchanges the pod's token with Google STS, then impersonates the
+		// service account of its iam.gke.io/gcp-service-account annotation
+		"workload_identity_federation": {"google_workload_identity_audience"},
+		// ID token from the metadata server's identity endpoint, for the node's
+		// default service account unless google_service_account_email is set
+		"metadata_identity": nil,
+	},
+	ServiceAccountToken: map[string]utils.TokenAttributes{
+		"workload_identity_federation": {RequiredServiceAccountAnnotationKeys: []string{utils.GoogleServiceAccountAnnotation}},
+	},
+	Validate: func(config utils.Provider) error {
+		if utils.GetEnvVarValue(config.Env, "google_auth_method") != "workload_identity_federation" {
+			return nil
+		}
+		if config.TokenAttributes == nil || config.TokenAttributes.ServiceAccountTokenAudience == "" ||
+			!slices.Contains(config.TokenAttributes.RequiredServiceAccountAnnotationKeys, utils.GoogleServiceAccountAnnotation) {
+			return fmt.Errorf("google_auth_method workload_identity_federation requires tokenAttributes with serviceAccountTokenAudience and the %s annotation in requiredServiceAccountAnnotationKeys", utils.GoogleServiceAccountAnnotation)
+		}
+		return nil
+	},
+}
+
+// googleSource gets an ID token for a Google service account through the
+// node's metadata server (impersonation, or the identity endpoint with
+// metadata_identity) or, with workload_identity_federation, through the pod's
+// federated identity, or exchanges the pod's own token.
+type googleSource struct {
+	oidcExchange
+	authMethod               string
+	serviceAccountEmail      string
+	workloadIdentityAudience string
+}
+
+func (g *googleSource) Detect(svc *service.Service, ctx context.Context) (bool, error) {
+	return handlers.CheckIfGoogle(svc, ctx)
+}
+
+func (g *googleSource) ValidateConfig(logs *logger.Logger, request utils.CredentialProviderRequest) error {
+	g.authMethod = utils.GetEnvs(logs, "google_auth_method", "")
+	g.audience = utils.GetEnvs(logs, "jfrog_oidc_audience", "")
+	g.providerName = utils.GetEnvs(logs, "jfrog_oidc_provider_name", "")
+	switch g.authMethod {
+	case "":
+		g.serviceAccountEmail = utils.GetEnvs(logs, "google_service_account_email", "")
+		if g.serviceAccountEmail == "" || g.audience == "" || g.providerName == "" {
+			return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: google_service_account_email, jfrog_oidc_audience, jfrog_oidc_provider_name")
+		}
+	case "workload_identity_federation":
+		g.workloadIdentityAudience = utils.GetEnvs(logs, "google_workload_identity_audience", "")
+		if g.workloadIdentityAudience == "" || g.audience == "" || g.providerName == "" {
+			return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: google_workload_identity_audience, jfrog_oidc_audience, jfrog_oidc_provider_name")
+		}
+		if request.ServiceAccountToken == "" {
+			return handlers.NewCredentialError(handlers.CodeRequestInvalid, "read service account token", fmt.Errorf("no service account token in the request, tokenAttributes must be configured for google_auth_method workload_identity_federation"))
+		}
+		// each pod pulls as the Google service account bound to its own service account
+		g.serviceAccountEmail = request.ServiceAccountAnnotations[utils.GoogleServiceAccountAnnotation]
+		if g.serviceAccountEmail == "" {
+			return handlers.NewCredentialError(handlers.CodeRequestInvalid, "read service account annotations", fmt.Errorf("service account has no %s annotation", utils.GoogleServiceAccountAnnotation))
+		}
+	case "metadata_identity":
+		g.serviceAccountEmail = utils.GetEnvs(logs, "google_service_account_email", "default")
+		if g.audience == "" || g.providerName == "" {
+			return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: jfrog_oidc_audience, jfrog_oidc_provider_name")
+		}
+	default:
+		return handlers.ConfigError(handlers.CodeConfigInvalid, "wrong google_auth_method value :%s", g.authMethod)
+	}
+	logs.Info(fmt.Sprintf("getting envs - googleServiceAccountEmail: %s, jfrogOidcProviderAudience: %s, jfrogOidcProviderName: %s",
+		g.serviceAccountEmail, g.audience, g.providerName))
+	return nil
+}
+
+func (g *googleSource) ObtainSubjectToken(svc *service.Service, ctx context.Context, logs *logger.Logger, request utils.CredentialProviderRequest) (SubjectToken, error) {
+	if g.authMethod == "workload_identity_federation" {
+		logs.Info("Service Account Token obtained using Workload Identity Federation (Google STS) for " + g.serviceAccountEmail)
+		token, err := handlers.GetGoogleWorkloadIdentityOIDCToken(svc, ctx, request.ServiceAccountToken, g.workloadIdentityAudience, g.serviceAccountEmail, g.audience)
+		if err != nil {
+			return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "GetGoogleWorkloadIdentityOIDCToken", err)
+		}
+		return SubjectToken{Token: token}, nil
+	}
+	if request.ServiceAccountAnnotations["JFrogExchange"] == "true" {
+		logs.Info("Service Account Token obtained using Pod Identity (Kubernetes Workload Identity)")
+		return SubjectToken{Token: request.ServiceAccountToken}, nil
+	}
+	if g.authMethod == "metadata_identity" {
+		logs.Info("Service Account Token obtained using the metadata server identity endpoint for " + g.serviceAccountEmail)
+		token, err := handlers.GetGoogleMetadataIdentityToken(svc, ctx, g.serviceAccountEmail, g.audience)
+		if err != nil {
+			return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "GetGoogleMetadataIdentityToken", err)
+		}
+		return SubjectToken{Token: token}, nil
+	}
+	// Get Google OIDC token
+	logs.Info("Service Account Token obtained using Node Identity (VM Service Account)")
+	token, err := handlers.GetGoogleOIDCToken(svc, ctx, g.serviceAccountEmail, g.audience)
+	if err != nil {
+		return SubjectToken{}, handlers.NewCredentialError(handlers.CodeCloudAuthFailed, "GetGoogleOIDCToken", err)
+	}
+	return SubjectToken{Token: token}, nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+)
+
+func init() {
+	RegisterCredentialSource(utils.CloudProviderKubernetes, kubernetesSchema, func() CredentialSource { return &kubernetesSource{} })
+}
+
+// kubernetesSchema is the provider env of kubernetesSource.
+var kubernetesSchema = utils.ConfigSchema{
+	Env:      []string{"jfrog_oidc_provider_name", "jfrog_token_audience"},
+	Required: []string{"jfrog_oidc_provider_name"},
+	ServiceAccountToken: map[string]utils.TokenAttributes{
+		"": {},
+	},
+	Validate: func(config utils.Provider) error {
+		// the kubelet only sends a service account token when tokenAttributes are configured
+		if config.TokenAttributes == nil || config.TokenAttributes.ServiceAccountTokenAudience == "" {
+			return fmt.Errorf("cloud_provider kubernetes requires tokenAttributes with serviceAccountTokenAudience to be set")
+		}
+		return nil
+	},
+}
+
+// kubernetesSource exchanges the projected service account token sent by the
+// kubelet (tokenAttributes) directly with a JFrog OIDC provider that trusts
+// the cluster's service account issuer.
+type kubernetesSource struct {
+	notDetectable
+	oidcExchange
+}
+
+func (k *kubernetesSource) ValidateConfig(logs *logger.Logger, request utils.CredentialProviderRequest) error {
+	k.providerName = utils.GetEnvs(logs, "jfrog_oidc_provider_name", "")
+	k.audience = utils.GetEnvs(logs, "jfrog_token_audience", "*@*")
+	if k.providerName == "" {
+		return handlers.ConfigError(handlers.CodeConfigMissing, "environment variables missing: jfrog_oidc_provider_name")
+	}
+	logs.Info(fmt.Sprintf("getting envs - jfrogOidcProviderName: %s, jfrogTokenAudience: %s", k.providerName, k.audience))
+
+	if request.ServiceAccountToken == "" {
+		return handlers.NewCredentialError(handlers.CodeRequestInvalid, "read service account token", fmt.Errorf("no service account token in the request, tokenAttributes must be configured for cloud_provider kubernetes"))
+	}
+	return nil
+}
+
+func (k *kubernetesSource) ObtainSubjectToken(_ *service.Service, _ context.Context, logs *logger.Logger, request utils.CredentialProviderRequest) (SubjectToken, error) {
+	logs.Info("Service Account Token obtained from the kubelet (Kubernetes projected service account token)")
+	return SubjectToken{Token: request.ServiceAccountToken}, nil
+}
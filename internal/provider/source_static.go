@@ -0,0 +1,104 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/cache"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+)
+
+func init() {
+	RegisterCredentialSource(utils.CloudProviderStatic, staticSchema, func() CredentialSource { return &staticSource{} })
+}
+
+// staticSchema is the provider env of staticSource.
+var staticSchema = utils.ConfigSchema{
+	Env: []string{"jfrog_access_token_file", "jfrog_refresh_token_file"},
+	// only file paths are accepted so tokens never end up in the kubelet config
+	// and the mounted token is served with its own scope, the refresh grant
+	// cannot narrow it
+	Forbidden: map[string]string{
+		"jfrog_access_token":      "mount the token and set jfrog_access_token_file instead",
+		"jfrog_refresh_token":     "mount the token and set jfrog_refresh_token_file instead",
+		"jfrog_token_scope":       "the mounted token keeps the scope it was created with",
+		"image_prefix_scopes":     "the mounted token keeps the scope it was created with",
+		"jfrog_token_refreshable": "whether the mounted token is refreshable depends on jfrog_refresh_token_file",
+	},
+	Validate: func(config utils.Provider) error {
+		return validateStaticEnv(
+			utils.GetEnvVarValue(config.Env, "jfrog_access_token_file"),
+			utils.GetEnvVarValue(config.Env, "jfrog_refresh_token_file"),
+			utils.GetEnvVarValue(config.Env, "artifactory_user"),
+		)
+	},
+}
+
+// validateStaticEnv requires an access token file, or a refresh token file
+// and the user the refresh grant issues the first access token for.
+func validateStaticEnv(accessTokenFile, refreshTokenFile, username string) error {
+	if accessTokenFile != "" {
+		return nil
+	}
+	if refreshTokenFile == "" {
+		return fmt.Errorf("environment variables missing: jfrog_access_token_file, or jfrog_refresh_token_file and artifactory_user")
+	}
+	if username == "" {
+		return fmt.Errorf("environment variables missing: artifactory_user is required when only jfrog_refresh_token_file is set")
+	}
+	return nil
+}
+
+// staticSource serves the access token mounted on the node, renewing it with
+// the refresh grant when a refresh token file is mounted as well, or gets
+// every access token from the refresh grant when only the refresh token is
+// mounted. It has no subject token, Exchange reads the mounted files.
+type staticSource struct {
+	notDetectable
+	creds handlers.StaticCredentials
+}
+
+func (s *staticSource) ValidateConfig(logs *logger.Logger, _ utils.CredentialProviderRequest) error {
+	s.creds = handlers.StaticCredentials{
+		AccessTokenFile:  utils.GetEnvs(logs, "jfrog_access_token_file", ""),
+		RefreshTokenFile: utils.GetEnvs(logs, "jfrog_refresh_token_file", ""),
+		Username:         utils.GetEnvs(logs, "artifactory_user", ""),
+		StateDir:         utils.GetEnvs(logs, "token_cache_dir", cache.DefaultCacheDir),
+	}
+	if err := validateStaticEnv(s.creds.AccessTokenFile, s.creds.RefreshTokenFile, s.creds.Username); err != nil {
+		return handlers.ConfigError(handlers.CodeConfigMissing, "%s", err)
+	}
+	// read without GetEnvs, which would log an inline token
+	for name, reason := range staticSchema.Forbidden {
+		if os.Getenv(name) != "" {
+			return handlers.ConfigError(handlers.CodeConfigInvalid, "%s is not supported with cloud_provider static, %s", name, reason)
+		}
+	}
+	logs.Info(fmt.Sprintf("getting envs - jfrogAccessTokenFile: %s, jfrogRefreshTokenFile: %s", s.creds.AccessTokenFile, s.creds.RefreshTokenFile))
+	return nil
+}
+
+func (s *staticSource) ObtainSubjectToken(*service.Service, context.Context, *logger.Logger, utils.CredentialProviderRequest) (SubjectToken, error) {
+	return SubjectToken{}, nil
+}
+
+func (s *staticSource) Exchange(svc *service.Service, ctx context.Context, _ SubjectToken, artifactoryUrl, _ string, _ handlers.TokenOptions) (handlers.ArtifactoryToken, error) {
+	return handlers.GetStaticArtifactoryToken(svc, ctx, artifactoryUrl, s.creds)
+}
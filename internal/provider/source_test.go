@@ -0,0 +1,293 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"errors"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// fakeSource is a credential source answering without any cloud.
+type fakeSource struct {
+	notDetectable
+	subject string
+}
+
+func (f *fakeSource) ValidateConfig(*logger.Logger, utils.CredentialProviderRequest) error {
+	f.subject = "fake-subject"
+	return nil
+}
+
+func (f *fakeSource) ObtainSubjectToken(*service.Service, context.Context, *logger.Logger, utils.CredentialProviderRequest) (SubjectToken, error) {
+	return SubjectToken{Token: f.subject}, nil
+}
+
+func (f *fakeSource) Exchange(_ *service.Service, _ context.Context, subject SubjectToken, _, _ string, _ handlers.TokenOptions) (handlers.ArtifactoryToken, error) {
+	return handlers.ArtifactoryToken{Username: "fake-user", AccessToken: "exchanged-" + subject.Token}, nil
+}
+
+func TestRegisteredSourceRunsTheFlow(t *testing.T) {
+	RegisterCredentialSource("fake", utils.ConfigSchema{}, func() CredentialSource { return &fakeSource{} })
+	t.Cleanup(func() {
+		credentialSources = slices.DeleteFunc(credentialSources, func(e credentialSourceEntry) bool { return e.name == "fake" })
+	})
+	t.Setenv("cloud_provider", "fake")
+
+	svc := service.NewService(http.DefaultClient, *testLogger())
+	token, err := cloudProviderAuth(svc, context.Background(), testLogger(), "example.jfrog.io", defaultSecretTTL, utils.CredentialProviderRequest{}, handlers.TokenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Username != "fake-user" || token.AccessToken != "exchanged-fake-subject" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+}
+
+func TestBuiltinSourcesAreRegistered(t *testing.T) {
+	names := credentialSourceNames()
+	for _, name := range []string{utils.CloudProviderAWS, utils.CloudProviderAzure, utils.CloudProviderGoogle, utils.CloudProviderKubernetes, utils.CloudProviderStatic} {
+		if !slices.Contains(names, name) {
+			t.Errorf("%s is not registered", name)
+		}
+		if _, ok := utils.GetConfigSchema(name); !ok {
+			t.Errorf("%s has no config schema", name)
+		}
+	}
+}
+
+func TestDetectionSkipsUndetectableSources(t *testing.T) {
+	for _, name := range []string{utils.CloudProviderKubernetes, utils.CloudProviderStatic} {
+		source, _ := newCredentialSource(name)
+		if _, err := source.Detect(nil, context.Background()); !errors.Is(err, errNotDetectable) {
+			t.Errorf("expected %s not to be detectable, got %v", name, err)
+		}
+	}
+}
+
+func TestAzureWorkloadIdentityExchange(t *testing.T) {
+	aad := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/pod-tenant/oauth2/v2.0/token" || r.PostForm.Get("client_id") != "pod-app" || r.PostForm.Get("client_assertion") != "projected-sa-token" {
+			http.Error(w, "unexpected exchange "+r.URL.Path+" "+r.PostForm.Encode(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"token_type":"Bearer","access_token":"entra-app-token","expires_in":3599}`))
+	}))
+	defer aad.Close()
+	t.Setenv("azure_auth_method", "workload_identity_exchange")
+	t.Setenv("azure_authority_host", aad.URL)
+	t.Setenv("azure_tenant_id", "env-tenant")
+	t.Setenv("jfrog_oidc_provider_name", "entra-oidc")
+
+	request := utils.CredentialProviderRequest{
+		ServiceAccountToken: "projected-sa-token",
+		ServiceAccountAnnotations: map[string]string{
+			utils.AzureWorkloadIdentityClientIDAnnotation: "pod-app",
+			utils.AzureWorkloadIdentityTenantIDAnnotation: "pod-tenant",
+		},
+	}
+	source, _ := newCredentialSource(utils.CloudProviderAzure)
+	if err := source.ValidateConfig(testLogger(), request); err != nil {
+		t.Fatal(err)
+	}
+	subject, err := source.ObtainSubjectToken(service.NewService(aad.Client(), *testLogger()), context.Background(), testLogger(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject.Token != "entra-app-token" {
+		t.Fatalf("expected the Azure AD token as subject, got %q", subject.Token)
+	}
+
+	delete(request.ServiceAccountAnnotations, utils.AzureWorkloadIdentityClientIDAnnotation)
+	if err := source.ValidateConfig(testLogger(), request); handlers.ErrorCodeOf(err) != handlers.CodeRequestInvalid {
+		t.Fatalf("expected %s without the client-id annotation, got %v", handlers.CodeRequestInvalid, err)
+	}
+}
+
+func jfrogProvider(env []utils.EnvVar, tokenAttributes *utils.TokenAttributes) utils.Provider {
+	return utils.Provider{
+		Name:                 "jfrog-credentials-provider",
+		MatchImages:          []string{"example.jfrog.io"},
+		DefaultCacheDuration: "4h",
+		APIVersion:           "credentialprovider.kubelet.k8s.io/v1",
+		TokenAttributes:      tokenAttributes,
+		Env:                  env,
+	}
+}
+
+func TestValidateJfrogProviderConfigKubernetes(t *testing.T) {
+	tokenAttributes := &utils.TokenAttributes{ServiceAccountTokenAudience: "jfrog", CacheType: "ServiceAccount", RequireServiceAccount: true}
+	env := []utils.EnvVar{
+		{Name: "artifactory_url", Value: "example.jfrog.io"},
+		{Name: "cloud_provider", Value: utils.CloudProviderKubernetes},
+		{Name: "jfrog_oidc_provider_name", Value: "k8s-oidc"},
+	}
+
+	// cloud_provider from the env wins over the detected one
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env, tokenAttributes), utils.CloudProviderAWS); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env, nil), ""); err == nil {
+		t.Fatal("expected error when tokenAttributes are missing")
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env[:2], tokenAttributes), ""); err == nil {
+		t.Fatal("expected error when jfrog_oidc_provider_name is missing")
+	}
+}
+
+func TestValidateJfrogProviderConfigStatic(t *testing.T) {
+	env := []utils.EnvVar{
+		{Name: "artifactory_url", Value: "example.jfrog.io"},
+		{Name: "cloud_provider", Value: utils.CloudProviderStatic},
+		{Name: "jfrog_access_token_file", Value: "/etc/jfrog/access-token"},
+	}
+
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env, nil), ""); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env[:2], nil), ""); err == nil {
+		t.Fatal("expected error when jfrog_access_token_file is missing")
+	}
+	inline := append(env[:3:3], utils.EnvVar{Name: "jfrog_access_token", Value: "secret"})
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(inline, nil), ""); err == nil {
+		t.Fatal("expected error when the token is set inline")
+	}
+
+	for _, name := range []string{"jfrog_token_scope", "image_prefix_scopes", "jfrog_token_refreshable"} {
+		value := "applied-permissions/groups:readers"
+		switch name {
+		case "image_prefix_scopes":
+			value = `{"docker-remote": "applied-permissions/groups:readers"}`
+		case "jfrog_token_refreshable":
+			value = "true"
+		}
+		scoped := append(env[:3:3], utils.EnvVar{Name: name, Value: value})
+		if err := utils.ValidateJfrogProviderConfig(jfrogProvider(scoped, nil), ""); err == nil {
+			t.Fatalf("expected error when %s is set with static credentials", name)
+		}
+	}
+
+	refreshOnly := append(env[:2:2], utils.EnvVar{Name: "jfrog_refresh_token_file", Value: "/etc/jfrog/refresh-token"})
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(refreshOnly, nil), ""); err == nil {
+		t.Fatal("expected error when only the refresh token is set without artifactory_user")
+	}
+	refreshOnly = append(refreshOnly, utils.EnvVar{Name: "artifactory_user", Value: "ci-puller"})
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(refreshOnly, nil), ""); err != nil {
+		t.Fatalf("expected refresh token and artifactory_user to be valid, got %v", err)
+	}
+}
+
+func TestStaticSourceRejectsTokenScope(t *testing.T) {
+	t.Setenv("jfrog_access_token_file", "/etc/jfrog/access-token")
+	source := &staticSource{}
+	if err := source.ValidateConfig(testLogger(), utils.CredentialProviderRequest{}); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	t.Setenv("jfrog_token_scope", "applied-permissions/groups:readers")
+	if err := source.ValidateConfig(testLogger(), utils.CredentialProviderRequest{}); handlers.ErrorCodeOf(err) != handlers.CodeConfigInvalid {
+		t.Fatalf("expected %s for jfrog_token_scope, got %v", handlers.CodeConfigInvalid, err)
+	}
+}
+
+func TestValidateJfrogProviderConfigAuthMethods(t *testing.T) {
+	base := []utils.EnvVar{{Name: "artifactory_url", Value: "example.jfrog.io"}}
+	with := func(env ...utils.EnvVar) []utils.EnvVar { return append(base[:1:1], env...) }
+
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(with(utils.EnvVar{Name: "aws_auth_method", Value: "web_identity_v2"}), nil), utils.CloudProviderAWS); err == nil {
+		t.Fatal("expected error for an unknown aws_auth_method")
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(with(utils.EnvVar{Name: "aws_auth_method", Value: "assume_external_role"}), nil), utils.CloudProviderAWS); err == nil {
+		t.Fatal("expected error when aws_external_role_arn is missing")
+	}
+
+	// pod identity only needs the audience and the OIDC provider
+	podIdentity := &utils.TokenAttributes{ServiceAccountTokenAudience: "api://AzureADTokenExchange", RequiredServiceAccountAnnotationKeys: []string{"JFrogExchange"}}
+	azure := with(utils.EnvVar{Name: "azure_app_audience", Value: "api://AzureADTokenExchange"}, utils.EnvVar{Name: "jfrog_oidc_provider_name", Value: "azure-oidc"})
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(azure, podIdentity), utils.CloudProviderAzure); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(azure, nil), utils.CloudProviderAzure); err == nil {
+		t.Fatal("expected error when the node identity env is missing")
+	}
+}
+
+func TestValidateJfrogProviderConfigAzureCloudName(t *testing.T) {
+	podIdentity := &utils.TokenAttributes{ServiceAccountTokenAudience: "api://AzureADTokenExchange", RequiredServiceAccountAnnotationKeys: []string{"JFrogExchange"}}
+	with := func(env ...utils.EnvVar) utils.Provider {
+		base := []utils.EnvVar{{Name: "artifactory_url", Value: "example.jfrog.io"}, {Name: "azure_app_audience", Value: "api://AzureADTokenExchange"}, {Name: "jfrog_oidc_provider_name", Value: "azure-oidc"}}
+		return jfrogProvider(append(base, env...), podIdentity)
+	}
+
+	for _, cloudName := range []string{"", "AzureCloud", "AzureChinaCloud", "AzureUSGovernment", "AzureGermanCloud"} {
+		if err := utils.ValidateJfrogProviderConfig(with(utils.EnvVar{Name: "azure_cloud_name", Value: cloudName}), utils.CloudProviderAzure); err != nil {
+			t.Fatalf("expected %q to be accepted, got %v", cloudName, err)
+		}
+	}
+	if err := utils.ValidateJfrogProviderConfig(with(utils.EnvVar{Name: "azure_cloud_name", Value: "AzureUSGovernmnet"}), utils.CloudProviderAzure); err == nil {
+		t.Fatal("expected error for a misspelled azure_cloud_name")
+	}
+	// air-gapped clouds name their own authority host
+	airGapped := with(utils.EnvVar{Name: "azure_cloud_name", Value: "AzureUSSec"}, utils.EnvVar{Name: "azure_authority_host", Value: "https://login.example.scloud/"})
+	if err := utils.ValidateJfrogProviderConfig(airGapped, utils.CloudProviderAzure); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if err := utils.ValidateJfrogProviderConfig(with(utils.EnvVar{Name: "azure_authority_host", Value: "login.example.scloud"}), utils.CloudProviderAzure); err == nil {
+		t.Fatal("expected error for an azure_authority_host without https scheme")
+	}
+}
+
+func TestValidateJfrogProviderConfigAzureWorkloadIdentityExchange(t *testing.T) {
+	env := []utils.EnvVar{
+		{Name: "artifactory_url", Value: "example.jfrog.io"},
+		{Name: "azure_auth_method", Value: "workload_identity_exchange"},
+		{Name: "jfrog_oidc_provider_name", Value: "entra-oidc"},
+	}
+	workloadIdentity := &utils.TokenAttributes{ServiceAccountTokenAudience: "api://AzureADTokenExchange", RequiredServiceAccountAnnotationKeys: []string{utils.AzureWorkloadIdentityClientIDAnnotation}}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env, workloadIdentity), utils.CloudProviderAzure); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env, nil), utils.CloudProviderAzure); err == nil {
+		t.Fatal("expected error without tokenAttributes")
+	}
+}
+
+func TestValidateJfrogProviderConfigGoogleWorkloadIdentityFederation(t *testing.T) {
+	env := []utils.EnvVar{
+		{Name: "artifactory_url", Value: "example.jfrog.io"},
+		{Name: "google_auth_method", Value: "workload_identity_federation"},
+		{Name: "google_workload_identity_audience", Value: "identitynamespace:project.svc.id.goog:https://container.googleapis.com/v1/projects/project/locations/us-central1/clusters/jfrog"},
+		{Name: "jfrog_oidc_audience", Value: "jfrog"},
+		{Name: "jfrog_oidc_provider_name", Value: "google-oidc"},
+	}
+	workloadIdentity := &utils.TokenAttributes{ServiceAccountTokenAudience: "project.svc.id.goog", RequiredServiceAccountAnnotationKeys: []string{utils.GoogleServiceAccountAnnotation}}
+	// the Google service account comes from the annotation, not the env
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env, workloadIdentity), utils.CloudProviderGoogle); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env, nil), utils.CloudProviderGoogle); err == nil {
+		t.Fatal("expected error without tokenAttributes")
+	}
+	if err := utils.ValidateJfrogProviderConfig(jfrogProvider(env[:2:2], workloadIdentity), utils.CloudProviderGoogle); err == nil {
+		t.Fatal("expected error when google_workload_identity_audience is missing")
+	}
+}
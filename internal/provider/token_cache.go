@@ -0,0 +1,190 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/cache"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/logger"
+	"jfrog-credential-provider/internal/utils"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// tokenCacheKey identifies the Artifactory token a request resolves to: the
+// target Artifactory, the provider settings (configFingerprint, so any change
+// of the role, role chain, session settings, auth method or token files
+// starts a new entry), the AWS SDK env the pod credential flows fall back to,
+// the service account annotations and subject the kubelet sends and the
+// requested token scope.
+func tokenCacheKey(artifactoryUrl string, request utils.CredentialProviderRequest, tokenOptions handlers.TokenOptions) string {
+	parts := []string{artifactoryUrl, configFingerprint()}
+	for _, name := range []string{"AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"} {
+		parts = append(parts, os.Getenv(name))
+	}
+	annotations := make([]string, 0, len(request.ServiceAccountAnnotations))
+	for key, value := range request.ServiceAccountAnnotations {
+		annotations = append(annotations, key+"="+value)
+	}
+	slices.Sort(annotations)
+	parts = append(parts, cache.Key(annotations...))
+	return cache.Key(append(parts, handlers.TokenClaimsFromJWT(request.ServiceAccountToken).Subject, tokenOptions.Scope)...)
+}
+
+// newCacheEntry computes the expiry of a freshly exchanged token. The
+// lifetime reported by Artifactory wins, then the exp claim of the access
+// token; secret_ttl_seconds is only used when neither is known. The result
+// never outlives the exp claim.
+func newCacheEntry(logs *logger.Logger, token handlers.ArtifactoryToken, issuedAt time.Time, secretTTL string) cache.Entry {
+	entry := cache.Entry{Username: token.Username, Token: token.AccessToken}
+	// static credentials rotate their own refresh token, refreshing it here
+	// as well would consume it behind their back
+	static := os.Getenv("cloud_provider") == utils.CloudProviderStatic
+	if !static {
+		entry.RefreshToken = token.RefreshToken
+	}
+	var expiresAt time.Time
+	if claims := handlers.TokenClaimsFromJWT(token.AccessToken); claims.ExpiresAt > 0 {
+		expiresAt = time.Unix(claims.ExpiresAt, 0)
+	}
+	if token.ExpiresIn > 0 {
+		entry.ExpiresAt = issuedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+	} else if !expiresAt.IsZero() {
+		entry.ExpiresAt = expiresAt
+	} else if static {
+		// secret_ttl_seconds only applies to tokens the provider requests,
+		// a mounted token of unknown lifetime is read again on every pull
+		logs.Info("Static access token has no expiry claim")
+	} else if ttl, err := strconv.Atoi(secretTTL); err == nil && ttl > 0 {
+		entry.ExpiresAt = issuedAt.Add(time.Duration(ttl) * time.Second)
+	} else {
+		logs.Info("Artifactory did not report a token lifetime and secret_ttl_seconds is not a positive number")
+	}
+	if !expiresAt.IsZero() && entry.ExpiresAt.After(expiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+	return entry
+}
+
+// tokenCacheSafetyMargin returns how long before expiry a cached token is
+// no longer served.
+func tokenCacheSafetyMargin(logs *logger.Logger) time.Duration {
+	safetyMargin := cache.DefaultSafetyMargin
+	if v := utils.GetEnvs(logs, "token_cache_safety_margin_seconds", ""); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			safetyMargin = time.Duration(n) * time.Second
+		} else {
+			logs.Info("bad value for token_cache_safety_margin_seconds, defaulting to " + safetyMargin.String())
+		}
+	}
+	return safetyMargin
+}
+
+// cachedCloudProviderAuth serves the Artifactory token from the on-node cache
+// when a valid one exists, otherwise runs cloudProviderAuth and stores the
+// result. The cache file is only locked to read and write it; a lock on the
+// key alone is held across the exchange so a burst of parallel invocations
+// for the same token results in a single exchange, without blocking pulls
+// resolving to other tokens.
+func cachedCloudProviderAuth(svc *service.Service, ctx context.Context, logs *logger.Logger, artifactoryUrl, secretTTL string, request utils.CredentialProviderRequest, tokenOptions handlers.TokenOptions) (cache.Entry, error) {
+	exchange := func() (cache.Entry, error) {
+		issuedAt := time.Now()
+		token, err := cloudProviderAuth(svc, ctx, logs, artifactoryUrl, secretTTL, request, tokenOptions)
+		if err != nil {
+			return cache.Entry{}, err
+		}
+		return newCacheEntry(logs, token, issuedAt, secretTTL), nil
+	}
+
+	if utils.GetEnvsBool(logs, "disable_token_cache", false) {
+		logs.Info("Token cache is disabled")
+		return exchange()
+	}
+
+	cacheDir := utils.GetEnvs(logs, "token_cache_dir", cache.DefaultCacheDir)
+	tokenCache, err := cache.Open(logs, cacheDir, tokenCacheSafetyMargin(logs))
+	if err != nil {
+		logs.Error("Could not open token cache, continuing without it: " + err.Error())
+		return exchange()
+	}
+
+	key := tokenCacheKey(artifactoryUrl, request, tokenOptions)
+	if entry, ok := tokenCache.Get(key); ok {
+		logs.Info("Using cached Artifactory token, expires at " + entry.ExpiresAt.UTC().Format(time.RFC3339))
+		return entry, nil
+	}
+
+	unlock, err := tokenCache.Lock(key)
+	if err != nil {
+		logs.Error("Could not lock token cache key, continuing without it: " + err.Error())
+		return exchange()
+	}
+	defer unlock()
+	// another invocation may have stored the token while this one waited
+	if entry, ok := tokenCache.Get(key); ok {
+		logs.Info("Using cached Artifactory token, expires at " + entry.ExpiresAt.UTC().Format(time.RFC3339))
+		return entry, nil
+	}
+
+	if stale, ok := tokenCache.Refreshable(key); ok {
+		if entry, ok := refreshCacheEntry(svc, ctx, logs, artifactoryUrl, secretTTL, stale); ok {
+			if err := tokenCache.Put(key, entry); err != nil {
+				logs.Error("Could not write token cache: " + err.Error())
+			}
+			return entry, nil
+		}
+	}
+
+	entry, err := exchange()
+	if err != nil {
+		return cache.Entry{}, err
+	}
+	if entry.ExpiresAt.IsZero() {
+		logs.Info("Token lifetime is unknown, not caching the token")
+		return entry, nil
+	}
+	if err := tokenCache.Put(key, entry); err != nil {
+		logs.Error("Could not write token cache: " + err.Error())
+	}
+	return entry, nil
+}
+
+// refreshCacheEntry renews an expired cached token with the refresh grant,
+// which avoids the cloud identity round trips of a full exchange. A failed
+// refresh is not fatal, the caller falls back to cloudProviderAuth.
+func refreshCacheEntry(svc *service.Service, ctx context.Context, logs *logger.Logger, artifactoryUrl, secretTTL string, stale cache.Entry) (cache.Entry, bool) {
+	logs.Info("Refreshing cached Artifactory token")
+	issuedAt := time.Now()
+	token, err := handlers.RefreshArtifactoryToken(svc, ctx, artifactoryUrl, stale.Token, stale.RefreshToken)
+	if err != nil {
+		logs.Error("Could not refresh cached Artifactory token, falling back to a full exchange: " + err.Error())
+		return cache.Entry{}, false
+	}
+	// the refresh response carries no username and the token subject may not
+	// name a user (e.g. OIDC mapped tokens), keep the one from the exchange
+	if token.Username == "" {
+		token.Username = stale.Username
+	}
+	entry := newCacheEntry(logs, token, issuedAt, secretTTL)
+	if entry.ExpiresAt.IsZero() {
+		logs.Info("Refreshed token lifetime is unknown, falling back to a full exchange")
+		return cache.Entry{}, false
+	}
+	return entry, true
+}
@@ -0,0 +1,131 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	service "jfrog-credential-provider/internal"
+	"jfrog-credential-provider/internal/cache"
+	"jfrog-credential-provider/internal/handlers"
+	"jfrog-credential-provider/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshCacheEntry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"new-token","refresh_token":"new-refresh","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	svc := service.NewService(server.Client(), *testLogger())
+	stale := cache.Entry{Username: "rt-user", Token: "old-token", RefreshToken: "old-refresh", ExpiresAt: time.Now().Add(-time.Minute)}
+	entry, ok := refreshCacheEntry(svc, context.Background(), testLogger(), strings.TrimPrefix(server.URL, "https://"), "", stale)
+	if !ok {
+		t.Fatal("expected refresh to succeed")
+	}
+	if entry.Username != "rt-user" || entry.Token != "new-token" || entry.RefreshToken != "new-refresh" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if time.Until(entry.ExpiresAt) < 59*time.Minute {
+		t.Fatalf("expected ~1h expiry, got %s", entry.ExpiresAt)
+	}
+}
+
+func TestRefreshCacheEntryFailureFallsBack(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "refresh token revoked", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	svc := service.NewService(server.Client(), *testLogger())
+	stale := cache.Entry{Username: "rt-user", Token: "old-token", RefreshToken: "old-refresh"}
+	if _, ok := refreshCacheEntry(svc, context.Background(), testLogger(), strings.TrimPrefix(server.URL, "https://"), "", stale); ok {
+		t.Fatal("expected failed refresh to fall back to a full exchange")
+	}
+}
+
+func TestTokenCacheKeySeparatesGoogleServiceAccounts(t *testing.T) {
+	t.Setenv("cloud_provider", utils.CloudProviderGoogle)
+	t.Setenv("google_auth_method", "workload_identity_federation")
+	request := func(gsa string) utils.CredentialProviderRequest {
+		return utils.CredentialProviderRequest{ServiceAccountAnnotations: map[string]string{utils.GoogleServiceAccountAnnotation: gsa}}
+	}
+	a := tokenCacheKey("example.jfrog.io", request("a@project.iam.gserviceaccount.com"), handlers.TokenOptions{})
+	b := tokenCacheKey("example.jfrog.io", request("b@project.iam.gserviceaccount.com"), handlers.TokenOptions{})
+	if a == b {
+		t.Fatal("expected pods bound to different Google service accounts to get different cache keys")
+	}
+}
+
+func TestTokenCacheKeyChangesWithTheIdentitySettings(t *testing.T) {
+	t.Setenv("cloud_provider", utils.CloudProviderAWS)
+	t.Setenv("aws_auth_method", "assume_external_role")
+	t.Setenv("aws_external_role_arn", "arn:aws:iam::111111111111:role/hop,arn:aws:iam::222222222222:role/puller")
+	key := func() string {
+		return tokenCacheKey("example.jfrog.io", utils.CredentialProviderRequest{}, handlers.TokenOptions{})
+	}
+	base := key()
+
+	for name, value := range map[string]string{
+		"aws_external_role_arn":              "arn:aws:iam::111111111111:role/hop,arn:aws:iam::333333333333:role/puller",
+		"aws_external_role_external_id":      "tenant-a",
+		"aws_external_role_session_tags":     "team=platform",
+		"aws_external_role_source_identity":  "kubelet",
+		"aws_external_role_session_name":     "jfrog-{{.NodeName}}",
+		"aws_web_identity_token_file":        "/var/run/secrets/other/token",
+		"jfrog_refresh_token_file":           "/etc/jfrog/refresh-token",
+		"AWS_ROLE_ARN":                       "arn:aws:iam::111111111111:role/irsa",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI": "http://169.254.170.23/v1/credentials",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if key() == base {
+				t.Fatalf("expected %s to change the cache key", name)
+			}
+		})
+	}
+	if key() != base {
+		t.Fatal("expected the same settings to give the same cache key")
+	}
+}
+
+func TestNewCacheEntryNeverOutlivesTheTokenExpiry(t *testing.T) {
+	issuedAt := time.Now()
+	exp := issuedAt.Add(30 * time.Minute).Truncate(time.Second)
+	claims, _ := json.Marshal(handlers.TokenClaims{Subject: "jfac@01h2/users/ci-puller", ExpiresAt: exp.Unix()})
+	accessToken := "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+
+	// an OIDC exchange reports no expires_in, secret_ttl_seconds is longer than exp
+	entry := newCacheEntry(testLogger(), handlers.ArtifactoryToken{Username: "ci-puller", AccessToken: accessToken}, issuedAt, "14400")
+	if !entry.ExpiresAt.Equal(exp) {
+		t.Fatalf("expected the exp claim %s, got %s", exp, entry.ExpiresAt)
+	}
+	// a reported lifetime past exp is capped as well
+	entry = newCacheEntry(testLogger(), handlers.ArtifactoryToken{AccessToken: accessToken, ExpiresIn: 7200}, issuedAt, "")
+	if !entry.ExpiresAt.Equal(exp) {
+		t.Fatalf("expected the lifetime capped at %s, got %s", exp, entry.ExpiresAt)
+	}
+	// without an exp claim secret_ttl_seconds still applies
+	entry = newCacheEntry(testLogger(), handlers.ArtifactoryToken{AccessToken: "opaque"}, issuedAt, "600")
+	if !entry.ExpiresAt.Equal(issuedAt.Add(10 * time.Minute)) {
+		t.Fatalf("expected secret_ttl_seconds to apply, got %s", entry.ExpiresAt)
+	}
+}
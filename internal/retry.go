@@ -0,0 +1,176 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryBaseDelay   = 200 * time.Millisecond
+	DefaultRetryMaxDelay    = 2 * time.Second
+)
+
+// RetryPolicy bounds how Service.Do retries transient failures.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled on each retry
+	BaseDelay time.Duration
+	// MaxDelay caps a single backoff, including a server's Retry-After
+	MaxDelay time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultRetryMaxAttempts,
+		BaseDelay:   DefaultRetryBaseDelay,
+		MaxDelay:    DefaultRetryMaxDelay,
+	}
+}
+
+// retryableStatus reports whether a response status is worth retrying:
+// throttling (429, IMDS 503) and other server side failures.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+type replayKey struct{}
+
+// WithReplay overrides whether Do may send requests made with ctx again after
+// they may have reached the server, which by default it only does for
+// idempotent methods. Token grants that can safely be repeated set it, single
+// use ones such as the refresh token grant clear it. Requests that failed
+// before they were sent (dial errors) are retried either way.
+func WithReplay(ctx context.Context, replay bool) context.Context {
+	return context.WithValue(ctx, replayKey{}, replay)
+}
+
+// replayable reports whether req may be sent again once it reached the server.
+func replayable(req *http.Request) bool {
+	if replay, ok := req.Context().Value(replayKey{}).(bool); ok {
+		return replay
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// notSent reports whether err happened before the request was written, when
+// resolving or connecting to the server.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// backoff returns a full-jitter delay for the given retry (0 based), or the
+// server's Retry-After when it sent one, both capped at MaxDelay.
+func (p RetryPolicy) backoff(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, p.MaxDelay)
+		}
+	}
+	ceiling := p.BaseDelay << retry
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// Do sends req with s.Client, retrying connection errors, 429 and 5xx
+// responses according to s.Retry. Requests that are not replayable (see
+// WithReplay) are only retried when they could not be sent. It stops early
+// when the next attempt would not start before the request context's
+// deadline, and returns the last response or error. Requests with a body
+// must be rewindable (http.NewRequest sets GetBody for in-memory bodies).
+func (s *Service) Do(req *http.Request) (*http.Response, error) {
+	attempts := max(s.Retry.MaxAttempts, 1)
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := s.Client.Do(req)
+		if !s.shouldRetry(req, resp, err) || attempt >= attempts {
+			return resp, err
+		}
+
+		delay := s.Retry.backoff(attempt-1, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+		next, rewindErr := rewind(req)
+		if rewindErr != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+			s.Logger.Info(fmt.Sprintf("%s %s returned %d, retrying in %s (attempt %d/%d)", req.Method, req.URL.Redacted(), resp.StatusCode, delay, attempt+1, attempts))
+		} else {
+			s.Logger.Info(fmt.Sprintf("%s %s failed: %v, retrying in %s (attempt %d/%d)", req.Method, req.URL.Redacted(), err, delay, attempt+1, attempts))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		req = next
+	}
+}
+
+func (s *Service) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		// the caller's deadline or cancellation is final, anything else
+		// (connection refused or reset, timeouts of a single attempt) is not
+		if req.Context().Err() != nil || errors.Is(err, context.Canceled) {
+			return false
+		}
+		return replayable(req) || notSent(err)
+	}
+	return replayable(req) && retryableStatus(resp.StatusCode)
+}
+
+// rewind returns a copy of req with a fresh body for the next attempt.
+func rewind(req *http.Request) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return next, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("request body cannot be replayed")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	next.Body = body
+	return next, nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"io"
+	"jfrog-credential-provider/internal/logger"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestService(client *http.Client) *Service {
+	s := NewService(client, logger.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	s.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	return s
+}
+
+func TestDoRetriesServerErrorsAndReplaysBody(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d got body %q", calls.Load()+1, body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequestWithContext(WithReplay(context.Background(), true), http.MethodPost, server.URL, strings.NewReader("payload"))
+	resp, err := newTestService(server.Client()).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("expected 200 after 3 attempts, got %d after %d", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoReturnsLastResponseWhenAttemptsRunOut(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := newTestService(server.Client()).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || calls.Load() != 3 {
+		t.Fatalf("expected 429 after 3 attempts, got %d after %d", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := newTestService(server.Client()).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls.Load())
+	}
+}
+
+func TestDoRetriesConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	s := newTestService(server.Client())
+	var attempts atomic.Int32
+	s.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if _, err := s.Do(req); err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestDoDoesNotResendNonReplayableRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	for name, ctx := range map[string]context.Context{
+		"POST":      context.Background(),
+		"opted out": WithReplay(context.Background(), false),
+	} {
+		calls.Store(0)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader("refresh_token"))
+		resp, err := newTestService(server.Client()).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if calls.Load() != 1 {
+			t.Fatalf("%s: expected a single attempt, got %d", name, calls.Load())
+		}
+	}
+}
+
+func TestDoRetriesNonReplayableRequestsThatWereNotSent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	s := newTestService(server.Client())
+	var attempts atomic.Int32
+	s.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	req, _ := http.NewRequestWithContext(WithReplay(context.Background(), false), http.MethodPost, url, strings.NewReader("refresh_token"))
+	if _, err := s.Do(req); err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("expected 3 attempts of a request refused at dial, got %d", attempts.Load())
+	}
+}
+
+func TestDoStopsBeforeTheContextDeadline(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s := newTestService(server.Client())
+	s.Retry.MaxDelay = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	start := time.Now()
+	resp, err := s.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 || time.Since(start) > 150*time.Millisecond {
+		t.Fatalf("expected to give up at once, got %d attempts in %s", calls.Load(), time.Since(start))
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -17,16 +17,40 @@ package service
 import (
 	"jfrog-credential-provider/internal/logger"
 	"net/http"
+	"strings"
 )
 
+// Endpoints overrides the base URLs of the cloud metadata services, e.g. for
+// an IPv6-only IMDS or a local emulator. Empty fields keep the defaults.
+type Endpoints struct {
+	AWSIMDS              string
+	AzureIMDS            string
+	GoogleMetadata       string
+	GoogleIAMCredentials string
+	GoogleSTS            string
+}
+
+// EndpointURL joins path to base, or to fallback when base is empty.
+func EndpointURL(base, fallback, path string) string {
+	if base == "" {
+		base = fallback
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
 type Service struct {
 	Client *http.Client
 	Logger logger.Logger
+	// Retry is the policy of Do, calls made directly on Client are not retried
+	Retry RetryPolicy
+	// Endpoints of the metadata services called by the handlers
+	Endpoints Endpoints
 }
 
 func NewService(client *http.Client, logger logger.Logger) *Service {
 	return &Service{
 		Client: client,
 		Logger: logger,
+		Retry:  DefaultRetryPolicy(),
 	}
 }
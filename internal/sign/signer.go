@@ -12,33 +12,37 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package controllers
+// Package sign signs HTTP requests with AWS Signature Version 4, either
+// SigV4 scoped to one region or SigV4a valid in a set of regions.
+package sign
 
 import (
 	"bytes"
-	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"hash"
-	"io"
-	"math/big"
 	"net/http"
-	"net/url"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 
-	signerCrypto "jfrog-credential-provider/internal/sign/v4a/crypto"
+	"jfrog-credential-provider/internal/fips"
 	v4Internal "jfrog-credential-provider/internal/sign/v4a/v4"
 )
 
+// Algorithm selects the signature version.
+type Algorithm string
+
+const (
+	// SigV4 signs for a single region with an HMAC of the secret key
+	SigV4 Algorithm = "sigv4"
+	// SigV4a signs for a region set with an ECDSA key derived from the
+	// secret key, "*" being valid in every region
+	SigV4a Algorithm = "sigv4a"
+)
+
 const (
 	// AmzRegionSetKey represents the region set header used for sigv4a
 	AmzRegionSetKey     = "X-Amz-Region-Set"
@@ -46,390 +50,202 @@ const (
 	amzDateKey          = v4Internal.AmzDateKey
 	authorizationHeader = "Authorization"
 
-	signingAlgorithm = "AWS4-ECDSA-P256-SHA256"
-
-	timeFormat      = "20060102T150405Z"
-	shortTimeFormat = "20060102"
+	timeFormat      = v4Internal.TimeFormat
+	shortTimeFormat = v4Internal.ShortTimeFormat
 )
 
-var (
-	p256          elliptic.Curve
-	nMinusTwoP256 *big.Int
-
-	one = new(big.Int).SetInt64(1)
-
-	cache = credsCache{}
-
-	randomSource = rand.Reader
-)
-
-func init() {
-	// Ensure the elliptic curve parameters are initialized on package import rather then on first usage
-	p256 = elliptic.P256()
-
-	nMinusTwoP256 = new(big.Int).SetBytes(p256.Params().N.Bytes())
-	nMinusTwoP256 = nMinusTwoP256.Sub(nMinusTwoP256, new(big.Int).SetInt64(2))
-}
-
-type credsCache struct {
-	asymmetric atomic.Value
-	m          sync.Mutex
-}
-
-// SetRandomSource used for testing to override rand so tests can expect stable output
-func SetRandomSource(reader io.Reader) {
-	randomSource = reader
-}
-
-// deriveKeyFromAccessKeyPair derives a NIST P-256 PrivateKey from the given
-// IAM AccessKey and SecretKey pair.
-//
-// Based on FIPS.186-4 Appendix B.4.2
-func deriveKeyFromAccessKeyPair(accessKey, secretKey string) (*ecdsa.PrivateKey, error) {
-	params := p256.Params()
-	bitLen := params.BitSize // Testing random candidates does not require an additional 64 bits
-	counter := 0x01
-
-	buffer := make([]byte, 1+len(accessKey)) // 1 byte counter + len(accessKey)
-	kdfContext := bytes.NewBuffer(buffer)
-
-	inputKey := append([]byte("AWS4A"), []byte(secretKey)...)
-
-	d := new(big.Int)
-	for {
-		kdfContext.Reset()
-		kdfContext.WriteString(accessKey)
-		kdfContext.WriteByte(byte(counter))
-
-		key, err := signerCrypto.HMACKeyDerivation(sha256.New, bitLen, inputKey, []byte(signingAlgorithm), kdfContext.Bytes())
-		if err != nil {
-			return nil, err
+// ParseAlgorithm returns the Algorithm named by value, SigV4a when empty.
+// In FIPS 140-3 mode it defaults to SigV4 and refuses SigV4a, whose key
+// derivation is not part of the Go Cryptographic Module.
+func ParseAlgorithm(value string) (Algorithm, error) {
+	switch Algorithm(strings.ToLower(value)) {
+	case "":
+		if fips.Enabled() {
+			return SigV4, nil
 		}
-
-		// Check key first before calling SetBytes if key is in fact a valid candidate.
-		// This ensures the byte slice is the correct length (32-bytes) to compare in constant-time
-		cmp, err := signerCrypto.ConstantTimeByteCompare(key, nMinusTwoP256.Bytes())
-		if err != nil {
-			return nil, err
-		}
-		if cmp == -1 {
-			d.SetBytes(key)
-			break
-		}
-
-		counter++
-		if counter > 0xFF {
-			return nil, fmt.Errorf("exhausted single byte external counter")
+		return SigV4a, nil
+	case SigV4a:
+		if err := fips.Check("signing with " + string(SigV4a)); err != nil {
+			return "", err
 		}
+		return SigV4a, nil
+	case SigV4:
+		return SigV4, nil
 	}
-	d = d.Add(d, one)
-
-	priv := new(ecdsa.PrivateKey)
-	priv.PublicKey.Curve = p256
-	priv.D = d
-	priv.PublicKey.X, priv.PublicKey.Y = p256.ScalarBaseMult(d.Bytes())
-
-	return priv, nil
-}
-
-// V4aCredentials is Context, ECDSA, and Optional Session Token that can be used
-// to sign requests using SigV4a
-type V4aCredentials struct {
-	Context      string
-	PrivateKey   *ecdsa.PrivateKey
-	SessionToken string
+	return "", fmt.Errorf("signing algorithm can only be set as %s or %s, however the current value is: %s", SigV4, SigV4a, value)
 }
 
+// AwsCredentials are the access key pair and optional session token to
+// sign with.
 type AwsCredentials struct {
 	AccessKey    string
 	SecretKey    string
-	RegionName   string
 	SessionToken string
-}
-
-// retrievePrivateKey returns credentials suitable for SigV4a signing
-func retrievePrivateKey(symmetric AwsCredentials) (V4aCredentials, error) {
-	cache.m.Lock()
-	defer cache.m.Unlock()
-
-	// try to get creds from cache
-	v := cache.asymmetric.Load()
-	if v != nil {
-		c := v.(*V4aCredentials)
-		// if the cached Context matches the symmetric AccessKey ID, then use cached value. Otherwise, creds have
-		// changed and we need to derive new asymmetric creds
-		if c != nil && c.Context == symmetric.AccessKey {
-			return *c, nil
-		}
-	}
-
-	privateKey, err := deriveKeyFromAccessKeyPair(symmetric.AccessKey, symmetric.SecretKey)
+	// Expires is when temporary credentials expire, zero when unknown. The
+	// SigV4a key derived from them is cached until then.
+	Expires time.Time
+}
+
+// Options are the signing parameters of a request.
+type Options struct {
+	Algorithm Algorithm
+	Service   string
+	// Region is the SigV4 region or the SigV4a region set, comma separated.
+	// Empty or "*" means every region for SigV4a, and us-east-1 for SigV4.
+	Region string
+	// Time is the signing time, now when zero
+	Time time.Time
+}
+
+// NewSignedRequest creates a method request to url with body and ctx, and
+// signs it with creds.
+func NewSignedRequest(ctx context.Context, method, url string, body []byte, creds AwsCredentials, opts Options) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
-		return V4aCredentials{}, fmt.Errorf("failed to derive asymmetric key from credentials")
+		return nil, err
 	}
-
-	creds := V4aCredentials{
-		Context:      symmetric.AccessKey,
-		PrivateKey:   privateKey,
-		SessionToken: symmetric.SessionToken,
+	if len(body) == 0 {
+		// no Content-Length to sign, nor a body to send
+		req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
 	}
-
-	// cache derived asymmetric creds so we don't derive new ones until symmetric creds change
-	cache.asymmetric.Store(&creds)
-
-	return creds, nil
+	if err := SignHTTP(req, PayloadHash(body), creds, opts); err != nil {
+		return nil, err
+	}
+	return req, nil
 }
 
-type httpSigner struct {
-	Request     *http.Request
-	ServiceName string
-	RegionSet   []string
-	Time        time.Time
-	Credentials V4aCredentials
-
-	// PayloadHash is the hex encoded SHA-256 hash of the request payload
-	// If len(PayloadHash) == 0 the signer will attempt to send the request
-	// as an unsigned payload. Note: Unsigned payloads only work for a subset of services.
-	PayloadHash string
+// PayloadHash returns the hex encoded SHA-256 of body, as signed.
+func PayloadHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
 }
 
-func (s *httpSigner) setRequiredSigningFields(headers http.Header, query url.Values) {
-	amzDate := s.Time.Format(timeFormat)
-
-	headers.Set(AmzRegionSetKey, strings.Join(s.RegionSet, ","))
-	headers.Set(amzDateKey, amzDate)
-	if len(s.Credentials.SessionToken) > 0 {
-		headers.Set(amzSecurityTokenKey, s.Credentials.SessionToken)
+// SignHTTP adds the signing headers and the Authorization header to r, whose
+// body hashes to payloadHash. The host is signed but not added as a header,
+// the receiver of forwarded signing headers uses its own.
+func SignHTTP(r *http.Request, payloadHash string, creds AwsCredentials, opts Options) error {
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
 	}
-}
-
-// Build modifies the Request attribute of the httpSigner, adding an Authorization header
-func (s *httpSigner) Build() (signedRequest, error) {
-	req := s.Request
-
-	query := req.URL.Query()
-	headers := req.Header
+	opts.Time = opts.Time.UTC()
 
-	// seemingly required by S3/MRAP -- 403 Forbidden otherwise
-	headers.Set("host", req.URL.Host)
-	//headers.Set("x-amz-content-sha256", s.PayloadHash)
+	var algorithm signingAlgorithm
+	switch opts.Algorithm {
+	case SigV4:
+		algorithm = sigV4{}
+	case SigV4a, "":
+		if err := fips.Check("signing with " + string(SigV4a)); err != nil {
+			return err
+		}
+		algorithm = sigV4a{}
+	default:
+		return fmt.Errorf("unknown signing algorithm %q", opts.Algorithm)
+	}
 
-	s.setRequiredSigningFields(headers, query)
+	r.Header.Set(amzDateKey, opts.Time.Format(timeFormat))
+	if creds.SessionToken != "" {
+		r.Header.Set(amzSecurityTokenKey, creds.SessionToken)
+	}
+	scope := algorithm.prepare(r, opts)
 
-	// Sort Each Query Key's Values
+	query := r.URL.Query()
 	for key := range query {
 		sort.Strings(query[key])
 	}
+	r.URL.RawQuery = strings.Replace(query.Encode(), "+", "%20", -1)
+	v4Internal.SanitizeHostForHeader(r)
+	host := r.URL.Host
+	if r.Host != "" {
+		host = r.Host
+	}
+	signedHeaders, canonicalHeaders := buildCanonicalHeaders(host, r.Header, r.ContentLength)
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		v4Internal.GetURIPath(r.URL),
+		r.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := buildStringToSign(algorithm.name(), opts.Time, scope, canonicalRequest)
 
-	v4Internal.SanitizeHostForHeader(req)
-
-	credentialScope := s.buildCredentialScope()
-	credentialStr := s.Credentials.Context + "/" + credentialScope
-
-	unsignedHeaders := headers
-
-	host := req.URL.Host
-	if len(req.Host) > 0 {
-		host = req.Host
-	}
-
-	signedHeaders, signedHeadersStr, canonicalHeaderStr := s.buildCanonicalHeaders(host, v4Internal.IgnoredHeaders, unsignedHeaders, s.Request.ContentLength)
-
-	rawQuery := strings.Replace(query.Encode(), "+", "%20", -1)
-
-	canonicalURI := v4Internal.GetURIPath(req.URL)
-
-	canonicalString := s.buildCanonicalString(
-		req.Method,
-		canonicalURI,
-		rawQuery,
-		signedHeadersStr,
-		canonicalHeaderStr,
-	)
-
-	strToSign := s.buildStringToSign(credentialScope, canonicalString)
-	signingSignature, err := s.buildSignature(strToSign)
+	signature, err := algorithm.sign(creds, opts, stringToSign)
 	if err != nil {
-		return signedRequest{}, err
+		return err
 	}
-
-	headers[authorizationHeader] = append(headers[authorizationHeader][:0], buildAuthorizationHeader(credentialStr, signedHeadersStr, signingSignature))
-
-	req.URL.RawQuery = rawQuery
-
-	return signedRequest{
-		Request:         req,
-		SignedHeaders:   signedHeaders,
-		CanonicalString: canonicalString,
-		StringToSign:    strToSign,
-	}, nil
+	r.Header.Set(authorizationHeader, fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm.name(), creds.AccessKey, scope, signedHeaders, signature))
+	return nil
 }
 
-func (s *httpSigner) buildCredentialScope() string {
-	return strings.Join([]string{
-		s.Time.Format(shortTimeFormat),
-		s.ServiceName,
-		"aws4_request",
-	}, "/")
-
+// SigningRegion returns the region a request forwarded as signing headers
+// was signed for: its SigV4a region set or its SigV4 credential scope region,
+// "*" when valid in every region.
+func SigningRegion(header http.Header) string {
+	if regionSet := header.Get(AmzRegionSetKey); regionSet != "" {
+		return regionSet
+	}
+	// AWS4-HMAC-SHA256 Credential=<key>/<date>/<region>/<service>/aws4_request, ...
+	credential, _, _ := strings.Cut(header.Get(authorizationHeader), ",")
+	if scope := strings.Split(credential, "/"); len(scope) == 5 {
+		return scope[2]
+	}
+	return "*"
 }
 
-func buildAuthorizationHeader(credentialStr, signedHeadersStr, signingSignature string) string {
-	const credential = "Credential="
-	const signedHeaders = "SignedHeaders="
-	const signature = "Signature="
-	const commaSpace = ", "
-
-	var parts strings.Builder
-	parts.Grow(len(signingAlgorithm) + 1 +
-		len(credential) + len(credentialStr) + len(commaSpace) +
-		len(signedHeaders) + len(signedHeadersStr) + len(commaSpace) +
-		len(signature) + len(signingSignature),
-	)
-	parts.WriteString(signingAlgorithm)
-	parts.WriteRune(' ')
-	parts.WriteString(credential)
-	parts.WriteString(credentialStr)
-	parts.WriteString(commaSpace)
-	parts.WriteString(signedHeaders)
-	parts.WriteString(signedHeadersStr)
-	parts.WriteString(commaSpace)
-	parts.WriteString(signature)
-	parts.WriteString(signingSignature)
-	return parts.String()
+// signingAlgorithm is what differs between SigV4 and SigV4a.
+type signingAlgorithm interface {
+	name() string
+	// prepare sets the algorithm's headers on r and returns the credential scope
+	prepare(r *http.Request, opts Options) string
+	sign(creds AwsCredentials, opts Options, stringToSign string) (string, error)
 }
 
-func (s *httpSigner) buildCanonicalHeaders(host string, rule v4Internal.Rule, header http.Header, length int64) (signed http.Header, signedHeaders, canonicalHeadersStr string) {
-	signed = make(http.Header)
-
-	const hostHeader = "host"
-	headers := make([]string, 0)
+func buildStringToSign(algorithm string, signingTime time.Time, scope, canonicalRequest string) string {
+	return strings.Join([]string{
+		algorithm,
+		signingTime.Format(timeFormat),
+		scope,
+		PayloadHash([]byte(canonicalRequest)),
+	}, "\n")
+}
 
+// buildCanonicalHeaders returns the signed header names and the canonical
+// headers block: host, content-length when there is a body, and every header
+// of header but the ignored ones.
+func buildCanonicalHeaders(host string, header http.Header, length int64) (signedHeaders, canonicalHeaders string) {
+	signed := map[string][]string{"host": {host}}
 	if length > 0 {
-		const contentLengthHeader = "content-length"
-		headers = append(headers, contentLengthHeader)
-		signed[contentLengthHeader] = append(signed[contentLengthHeader], strconv.FormatInt(length, 10))
+		signed["content-length"] = []string{strconv.FormatInt(length, 10)}
 	}
-
 	for k, v := range header {
-		if !rule.IsValid(k) {
-			continue // ignored header
+		if !v4Internal.IgnoredHeaders.IsValid(k) {
+			continue
 		}
-
 		lowerCaseKey := strings.ToLower(k)
-		if _, ok := signed[lowerCaseKey]; ok {
-			// include additional values
-			signed[lowerCaseKey] = append(signed[lowerCaseKey], v...)
-			continue
+		if lowerCaseKey == "host" || lowerCaseKey == "content-length" {
+			continue // taken from the request, not its headers
 		}
-
-		headers = append(headers, lowerCaseKey)
-		signed[lowerCaseKey] = v
-	}
-	sort.Strings(headers)
-
-	signedHeaders = strings.Join(headers, ";")
-
-	var canonicalHeaders strings.Builder
-	n := len(headers)
-	const colon = ':'
-	for i := 0; i < n; i++ {
-		if headers[i] == hostHeader {
-			canonicalHeaders.WriteString(hostHeader)
-			canonicalHeaders.WriteRune(colon)
-			canonicalHeaders.WriteString(v4Internal.StripExcessSpaces(host))
-		} else {
-			canonicalHeaders.WriteString(headers[i])
-			canonicalHeaders.WriteRune(colon)
-			// Trim out leading, trailing, and dedup inner spaces from signed header values.
-			values := signed[headers[i]]
-			for j, v := range values {
-				cleanedValue := strings.TrimSpace(v4Internal.StripExcessSpaces(v))
-				canonicalHeaders.WriteString(cleanedValue)
-				if j < len(values)-1 {
-					canonicalHeaders.WriteRune(',')
-				}
+		signed[lowerCaseKey] = append(signed[lowerCaseKey], v...)
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteRune(':')
+		// Trim out leading, trailing, and dedup inner spaces from signed header values.
+		for i, v := range signed[name] {
+			if i > 0 {
+				b.WriteRune(',')
 			}
+			b.WriteString(strings.TrimSpace(v4Internal.StripExcessSpaces(v)))
 		}
-		canonicalHeaders.WriteRune('\n')
+		b.WriteRune('\n')
 	}
-	canonicalHeadersStr = canonicalHeaders.String()
-
-	return signed, signedHeaders, canonicalHeadersStr
-}
-
-func (s *httpSigner) buildCanonicalString(method, uri, query, signedHeaders, canonicalHeaders string) string {
-	return strings.Join([]string{
-		method,
-		uri,
-		query,
-		canonicalHeaders,
-		signedHeaders,
-		s.PayloadHash,
-	}, "\n")
-}
-
-func (s *httpSigner) buildStringToSign(credentialScope, canonicalRequestString string) string {
-	return strings.Join([]string{
-		signingAlgorithm,
-		s.Time.Format(timeFormat),
-		credentialScope,
-		hex.EncodeToString(makeHash(sha256.New(), []byte(canonicalRequestString))),
-	}, "\n")
-}
-
-func makeHash(hash hash.Hash, b []byte) []byte {
-	hash.Reset()
-	hash.Write(b)
-	return hash.Sum(nil)
-}
-
-func (s *httpSigner) buildSignature(strToSign string) (string, error) {
-	sig, err := s.Credentials.PrivateKey.Sign(randomSource, makeHash(sha256.New(), []byte(strToSign)), crypto.SHA256)
-	if err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(sig), nil
-}
-
-type signedRequest struct {
-	Request         *http.Request
-	SignedHeaders   http.Header
-	CanonicalString string
-	StringToSign    string
-}
-
-// SignV4a returns a map[string][]string of headers, including an added AWS V4a signature based on the config/credentials provided.
-func SignV4a(method string, url string, service string, awsCreds AwsCredentials) (*http.Request, error) {
-
-	key, err := retrievePrivateKey(awsCreds)
-	if err != nil {
-		return nil, err
-	}
-
-	req, _ := http.NewRequest(method, url, nil)
-
-	regionSet := []string{"*"}
-	if awsCreds.RegionName != "" && awsCreds.RegionName != "*" {
-		regionSet = []string{awsCreds.RegionName}
-	}
-
-	signer := &httpSigner{
-		Request:     req,
-		PayloadHash: `e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855`,
-		ServiceName: service,
-		RegionSet:   regionSet,
-		Credentials: key,
-		Time:        time.Now().UTC(),
-	}
-
-	_, err = signer.Build()
-	if err != nil {
-		return nil, err
-	}
-	// removing host only from the headers to avoid 403 Forbidden error
-	// It does exist in CanonicalSignature, Artifactory adds this themseleves so we don't need to add it
-	// specifically to the headers in the request.
-	req.Header.Del("Host")
-	return req, nil
+	return strings.Join(names, ";"), b.String()
 }
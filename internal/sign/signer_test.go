@@ -0,0 +1,145 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The vectors below come from the AWS Signature Version 4 test suite and the
+// SigV4a test suite of aws-c-auth, which share their credentials and time.
+var (
+	testSuiteCredentials = AwsCredentials{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	testSuiteTime        = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+func TestSignHTTPSigV4KnownAnswers(t *testing.T) {
+	tests := map[string]struct {
+		method, url, body, contentType string
+		signedHeaders, signature       string
+	}{
+		"get-vanilla": {"GET", "https://example.amazonaws.com/", "", "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		"get-vanilla-query-order-key-case": {"GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "",
+			"host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		"post-vanilla": {"POST", "https://example.amazonaws.com/", "", "",
+			"host;x-amz-date", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		"post-x-www-form-urlencoded": {"POST", "https://example.amazonaws.com/", "Param1=value1", "application/x-www-form-urlencoded",
+			"content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			// the suite requests carry no Content-Length to sign
+			req.ContentLength = 0
+			err := SignHTTP(req, PayloadHash([]byte(tt.body)), testSuiteCredentials, Options{Algorithm: SigV4, Service: "service", Region: "us-east-1", Time: testSuiteTime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" + tt.signedHeaders + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != expected {
+				t.Fatalf("expected %s\ngot      %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestDeriveKeyFromAccessKeyPairKnownAnswer(t *testing.T) {
+	key, err := deriveKeyFromAccessKeyPair(testSuiteCredentials.AccessKey, testSuiteCredentials.SecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := hex.EncodeToString(key.X.Bytes()), hex.EncodeToString(key.Y.Bytes())
+	if x != "b6618f6a65740a99e650b33b6b4b5bd0d43b176d721a3edfea7e7d2d56d936b1" || y != "865ed22a7eadc9c5cb9d2cbaca1b3699139fedc5043dc6661864218330c8e518" {
+		t.Fatalf("unexpected public key %s, %s", x, y)
+	}
+}
+
+func TestSignHTTPSigV4aKnownAnswer(t *testing.T) {
+	// get-vanilla of the SigV4a suite, ECDSA signatures are randomized so the
+	// signature is verified against the expected canonical request
+	const canonicalRequest = "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\nx-amz-region-set:us-east-1\n\nhost;x-amz-date;x-amz-region-set\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err := SignHTTP(req, PayloadHash(nil), testSuiteCredentials, Options{Algorithm: SigV4a, Service: "service", Region: "us-east-1", Time: testSuiteTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "AWS4-ECDSA-P256-SHA256 Credential=AKIDEXAMPLE/20150830/service/aws4_request, SignedHeaders=host;x-amz-date;x-amz-region-set, Signature="
+	authorization := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, prefix) {
+		t.Fatalf("unexpected authorization %s", authorization)
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(authorization, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := retrievePrivateKey(testSuiteCredentials)
+	digest := sha256.Sum256([]byte(buildStringToSign(sigV4aAlgorithm, testSuiteTime, "20150830/service/aws4_request", canonicalRequest)))
+	if !ecdsa.VerifyASN1(&key.PrivateKey.PublicKey, digest[:], signature) {
+		t.Fatal("the signature does not match the expected canonical request")
+	}
+	if req.Header.Get("Host") != "" {
+		t.Fatal("expected the host to be signed but not set as a header")
+	}
+}
+
+func TestNewSignedRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	creds := AwsCredentials{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "session"}
+	req, err := NewSignedRequest(ctx, "POST", "https://sts.us-west-2.amazonaws.com/", []byte("Action=GetCallerIdentity&Version=2011-06-15"), creds, Options{Algorithm: SigV4, Service: "sts", Region: "us-west-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Context() != ctx || req.ContentLength == 0 {
+		t.Fatal("expected the request to carry the context and the body")
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=content-length;host;x-amz-date;x-amz-security-token") {
+		t.Fatalf("expected the body length and session token to be signed, got %s", req.Header.Get("Authorization"))
+	}
+	if region := SigningRegion(req.Header); region != "us-west-2" {
+		t.Fatalf("expected the SigV4 scope region, got %q", region)
+	}
+
+	req, err = NewSignedRequest(ctx, "GET", "https://sts.amazonaws.com/?Action=GetCallerIdentity&Version=2011-06-15", nil, creds, Options{Service: "sts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region := SigningRegion(req.Header); region != "*" {
+		t.Fatalf("expected SigV4a for every region by default, got %q", region)
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	if algorithm, err := ParseAlgorithm(""); err != nil || algorithm != SigV4a {
+		t.Fatalf("expected SigV4a by default, got %q, %v", algorithm, err)
+	}
+	if algorithm, err := ParseAlgorithm("SigV4"); err != nil || algorithm != SigV4 {
+		t.Fatalf("expected SigV4, got %q, %v", algorithm, err)
+	}
+	if _, err := ParseAlgorithm("sigv2"); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+}
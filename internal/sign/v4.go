@@ -0,0 +1,54 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	v4Internal "jfrog-credential-provider/internal/sign/v4a/v4"
+)
+
+// defaultSigV4Region is the region of the global STS endpoint, used when
+// SigV4 is asked to sign for every region
+const defaultSigV4Region = "us-east-1"
+
+// sigV4 signs with an HMAC key derived from the secret key, the date, the
+// region and the service.
+type sigV4 struct{}
+
+func (sigV4) name() string {
+	return "AWS4-HMAC-SHA256"
+}
+
+func (sigV4) prepare(r *http.Request, opts Options) string {
+	return strings.Join([]string{opts.Time.Format(shortTimeFormat), sigV4Region(opts.Region), opts.Service, "aws4_request"}, "/")
+}
+
+func (sigV4) sign(creds AwsCredentials, opts Options, stringToSign string) (string, error) {
+	key := v4Internal.HMACSHA256([]byte("AWS4"+creds.SecretKey), []byte(opts.Time.Format(shortTimeFormat)))
+	key = v4Internal.HMACSHA256(key, []byte(sigV4Region(opts.Region)))
+	key = v4Internal.HMACSHA256(key, []byte(opts.Service))
+	key = v4Internal.HMACSHA256(key, []byte("aws4_request"))
+	return hex.EncodeToString(v4Internal.HMACSHA256(key, []byte(stringToSign))), nil
+}
+
+func sigV4Region(region string) string {
+	if region == "" || region == "*" {
+		return defaultSigV4Region
+	}
+	return region
+}
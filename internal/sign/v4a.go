@@ -0,0 +1,225 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	signerCrypto "jfrog-credential-provider/internal/sign/v4a/crypto"
+)
+
+const (
+	sigV4aAlgorithm = "AWS4-ECDSA-P256-SHA256"
+
+	// defaultDerivedKeyTTL bounds how long a derived key is kept for
+	// credentials without a known expiration
+	defaultDerivedKeyTTL = time.Hour
+	// maxDerivedKeys bounds the derived key cache, the daemon signs for a
+	// handful of roles at most
+	maxDerivedKeys = 64
+)
+
+var (
+	p256          elliptic.Curve
+	nMinusTwoP256 *big.Int
+
+	one = new(big.Int).SetInt64(1)
+
+	cache = credsCache{keys: map[string]derivedKey{}}
+
+	randomSource io.Reader = rand.Reader
+)
+
+func init() {
+	// Ensure the elliptic curve parameters are initialized on package import rather then on first usage
+	p256 = elliptic.P256()
+
+	nMinusTwoP256 = new(big.Int).SetBytes(p256.Params().N.Bytes())
+	nMinusTwoP256 = nMinusTwoP256.Sub(nMinusTwoP256, new(big.Int).SetInt64(2))
+}
+
+// credsCache memoises derived keys by access key ID until the credentials
+// expire. A plugin invocation signs once, so the cache pays off in the
+// credential daemon, which signs for every pull on the node.
+type credsCache struct {
+	m    sync.Mutex
+	keys map[string]derivedKey
+}
+
+type derivedKey struct {
+	privateKey *ecdsa.PrivateKey
+	// secretHash tells a rotated secret key apart from the cached one
+	secretHash [sha256.Size]byte
+	expires    time.Time
+}
+
+// SetRandomSource used for testing to override rand so tests can expect stable output
+func SetRandomSource(reader io.Reader) {
+	randomSource = reader
+}
+
+// sigV4a signs with an ECDSA P-256 key derived from the access key pair,
+// for the region set of the X-Amz-Region-Set header.
+type sigV4a struct{}
+
+func (sigV4a) name() string {
+	return sigV4aAlgorithm
+}
+
+func (sigV4a) prepare(r *http.Request, opts Options) string {
+	regionSet := opts.Region
+	if regionSet == "" {
+		regionSet = "*"
+	}
+	r.Header.Set(AmzRegionSetKey, regionSet)
+	return strings.Join([]string{opts.Time.Format(shortTimeFormat), opts.Service, "aws4_request"}, "/")
+}
+
+func (sigV4a) sign(creds AwsCredentials, opts Options, stringToSign string) (string, error) {
+	key, err := retrievePrivateKey(creds)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(stringToSign))
+	sig, err := key.PrivateKey.Sign(randomSource, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// deriveKeyFromAccessKeyPair derives a NIST P-256 PrivateKey from the given
+// IAM AccessKey and SecretKey pair.
+//
+// Based on FIPS.186-4 Appendix B.4.2
+func deriveKeyFromAccessKeyPair(accessKey, secretKey string) (*ecdsa.PrivateKey, error) {
+	params := p256.Params()
+	bitLen := params.BitSize // Testing random candidates does not require an additional 64 bits
+	counter := 0x01
+
+	buffer := make([]byte, 1+len(accessKey)) // 1 byte counter + len(accessKey)
+	kdfContext := bytes.NewBuffer(buffer)
+
+	inputKey := append([]byte("AWS4A"), []byte(secretKey)...)
+
+	d := new(big.Int)
+	for {
+		kdfContext.Reset()
+		kdfContext.WriteString(accessKey)
+		kdfContext.WriteByte(byte(counter))
+
+		key, err := signerCrypto.HMACKeyDerivation(sha256.New, bitLen, inputKey, []byte(sigV4aAlgorithm), kdfContext.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		// Check key first before calling SetBytes if key is in fact a valid candidate.
+		// This ensures the byte slice is the correct length (32-bytes) to compare in constant-time
+		cmp, err := signerCrypto.ConstantTimeByteCompare(key, nMinusTwoP256.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if cmp == -1 {
+			d.SetBytes(key)
+			break
+		}
+
+		counter++
+		if counter > 0xFF {
+			return nil, fmt.Errorf("exhausted single byte external counter")
+		}
+	}
+	d = d.Add(d, one)
+
+	priv := new(ecdsa.PrivateKey)
+	priv.PublicKey.Curve = p256
+	priv.D = d
+	priv.PublicKey.X, priv.PublicKey.Y = p256.ScalarBaseMult(d.Bytes())
+
+	return priv, nil
+}
+
+// V4aCredentials is Context, ECDSA, and Optional Session Token that can be used
+// to sign requests using SigV4a
+type V4aCredentials struct {
+	Context      string
+	PrivateKey   *ecdsa.PrivateKey
+	SessionToken string
+}
+
+// retrievePrivateKey returns credentials suitable for SigV4a signing, the
+// key derived for the access key pair by an earlier call while the
+// credentials are valid.
+func retrievePrivateKey(symmetric AwsCredentials) (V4aCredentials, error) {
+	now := time.Now()
+	secretHash := sha256.Sum256([]byte(symmetric.SecretKey))
+
+	cache.m.Lock()
+	defer cache.m.Unlock()
+
+	if c, ok := cache.keys[symmetric.AccessKey]; ok && c.secretHash == secretHash && now.Before(c.expires) {
+		return V4aCredentials{Context: symmetric.AccessKey, PrivateKey: c.privateKey, SessionToken: symmetric.SessionToken}, nil
+	}
+
+	privateKey, err := deriveKeyFromAccessKeyPair(symmetric.AccessKey, symmetric.SecretKey)
+	if err != nil {
+		return V4aCredentials{}, fmt.Errorf("failed to derive asymmetric key from credentials")
+	}
+
+	expires := symmetric.Expires
+	if expires.IsZero() {
+		expires = now.Add(defaultDerivedKeyTTL)
+	}
+	cache.put(now, symmetric.AccessKey, derivedKey{privateKey: privateKey, secretHash: secretHash, expires: expires})
+
+	return V4aCredentials{
+		Context:      symmetric.AccessKey,
+		PrivateKey:   privateKey,
+		SessionToken: symmetric.SessionToken,
+	}, nil
+}
+
+// put stores key, dropping the expired keys and, when the cache is still
+// full, the one expiring first. Callers hold c.m.
+func (c *credsCache) put(now time.Time, accessKey string, key derivedKey) {
+	for k, v := range c.keys {
+		if !now.Before(v.expires) {
+			delete(c.keys, k)
+		}
+	}
+	if _, ok := c.keys[accessKey]; !ok && len(c.keys) >= maxDerivedKeys {
+		var oldest string
+		for k, v := range c.keys {
+			if oldest == "" || v.expires.Before(c.keys[oldest].expires) {
+				oldest = k
+			}
+		}
+		delete(c.keys, oldest)
+	}
+	c.keys[accessKey] = key
+}
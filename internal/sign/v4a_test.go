@@ -0,0 +1,121 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func resetDerivedKeys(tb testing.TB) {
+	tb.Helper()
+	reset := func() {
+		cache.m.Lock()
+		cache.keys = map[string]derivedKey{}
+		cache.m.Unlock()
+	}
+	reset()
+	tb.Cleanup(reset)
+}
+
+func TestRetrievePrivateKeyCache(t *testing.T) {
+	resetDerivedKeys(t)
+	creds := AwsCredentials{AccessKey: "ASIAFIRST", SecretKey: "first-secret", Expires: time.Now().Add(time.Hour)}
+	other := AwsCredentials{AccessKey: "ASIASECOND", SecretKey: "second-secret", Expires: time.Now().Add(time.Hour)}
+
+	first, _ := retrievePrivateKey(creds)
+	retrievePrivateKey(other)
+	again, _ := retrievePrivateKey(creds)
+	if again.PrivateKey != first.PrivateKey {
+		t.Fatal("expected the key derived for the access key to be reused after signing for another one")
+	}
+
+	rotated := creds
+	rotated.SecretKey = "rotated-secret"
+	if key, _ := retrievePrivateKey(rotated); key.PrivateKey.D.Cmp(first.PrivateKey.D) == 0 {
+		t.Fatal("expected a new key for a different secret key")
+	}
+
+	expired := AwsCredentials{AccessKey: "ASIAEXPIRED", SecretKey: "secret", Expires: time.Now().Add(-time.Second)}
+	key, _ := retrievePrivateKey(expired)
+	if again, _ := retrievePrivateKey(expired); again.PrivateKey == key.PrivateKey {
+		t.Fatal("expected expired credentials not to be served from the cache")
+	}
+}
+
+func TestRetrievePrivateKeyCacheBounded(t *testing.T) {
+	resetDerivedKeys(t)
+	expires := time.Now().Add(time.Hour)
+	for i := range maxDerivedKeys + 1 {
+		retrievePrivateKey(AwsCredentials{AccessKey: fmt.Sprintf("ASIA%04d", i), SecretKey: "secret", Expires: expires.Add(time.Duration(i) * time.Second)})
+	}
+	cache.m.Lock()
+	defer cache.m.Unlock()
+	if len(cache.keys) != maxDerivedKeys {
+		t.Fatalf("expected at most %d cached keys, got %d", maxDerivedKeys, len(cache.keys))
+	}
+	if _, ok := cache.keys["ASIA0000"]; ok {
+		t.Fatal("expected the key expiring first to be evicted")
+	}
+}
+
+func BenchmarkDeriveKeyFromAccessKeyPair(b *testing.B) {
+	for b.Loop() {
+		if _, err := deriveKeyFromAccessKeyPair(testSuiteCredentials.AccessKey, testSuiteCredentials.SecretKey); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRetrievePrivateKeyCached(b *testing.B) {
+	resetDerivedKeys(b)
+	creds := testSuiteCredentials
+	creds.Expires = time.Now().Add(time.Hour)
+	retrievePrivateKey(creds)
+	for b.Loop() {
+		if _, err := retrievePrivateKey(creds); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSignHTTPSigV4a compares signing with fresh credentials each time,
+// as every plugin invocation did, and with the same credentials, as the
+// daemon does between credential refreshes.
+func BenchmarkSignHTTPSigV4a(b *testing.B) {
+	opts := Options{Algorithm: SigV4a, Service: "sts", Region: "*", Time: testSuiteTime}
+	sign := func(b *testing.B, creds AwsCredentials) {
+		req, _ := http.NewRequest("GET", "https://sts.amazonaws.com/?Action=GetCallerIdentity&Version=2011-06-15", nil)
+		if err := SignHTTP(req, PayloadHash(nil), creds, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.Run("derive", func(b *testing.B) {
+		resetDerivedKeys(b)
+		i := 0
+		for b.Loop() {
+			i++
+			sign(b, AwsCredentials{AccessKey: fmt.Sprintf("ASIA%016d", i), SecretKey: testSuiteCredentials.SecretKey})
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		resetDerivedKeys(b)
+		for b.Loop() {
+			sign(b, testSuiteCredentials)
+		}
+	})
+}
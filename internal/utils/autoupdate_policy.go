@@ -0,0 +1,208 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// UpdateChannel bounds how far auto-update moves from the current version.
+type UpdateChannel string
+
+const (
+	// UpdateChannelMajor follows every newer release, the default
+	UpdateChannelMajor UpdateChannel = "major"
+	// UpdateChannelMinor follows releases of the current major version
+	UpdateChannelMinor UpdateChannel = "minor"
+	// UpdateChannelPatch follows releases of the current minor version
+	UpdateChannelPatch UpdateChannel = "patch"
+)
+
+// AutoUpdatePolicy restricts which release auto-update moves to, and when.
+type AutoUpdatePolicy struct {
+	// Pin is the version to run whatever the newer releases, empty if unset
+	Pin     string
+	Channel UpdateChannel
+	// Denylist are versions never updated to
+	Denylist []string
+	// RolloutPercentage is the share of nodes updating to a release, each
+	// node falling in or out of it consistently per release
+	RolloutPercentage int
+	// Window is when updates may happen, nil for any time
+	Window *MaintenanceWindow
+}
+
+// MaintenanceWindow is a daily UTC time range, on some weekdays only.
+type MaintenanceWindow struct {
+	// Days the window starts on, every day when empty
+	Days []time.Weekday
+	// Start and End are offsets from midnight, End before Start for a
+	// window across midnight
+	Start, End time.Duration
+}
+
+// ParseAutoUpdatePolicy parses the autoupdate_* env read with get:
+// autoupdate_pin (a version), autoupdate_channel (major, minor or patch),
+// autoupdate_denylist (comma separated versions),
+// autoupdate_rollout_percentage (0 to 100) and autoupdate_window
+// ("[Mon,Tue,...] HH:MM-HH:MM" in UTC).
+func ParseAutoUpdatePolicy(get func(string) string) (AutoUpdatePolicy, error) {
+	policy := AutoUpdatePolicy{Channel: UpdateChannelMajor, RolloutPercentage: 100}
+
+	if pin := strings.TrimSpace(get("autoupdate_pin")); pin != "" {
+		policy.Pin = CanonicalVersion(pin)
+		if !semver.IsValid(policy.Pin) {
+			return AutoUpdatePolicy{}, fmt.Errorf("autoupdate_pin should be a version like 1.4.2, however the current value is: %s", pin)
+		}
+	}
+
+	if channel := strings.ToLower(strings.TrimSpace(get("autoupdate_channel"))); channel != "" {
+		policy.Channel = UpdateChannel(channel)
+		if !slices.Contains([]UpdateChannel{UpdateChannelMajor, UpdateChannelMinor, UpdateChannelPatch}, policy.Channel) {
+			return AutoUpdatePolicy{}, fmt.Errorf("autoupdate_channel can only be set as major, minor or patch, however the current value is: %s", channel)
+		}
+		if policy.Pin != "" {
+			return AutoUpdatePolicy{}, fmt.Errorf("autoupdate_pin and autoupdate_channel cannot be set together")
+		}
+	}
+
+	for _, version := range strings.Split(get("autoupdate_denylist"), ",") {
+		if version = strings.TrimSpace(version); version == "" {
+			continue
+		}
+		if !semver.IsValid(CanonicalVersion(version)) {
+			return AutoUpdatePolicy{}, fmt.Errorf("autoupdate_denylist should be comma separated versions, got: %q", version)
+		}
+		policy.Denylist = append(policy.Denylist, CanonicalVersion(version))
+	}
+	if policy.Pin != "" && slices.Contains(policy.Denylist, policy.Pin) {
+		return AutoUpdatePolicy{}, fmt.Errorf("autoupdate_pin %s is in autoupdate_denylist", get("autoupdate_pin"))
+	}
+
+	if value := strings.TrimSpace(get("autoupdate_rollout_percentage")); value != "" {
+		percentage, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percentage < 0 || percentage > 100 {
+			return AutoUpdatePolicy{}, fmt.Errorf("autoupdate_rollout_percentage should be between 0 and 100, however the current value is: %s", value)
+		}
+		policy.RolloutPercentage = percentage
+	}
+
+	if value := strings.TrimSpace(get("autoupdate_window")); value != "" {
+		window, err := parseMaintenanceWindow(value)
+		if err != nil {
+			return AutoUpdatePolicy{}, fmt.Errorf("autoupdate_window should be like \"Sat,Sun 02:00-05:00\" (UTC), %w", err)
+		}
+		policy.Window = &window
+	}
+	return policy, nil
+}
+
+// CanonicalVersion returns version with the v prefix semver expects.
+func CanonicalVersion(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
+
+// Denied reports whether version is in the denylist.
+func (p AutoUpdatePolicy) Denied(version string) bool {
+	return slices.Contains(p.Denylist, CanonicalVersion(version))
+}
+
+// Allows reports whether the channel allows updating from current to
+// version, a newer release.
+func (p AutoUpdatePolicy) Allows(current, version string) bool {
+	current, version = CanonicalVersion(current), CanonicalVersion(version)
+	if semver.Compare(version, current) <= 0 {
+		return false
+	}
+	switch p.Channel {
+	case UpdateChannelPatch:
+		return semver.MajorMinor(version) == semver.MajorMinor(current)
+	case UpdateChannelMinor:
+		return semver.Major(version) == semver.Major(current)
+	}
+	return true
+}
+
+// Contains reports whether t falls in the window.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	sinceMidnight := t.Sub(t.Truncate(24 * time.Hour))
+	startsOn := func(day time.Weekday) bool {
+		return len(w.Days) == 0 || slices.Contains(w.Days, day)
+	}
+	if w.Start <= w.End {
+		return startsOn(t.Weekday()) && sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+	// across midnight, the part after midnight belongs to the previous day
+	if sinceMidnight >= w.Start {
+		return startsOn(t.Weekday())
+	}
+	return sinceMidnight < w.End && startsOn((t.Weekday()+6)%7)
+}
+
+func parseMaintenanceWindow(value string) (MaintenanceWindow, error) {
+	var window MaintenanceWindow
+	fields := strings.Fields(value)
+	if len(fields) == 2 {
+		for _, name := range strings.Split(fields[0], ",") {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return MaintenanceWindow{}, fmt.Errorf("unknown day: %q", name)
+			}
+			window.Days = append(window.Days, day)
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return MaintenanceWindow{}, fmt.Errorf("got: %q", value)
+	}
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return MaintenanceWindow{}, fmt.Errorf("got: %q", value)
+	}
+	var err error
+	if window.Start, err = parseTimeOfDay(start); err != nil {
+		return MaintenanceWindow{}, err
+	}
+	if window.End, err = parseTimeOfDay(end); err != nil {
+		return MaintenanceWindow{}, err
+	}
+	if window.Start == window.End {
+		return MaintenanceWindow{}, fmt.Errorf("the window is empty: %q", value)
+	}
+	return window, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
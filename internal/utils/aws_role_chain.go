@@ -0,0 +1,133 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultAWSRoleSessionName is the aws_external_role_session_name template
+	// used when it is not set, stable per node so CloudTrail can follow it
+	DefaultAWSRoleSessionName = "jfrog-credential-provider-$node"
+	// maxAWSSessionTags is the STS limit of session tags per AssumeRole call
+	maxAWSSessionTags = 50
+)
+
+var (
+	awsRoleARNPattern    = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+	awsExternalIDPattern = regexp.MustCompile(`^[\w+=,.@:/-]+$`)
+	// awsSessionNameInvalidChars matches what role session names and source
+	// identities may not contain
+	awsSessionNameInvalidChars = regexp.MustCompile(`[^\w+=,.@-]`)
+)
+
+// AWSRoleHop is one role of the aws_external_role_arn chain, assumed with
+// the credentials of the previous hop.
+type AWSRoleHop struct {
+	RoleARN    string `json:"role_arn"`
+	ExternalID string `json:"external_id,omitempty"`
+	// Policy is an inline session policy, PolicyARNs are managed ones, both
+	// can only narrow the permissions of the role
+	Policy            string            `json:"policy,omitempty"`
+	PolicyARNs        []string          `json:"policy_arns,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty"`
+	TransitiveTagKeys []string          `json:"transitive_tag_keys,omitempty"`
+}
+
+// ParseAWSRoleChain parses aws_external_role_arn: a role ARN, comma
+// separated role ARNs, or a JSON array of AWSRoleHop, in the order the roles
+// are assumed.
+func ParseAWSRoleChain(value string) ([]AWSRoleHop, error) {
+	value = strings.TrimSpace(value)
+	var chain []AWSRoleHop
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &chain); err != nil {
+			return nil, fmt.Errorf("aws_external_role_arn is not a valid JSON role chain: %v", err)
+		}
+	} else if value != "" {
+		for _, roleARN := range strings.Split(value, ",") {
+			chain = append(chain, AWSRoleHop{RoleARN: strings.TrimSpace(roleARN)})
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("aws_external_role_arn should name at least one role")
+	}
+	for i, hop := range chain {
+		if !awsRoleARNPattern.MatchString(hop.RoleARN) {
+			return nil, fmt.Errorf("aws_external_role_arn hop %d should be an IAM role ARN, got: %q", i+1, hop.RoleARN)
+		}
+		if hop.ExternalID != "" && !ValidAWSExternalID(hop.ExternalID) {
+			return nil, fmt.Errorf("aws_external_role_arn hop %d has an invalid external_id", i+1)
+		}
+		if hop.Policy != "" && !json.Valid([]byte(hop.Policy)) {
+			return nil, fmt.Errorf("aws_external_role_arn hop %d policy should be a JSON policy document", i+1)
+		}
+		if len(hop.Tags) > maxAWSSessionTags {
+			return nil, fmt.Errorf("aws_external_role_arn hop %d has more than %d tags", i+1, maxAWSSessionTags)
+		}
+		for _, key := range hop.TransitiveTagKeys {
+			if _, ok := hop.Tags[key]; !ok {
+				return nil, fmt.Errorf("aws_external_role_arn hop %d transitive tag key %s is not one of its tags", i+1, key)
+			}
+		}
+	}
+	return chain, nil
+}
+
+// ValidAWSExternalID reports whether id is an ExternalId STS accepts.
+func ValidAWSExternalID(id string) bool {
+	return len(id) >= 2 && len(id) <= 1224 && awsExternalIDPattern.MatchString(id)
+}
+
+// ParseAWSSessionTags parses aws_external_role_session_tags, comma separated
+// key=value pairs. Values may use the ExpandAWSSessionTemplate variables.
+func ParseAWSSessionTags(value string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, tagValue, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || len(key) > 128 {
+			return nil, fmt.Errorf("aws_external_role_session_tags should be comma separated key=value pairs, got: %q", pair)
+		}
+		tags[key] = strings.TrimSpace(tagValue)
+	}
+	if len(tags) > maxAWSSessionTags {
+		return nil, fmt.Errorf("aws_external_role_session_tags has more than %d tags", maxAWSSessionTags)
+	}
+	return tags, nil
+}
+
+// ExpandAWSSessionTemplate replaces $cluster and $node in template with the
+// cluster name and the node name.
+func ExpandAWSSessionTemplate(template, cluster, node string) string {
+	return strings.NewReplacer("$cluster", cluster, "$node", node).Replace(template)
+}
+
+// AWSSessionName turns an expanded template into a valid role session name
+// or source identity: invalid characters become '-', cut to 64 characters.
+func AWSSessionName(value string) string {
+	value = awsSessionNameInvalidChars.ReplaceAllString(value, "-")
+	if len(value) > 64 {
+		value = value[:64]
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultAzureCloudName = "AzureCloud"
+
+	// Azure workload identity service account annotations, read by
+	// azure_auth_method=workload_identity_exchange
+	AzureWorkloadIdentityClientIDAnnotation = "azure.workload.identity/client-id"
+	AzureWorkloadIdentityTenantIDAnnotation = "azure.workload.identity/tenant-id"
+)
+
+// azureAuthorityHosts maps the accepted azure_cloud_name values to their
+// Azure AD authority host. The *Cloud aliases are the azEnvironment names
+// reported by IMDS.
+var azureAuthorityHosts = map[string]string{
+	"AzureCloud":             "https://login.microsoftonline.com",
+	"AzurePublicCloud":       "https://login.microsoftonline.com",
+	"AzureChinaCloud":        "https://login.partner.microsoftonline.cn",
+	"AzureUSGovernment":      "https://login.microsoftonline.us",
+	"AzureUSGovernmentCloud": "https://login.microsoftonline.us",
+	"AzureGermanCloud":       "https://login.microsoftonline.de",
+}
+
+// AzureCloudNames returns the azure_cloud_name values known without an
+// azure_authority_host, sorted.
+func AzureCloudNames() []string {
+	names := make([]string, 0, len(azureAuthorityHosts))
+	for name := range azureAuthorityHosts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// AzureAuthorityHost returns the Azure AD authority host of a cloud. An
+// authorityHost override wins and lets air-gapped clouds use any cloud name,
+// otherwise cloudName must be a known cloud, AzureCloud when empty.
+func AzureAuthorityHost(cloudName, authorityHost string) (string, error) {
+	if authorityHost != "" {
+		u, err := url.Parse(authorityHost)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return "", fmt.Errorf("azure_authority_host should be an https URL, got: %s", authorityHost)
+		}
+		return strings.TrimSuffix(authorityHost, "/"), nil
+	}
+	if cloudName == "" {
+		cloudName = DefaultAzureCloudName
+	}
+	host, ok := azureAuthorityHosts[cloudName]
+	if !ok {
+		return "", fmt.Errorf("azure_cloud_name can only be set as %s, or any name with azure_authority_host set, however the current value is: %s", strings.Join(AzureCloudNames(), ", "), cloudName)
+	}
+	return host, nil
+}
@@ -36,7 +36,7 @@ func HttpReq(s *service.Service, ctx context.Context, url string, body []byte, r
 		}
 	}
 
-	resp, err := s.Client.Do(req)
+	resp, err := s.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Error sending artifactory create token request, Cause %s", err)
 	}
@@ -112,6 +112,15 @@ env:
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
+			// credential sources register their schemas in the provider package
+			if _, ok := GetConfigSchema(tc.cloudProvider); !ok {
+				RegisterConfigSchema(tc.cloudProvider, ConfigSchema{})
+				t.Cleanup(func() {
+					schemasMu.Lock()
+					delete(schemas, tc.cloudProvider)
+					schemasMu.Unlock()
+				})
+			}
 			dir := t.TempDir()
 			platformPath := filepath.Join(dir, tc.platformFile)
 			jfrogPath := filepath.Join(dir, "jfrog-provider.yaml")
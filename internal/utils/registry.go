@@ -0,0 +1,121 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	CacheKeyTypeRegistry = "Registry"
+	CacheKeyTypeImage    = "Image"
+	CacheKeyTypeGlobal   = "Global"
+)
+
+// ImageScope describes which Artifactory repository path an image is pulled
+// from and which registry keys the credentials are returned under.
+type ImageScope struct {
+	// Prefix is the matched image path prefix (e.g. docker-remote), empty when none matched
+	Prefix string
+	// Scope is the token scope configured for Prefix, empty when none matched
+	Scope string
+	// RegistryKeys are the keys of the auth section in the kubelet response
+	RegistryKeys []string
+}
+
+// ResolveCacheKeyType validates the cache_key_type env value and applies its
+// default. kubelet keys its Registry and Global caches by host only, so a
+// response scoped to one path prefix would be reused for images under other
+// prefixes; image_prefix_scopes therefore defaults to, and requires, Image.
+func ResolveCacheKeyType(cacheKeyType string, prefixScopes map[string]string) (string, error) {
+	if cacheKeyType == "" {
+		if len(prefixScopes) > 0 {
+			return CacheKeyTypeImage, nil
+		}
+		return CacheKeyTypeRegistry, nil
+	}
+	if !slices.Contains([]string{CacheKeyTypeRegistry, CacheKeyTypeImage, CacheKeyTypeGlobal}, cacheKeyType) {
+		return "", fmt.Errorf("cache_key_type can only be set as Registry, Image or Global however the current value is: %s", cacheKeyType)
+	}
+	if len(prefixScopes) > 0 && cacheKeyType != CacheKeyTypeImage {
+		return "", fmt.Errorf("cache_key_type must be Image when image_prefix_scopes is set, however the current value is: %s", cacheKeyType)
+	}
+	return cacheKeyType, nil
+}
+
+// ParseImagePrefixScopes parses the image_prefix_scopes env value, a JSON
+// object mapping image path prefixes to Artifactory token scopes, e.g.
+// {"docker-remote": "applied-permissions/groups:docker-readers"}. Leading and
+// trailing slashes are trimmed from the prefixes.
+func ParseImagePrefixScopes(value string) (map[string]string, error) {
+	prefixScopes := map[string]string{}
+	if value == "" {
+		return prefixScopes, nil
+	}
+	raw := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("image_prefix_scopes must be a JSON object of image path prefix to token scope: %w", err)
+	}
+	for prefix, scope := range raw {
+		trimmed := strings.Trim(prefix, "/")
+		if trimmed == "" {
+			return nil, fmt.Errorf("image_prefix_scopes contains an empty image path prefix")
+		}
+		if _, ok := prefixScopes[trimmed]; ok {
+			return nil, fmt.Errorf("image_prefix_scopes contains the image path prefix %q more than once", trimmed)
+		}
+		prefixScopes[trimmed] = scope
+	}
+	return prefixScopes, nil
+}
+
+// ResolveImageScope matches the repository path of image against the
+// configured prefixes (longest match wins, on path segment boundaries) and
+// returns the registry keys to answer with. A matched prefix produces a
+// single host/prefix key so kubelet only reuses the scoped token for that
+// path; otherwise the registry host plus any additionalKeys are returned.
+func ResolveImageScope(image string, prefixScopes map[string]string, additionalKeys []string) ImageScope {
+	host, path, _ := strings.Cut(image, "/")
+
+	var matched, scope string
+	for prefix, prefixScope := range prefixScopes {
+		trimmed := strings.Trim(prefix, "/")
+		if (path == trimmed || strings.HasPrefix(path, trimmed+"/")) && len(trimmed) > len(matched) {
+			matched, scope = trimmed, prefixScope
+		}
+	}
+	if matched != "" {
+		return ImageScope{
+			Prefix:       matched,
+			Scope:        scope,
+			RegistryKeys: []string{host + "/" + matched},
+		}
+	}
+
+	keys := []string{}
+	if host != "" {
+		keys = append(keys, host)
+	}
+	for _, key := range additionalKeys {
+		key = strings.TrimSpace(key)
+		if key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return ImageScope{RegistryKeys: keys}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveImageScope(t *testing.T) {
+	prefixScopes := map[string]string{
+		"docker-remote":        "applied-permissions/groups:remote-readers",
+		"docker-remote/team-a": "applied-permissions/groups:team-a",
+		"docker-local":         "applied-permissions/groups:local-readers",
+		"/docker-dev/":         "applied-permissions/groups:dev-readers",
+	}
+
+	cases := []struct {
+		name           string
+		image          string
+		additionalKeys []string
+		wantPrefix     string
+		wantScope      string
+		wantKeys       []string
+	}{
+		{
+			name:       "matches prefix",
+			image:      "example.jfrog.io/docker-local/nginx:1.27",
+			wantPrefix: "docker-local",
+			wantScope:  "applied-permissions/groups:local-readers",
+			wantKeys:   []string{"example.jfrog.io/docker-local"},
+		},
+		{
+			name:       "longest prefix wins",
+			image:      "example.jfrog.io/docker-remote/team-a/app:latest",
+			wantPrefix: "docker-remote/team-a",
+			wantScope:  "applied-permissions/groups:team-a",
+			wantKeys:   []string{"example.jfrog.io/docker-remote/team-a"},
+		},
+		{
+			name:       "prefix with surrounding slashes keeps its scope",
+			image:      "example.jfrog.io/docker-dev/app:1.0",
+			wantPrefix: "docker-dev",
+			wantScope:  "applied-permissions/groups:dev-readers",
+			wantKeys:   []string{"example.jfrog.io/docker-dev"},
+		},
+		{
+			name:     "prefix only matches whole segments",
+			image:    "example.jfrog.io/docker-remote-2/app",
+			wantKeys: []string{"example.jfrog.io"},
+		},
+		{
+			name:           "no match returns host and additional keys",
+			image:          "example.jfrog.io/other/app",
+			additionalKeys: []string{"*.jfrog.io", " example.jfrog.io ", ""},
+			wantKeys:       []string{"example.jfrog.io", "*.jfrog.io"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ResolveImageScope(tc.image, prefixScopes, tc.additionalKeys)
+			if got.Prefix != tc.wantPrefix || got.Scope != tc.wantScope {
+				t.Fatalf("expected prefix %q scope %q, got %+v", tc.wantPrefix, tc.wantScope, got)
+			}
+			if !slices.Equal(got.RegistryKeys, tc.wantKeys) {
+				t.Fatalf("expected keys %v, got %v", tc.wantKeys, got.RegistryKeys)
+			}
+		})
+	}
+}
+
+func TestResolveCacheKeyType(t *testing.T) {
+	scoped := map[string]string{"docker-remote": "scope"}
+
+	if got, err := ResolveCacheKeyType("", nil); err != nil || got != CacheKeyTypeRegistry {
+		t.Fatalf("expected Registry default, got %q, %v", got, err)
+	}
+	if got, err := ResolveCacheKeyType("", scoped); err != nil || got != CacheKeyTypeImage {
+		t.Fatalf("expected Image default with prefix scopes, got %q, %v", got, err)
+	}
+	if _, err := ResolveCacheKeyType(CacheKeyTypeRegistry, scoped); err == nil {
+		t.Fatal("expected error for Registry cache key type with prefix scopes")
+	}
+	if _, err := ResolveCacheKeyType("registry", nil); err == nil {
+		t.Fatal("expected error for unknown cache key type")
+	}
+}
+
+func TestParseImagePrefixScopes(t *testing.T) {
+	if _, err := ParseImagePrefixScopes(`{"docker-remote": "scope"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseImagePrefixScopes(`docker-remote=scope`); err == nil {
+		t.Fatal("expected error for non JSON value")
+	}
+	if _, err := ParseImagePrefixScopes(`{"/": "scope"}`); err == nil {
+		t.Fatal("expected error for empty prefix")
+	}
+	prefixScopes, err := ParseImagePrefixScopes(`{"docker-remote/": "scope"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefixScopes["docker-remote"] != "scope" {
+		t.Fatalf("expected trailing slash to be trimmed, got %v", prefixScopes)
+	}
+	if got := ResolveImageScope("example.jfrog.io/docker-remote/app", prefixScopes, nil); got.Scope != "scope" {
+		t.Fatalf("expected scope for trailing slash prefix, got %+v", got)
+	}
+	if _, err := ParseImagePrefixScopes(`{"docker-remote": "a", "/docker-remote/": "b"}`); err == nil {
+		t.Fatal("expected error for duplicate prefix after trimming")
+	}
+}
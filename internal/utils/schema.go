@@ -0,0 +1,160 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ConfigSchema declares the provider env of one credential source
+// (cloud_provider value). It drives ValidateJfrogProviderConfig when the
+// config is merged and CreateProviderConfigFromEnv when it is generated.
+type ConfigSchema struct {
+	// Env lists every env var the source reads
+	Env []string
+	// Required env vars, whatever the auth method
+	Required []string
+	// MethodEnv is the env var selecting the auth method, e.g. aws_auth_method
+	MethodEnv string
+	// Methods maps each accepted MethodEnv value to the env vars it requires
+	// on top of Required
+	Methods map[string][]string
+	// Annotated maps a service account annotation key required by the
+	// tokenAttributes to the env vars required instead of those of the
+	// method, for flows that exchange the pod's own token
+	Annotated map[string][]string
+	// ServiceAccountToken maps the auth methods ("" when there is no
+	// MethodEnv) exchanging the pod's service account token to the annotation
+	// keys of the tokenAttributes a generated config gets for them
+	ServiceAccountToken map[string]TokenAttributes
+	// Forbidden maps env vars the source refuses to the reason why
+	Forbidden map[string]string
+	// Validate runs checks that do not fit the fields above, may be nil
+	Validate func(config Provider) error
+}
+
+// commonEnv is the provider env shared by all credential sources.
+var commonEnv = []string{
+	"artifactory_url", "cloud_provider", "artifactory_user", "secret_ttl_seconds",
+	"cache_key_type", "image_prefix_scopes", "additional_registry_keys",
+	"jfrog_token_scope", "jfrog_token_refreshable",
+	"disable_token_cache", "token_cache_dir", "token_cache_safety_margin_seconds",
+	"cache_duration_skew_seconds",
+	"credential_daemon_socket", "credential_daemon_timeout_seconds",
+	"http_timeout_seconds", "http_retry_max_attempts", "http_retry_base_delay_ms", "http_retry_max_delay_ms",
+	"log_level", "disable_provider_autoupdate",
+	"cloud_detection_timeout_ms", "cloud_detection_cache_file",
+	"autoupdate_pin", "autoupdate_channel", "autoupdate_denylist",
+	"autoupdate_rollout_percentage", "autoupdate_window",
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = map[string]ConfigSchema{}
+)
+
+// RegisterConfigSchema adds or replaces the schema of a cloud_provider value.
+// Credential sources register theirs along with the source.
+func RegisterConfigSchema(cloudProvider string, schema ConfigSchema) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	schemas[cloudProvider] = schema
+}
+
+// GetConfigSchema returns the schema registered for a cloud_provider value.
+func GetConfigSchema(cloudProvider string) (ConfigSchema, bool) {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	schema, ok := schemas[cloudProvider]
+	return schema, ok
+}
+
+// ConfigSchemaNames returns the cloud_provider values with a registered
+// schema, sorted.
+func ConfigSchemaNames() []string {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ProviderEnvNames returns the env vars of all credential sources plus the
+// common ones, sorted and without duplicates.
+func ProviderEnvNames() []string {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	names := slices.Clone(commonEnv)
+	for _, schema := range schemas {
+		names = append(names, schema.Env...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// ValidateEnv checks the provider env of config against the schema.
+func (s ConfigSchema) ValidateEnv(config Provider) error {
+	for name, reason := range s.Forbidden {
+		if GetEnvVarValue(config.Env, name) != "" {
+			return fmt.Errorf("%s is not supported, %s", name, reason)
+		}
+	}
+
+	required := slices.Clone(s.Required)
+	method := GetEnvVarValue(config.Env, s.MethodEnv)
+	if s.MethodEnv != "" {
+		methodRequired, ok := s.Methods[method]
+		if !ok {
+			accepted := make([]string, 0, len(s.Methods))
+			for name := range s.Methods {
+				if name != "" {
+					accepted = append(accepted, name)
+				}
+			}
+			slices.Sort(accepted)
+			return fmt.Errorf("%s can only be set as %s however the current value is: %s", s.MethodEnv, strings.Join(accepted, ", "), method)
+		}
+		required = append(required, methodRequired...)
+	}
+	if config.TokenAttributes != nil {
+		for annotation, annotatedRequired := range s.Annotated {
+			if slices.Contains(config.TokenAttributes.RequiredServiceAccountAnnotationKeys, annotation) {
+				required = append(slices.Clone(s.Required), annotatedRequired...)
+				break
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range required {
+		if GetEnvVarValue(config.Env, name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("ERROR in JFrog Credentials provider, environment variables missing: %s", strings.Join(missing, ", "))
+	}
+
+	if s.Validate != nil {
+		return s.Validate(config)
+	}
+	return nil
+}
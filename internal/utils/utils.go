@@ -33,6 +33,16 @@ const (
 	CloudProviderAWS    = "aws"
 	CloudProviderAzure  = "azure"
 	CloudProviderGoogle = "google"
+	// CloudProviderKubernetes exchanges the projected service account token directly,
+	// for clusters without a cloud workload identity (on-prem, kind, k3s, bare metal)
+	CloudProviderKubernetes = "kubernetes"
+	// CloudProviderStatic serves an access token mounted on the node, for
+	// air-gapped nodes without a metadata service or OIDC issuer
+	CloudProviderStatic = "static"
+
+	// GoogleServiceAccountAnnotation names the Google service account of a
+	// Kubernetes service account under GKE Workload Identity
+	GoogleServiceAccountAnnotation = "iam.gke.io/gcp-service-account"
 )
 
 // CredentialProviderRequest is the request sent by the kubelet.
@@ -54,10 +64,13 @@ type AuthSection struct {
 
 // CredentialProviderResponse is the response expected by the kubelet.
 type CredentialProviderResponse struct {
-	ApiVersion   string      `json:"apiVersion"`
-	Kind         string      `json:"kind"`
-	CacheKeyType string      `json:"cacheKeyType"`
-	Auth         AuthSection `json:"auth"`
+	ApiVersion   string `json:"apiVersion"`
+	Kind         string `json:"kind"`
+	CacheKeyType string `json:"cacheKeyType"`
+	// CacheDuration is how long kubelet may cache the credentials, e.g. "4h55m0s".
+	// When omitted kubelet falls back to the provider's defaultCacheDuration.
+	CacheDuration string      `json:"cacheDuration,omitempty"`
+	Auth          AuthSection `json:"auth"`
 }
 
 type Provider struct {
@@ -92,15 +105,30 @@ type CredentialProviderConfig struct {
 }
 
 type AWSEnvVariables struct {
-	AWSAuthMethod                  string
-	AWSRoleName                    string
-	AWSExternalRoleARN             string
+	AWSAuthMethod string
+	AWSRoleName   string
+	// AWSExternalRoleChain lists the roles assume_external_role assumes in
+	// order, parsed from aws_external_role_arn
+	AWSExternalRoleChain           []AWSRoleHop
 	AWSExternalRoleDurationSeconds int
-	JFrogOIDCProviderName          string
-	SecretName                     string
-	ResourceServerName             string
-	UserPoolName                   string
-	UserPoolResourceScope          string
+	// AWSExternalRoleSessionName and AWSExternalRoleSourceIdentity are the
+	// expanded role session name and CloudTrail source identity
+	AWSExternalRoleSessionName    string
+	AWSExternalRoleSourceIdentity string
+	// SigningAlgorithm signs the GetCallerIdentity request sent to
+	// Artifactory, sigv4a (default) or sigv4
+	SigningAlgorithm      string
+	JFrogOIDCProviderName string
+	SecretName            string
+	ResourceServerName    string
+	UserPoolName          string
+	UserPoolResourceScope string
+	// WebIdentityTokenFile is the IRSA token read by web_identity_token_file
+	WebIdentityTokenFile string
+	// ContainerCredentialsURI and ContainerAuthorizationTokenFile locate the
+	// EKS Pod Identity agent and its token for pod_identity
+	ContainerCredentialsURI         string
+	ContainerAuthorizationTokenFile string
 }
 
 func getStructFields(s interface{}) []string {
@@ -332,47 +360,37 @@ func ValidateJfrogProviderConfig(config Provider, cloudProvider string) error {
 		return fmt.Errorf("missing required fields in provider: artifactory_url")
 	}
 
-	switch cloudProvider {
-	case CloudProviderAWS:
-		awsAuthMethod := GetEnvVarValue(config.Env, "aws_auth_method")
-		if awsAuthMethod != "cognito_oidc" && awsAuthMethod != "assume_role" && awsAuthMethod != "assume_external_role" {
-			return fmt.Errorf("aws_auth_method can only be set as cognito_oidc, assume_role or assume_external_role however the current value is: %s", awsAuthMethod)
-		}
+	prefixScopes, err := ParseImagePrefixScopes(GetEnvVarValue(config.Env, "image_prefix_scopes"))
+	if err != nil {
+		return err
+	}
+	if _, err := ResolveCacheKeyType(GetEnvVarValue(config.Env, "cache_key_type"), prefixScopes); err != nil {
+		return err
+	}
+	// an explicit cloud_provider in the provider env wins over the detected one
+	if configuredCloudProvider := GetEnvVarValue(config.Env, "cloud_provider"); configuredCloudProvider != "" {
+		cloudProvider = configuredCloudProvider
+	}
 
-		if awsAuthMethod == "cognito_oidc" {
-			if GetEnvVarValue(config.Env, "jfrog_oidc_provider_name") == "" || GetEnvVarValue(config.Env, "secret_name") == "" || GetEnvVarValue(config.Env, "user_pool_name") == "" || GetEnvVarValue(config.Env, "resource_server_name") == "" || GetEnvVarValue(config.Env, "user_pool_resource_scope") == "" {
-				return fmt.Errorf("aws_auth_method as cognito_oidc has one or more missing environment variables: jfrog_oidc_provider_name, secret_name, userPoolResourceDomain, userPoolResourceScope")
-			}
+	if refreshable := GetEnvVarValue(config.Env, "jfrog_token_refreshable"); refreshable != "" {
+		if _, err := strconv.ParseBool(refreshable); err != nil {
+			return fmt.Errorf("jfrog_token_refreshable must be true or false, however the current value is: %s", refreshable)
 		}
+	}
 
-		if awsAuthMethod == "assume_external_role" && GetEnvVarValue(config.Env, "aws_external_role_arn") == "" {
-			return fmt.Errorf("aws_auth_method as assume_external_role requires aws_external_role_arn to be set")
-		}
+	if _, err := ParseAutoUpdatePolicy(func(name string) string { return GetEnvVarValue(config.Env, name) }); err != nil {
+		return err
+	}
 
-	case CloudProviderAzure:
-		azureAuthMethod := GetEnvVarValue(config.Env, "azure_auth_method")
-		if azureAuthMethod != "" && azureAuthMethod != "imds_direct" {
-			return fmt.Errorf("azure_auth_method can only be set as imds_direct however the current value is :%s", azureAuthMethod)
-		}
-		if config.TokenAttributes != nil && slices.Contains(config.TokenAttributes.RequiredServiceAccountAnnotationKeys, "JFrogExchange") {
-			if GetEnvVarValue(config.Env, "azure_app_audience") == "" || GetEnvVarValue(config.Env, "jfrog_oidc_provider_name") == "" {
-				return fmt.Errorf("ERROR in JFrog Credentials provider, environment variables missing: azure_app_audience, jfrog_oidc_provider_name")
-			}
-		} else if azureAuthMethod == "imds_direct" {
-			// IMDS direct flow: fetches an app-scoped access token from IMDS using the
-			// app client id; no Azure AD impersonation, so no tenant id or audience.
-			if GetEnvVarValue(config.Env, "azure_app_client_id") == "" || GetEnvVarValue(config.Env, "azure_nodepool_client_id") == "" || GetEnvVarValue(config.Env, "jfrog_oidc_provider_name") == "" {
-				return fmt.Errorf("ERROR in JFrog Credentials provider, environment variables missing: azure_app_client_id, azure_nodepool_client_id, jfrog_oidc_provider_name")
-			}
-		} else if GetEnvVarValue(config.Env, "azure_app_client_id") == "" || GetEnvVarValue(config.Env, "azure_tenant_id") == "" || GetEnvVarValue(config.Env, "azure_app_audience") == "" || GetEnvVarValue(config.Env, "azure_nodepool_client_id") == "" || GetEnvVarValue(config.Env, "jfrog_oidc_provider_name") == "" {
-			return fmt.Errorf("ERROR in JFrog Credentials provider, environment variables missing: azure_app_client_id, azure_tenant_id, azure_app_audience, azure_nodepool_client_id, jfrog_oidc_provider_name")
-		}
-	case CloudProviderGoogle:
-		if GetEnvVarValue(config.Env, "google_service_account_email") == "" || GetEnvVarValue(config.Env, "jfrog_oidc_provider_name") == "" || GetEnvVarValue(config.Env, "jfrog_oidc_audience") == "" {
-			return fmt.Errorf("ERROR in JFrog Credentials provider, environment variables missing: google_service_account_email, jfrog_oidc_provider_name, jfrog_oidc_audience")
-		}
+	// without cloud_provider the source is only known once it is detected
+	if cloudProvider == "" {
+		return nil
 	}
-	return nil
+	schema, ok := GetConfigSchema(cloudProvider)
+	if !ok {
+		return fmt.Errorf("cloud_provider %s is not supported, supported values are: %s", cloudProvider, strings.Join(ConfigSchemaNames(), ", "))
+	}
+	return schema.ValidateEnv(config)
 }
 
 func GetEnvVarValue(envVars []EnvVar, name string) string {
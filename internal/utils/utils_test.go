@@ -0,0 +1,187 @@
+// Copyright (c) JFrog Ltd. (2025)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func kubernetesProvider(env []EnvVar, tokenAttributes *TokenAttributes) Provider {
+	return Provider{
+		Name:                 "jfrog-credentials-provider",
+		MatchImages:          []string{"example.jfrog.io"},
+		DefaultCacheDuration: "4h",
+		APIVersion:           "credentialprovider.kubelet.k8s.io/v1",
+		TokenAttributes:      tokenAttributes,
+		Env:                  env,
+	}
+}
+
+func TestAzureAuthorityHost(t *testing.T) {
+	tests := map[string][2]string{
+		"public default": {"", "https://login.microsoftonline.com"},
+		"us government":  {"AzureUSGovernment", "https://login.microsoftonline.us"},
+		"china":          {"AzureChinaCloud", "https://login.partner.microsoftonline.cn"},
+		"germany":        {"AzureGermanCloud", "https://login.microsoftonline.de"},
+	}
+	for name, tt := range tests {
+		if host, err := AzureAuthorityHost(tt[0], ""); err != nil || host != tt[1] {
+			t.Errorf("%s: expected %s, got %q, %v", name, tt[1], host, err)
+		}
+	}
+	if host, _ := AzureAuthorityHost("AzureCloud", "https://login.example.scloud/"); host != "https://login.example.scloud" {
+		t.Errorf("expected the override to win, got %q", host)
+	}
+}
+
+func TestParseAWSRoleChain(t *testing.T) {
+	chain, err := ParseAWSRoleChain("arn:aws:iam::111111111111:role/shared-services, arn:aws:iam::222222222222:role/artifactory-access")
+	if err != nil || len(chain) != 2 || chain[1].RoleARN != "arn:aws:iam::222222222222:role/artifactory-access" {
+		t.Fatalf("unexpected chain %+v, %v", chain, err)
+	}
+
+	chain, err = ParseAWSRoleChain(`[
+		{"role_arn": "arn:aws:iam::111111111111:role/shared-services", "external_id": "shared-ext-id"},
+		{"role_arn": "arn:aws-us-gov:iam::222222222222:role/artifactory-access", "tags": {"cluster": "prod"}, "transitive_tag_keys": ["cluster"]}
+	]`)
+	if err != nil || len(chain) != 2 || chain[0].ExternalID != "shared-ext-id" || chain[1].Tags["cluster"] != "prod" {
+		t.Fatalf("unexpected chain %+v, %v", chain, err)
+	}
+
+	for name, value := range map[string]string{
+		"empty":              "",
+		"role name":          "artifactory-access",
+		"empty hop":          "arn:aws:iam::111111111111:role/shared-services,",
+		"bad json":           `[{"role_arn": }]`,
+		"bad external id":    `[{"role_arn": "arn:aws:iam::111111111111:role/r", "external_id": "no spaces"}]`,
+		"bad policy":         `[{"role_arn": "arn:aws:iam::111111111111:role/r", "policy": "s3:*"}]`,
+		"unknown transitive": `[{"role_arn": "arn:aws:iam::111111111111:role/r", "transitive_tag_keys": ["cluster"]}]`,
+	} {
+		if _, err := ParseAWSRoleChain(value); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAWSSessionSettings(t *testing.T) {
+	tags, err := ParseAWSSessionTags("cluster=$cluster, node=$node,,")
+	if err != nil || len(tags) != 2 || tags["node"] != "$node" {
+		t.Fatalf("unexpected tags %v, %v", tags, err)
+	}
+	if _, err := ParseAWSSessionTags("cluster"); err == nil {
+		t.Fatal("expected an error for a tag without value")
+	}
+
+	name := AWSSessionName(ExpandAWSSessionTemplate("jfrog-$cluster-$node", "eks prod", "ip-10-0-1-23.ec2.internal"))
+	if name != "jfrog-eks-prod-ip-10-0-1-23.ec2.internal" {
+		t.Fatalf("unexpected session name %q", name)
+	}
+	if name := AWSSessionName(strings.Repeat("n", 100)); len(name) != 64 {
+		t.Fatalf("expected the session name to be cut to 64 characters, got %d", len(name))
+	}
+}
+
+func TestParseAutoUpdatePolicy(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+	policy, err := ParseAutoUpdatePolicy(env(nil))
+	if err != nil || policy.Channel != UpdateChannelMajor || policy.RolloutPercentage != 100 || policy.Window != nil {
+		t.Fatalf("unexpected default policy %+v, %v", policy, err)
+	}
+
+	policy, err = ParseAutoUpdatePolicy(env(map[string]string{
+		"autoupdate_channel":            "Patch",
+		"autoupdate_denylist":           "1.4.3, v1.4.5",
+		"autoupdate_rollout_percentage": "25%",
+		"autoupdate_window":             "Sat,Sun 23:00-02:00",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.Denied("1.4.3") || !policy.Denied("v1.4.5") || policy.Denied("1.4.4") || policy.RolloutPercentage != 25 {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+	if !policy.Allows("1.4.2", "1.4.4") || policy.Allows("1.4.2", "1.5.0") || policy.Allows("1.4.2", "1.4.1") {
+		t.Fatal("expected the patch channel to allow newer patches of the current minor version only")
+	}
+	if policy.Channel = UpdateChannelMinor; !policy.Allows("1.4.2", "1.5.0") || policy.Allows("1.4.2", "2.0.0") {
+		t.Fatal("expected the minor channel to allow newer releases of the current major version only")
+	}
+
+	saturday := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for offset, expected := range map[time.Duration]bool{
+		23*time.Hour + 30*time.Minute: true,  // Saturday 23:30
+		25 * time.Hour:                true,  // Sunday 01:00, Saturday's window
+		47 * time.Hour:                true,  // Sunday 23:00
+		49 * time.Hour:                true,  // Monday 01:00, Sunday's window
+		50 * time.Hour:                false, // Monday 02:00
+		1 * time.Hour:                 false, // Saturday 01:00, Friday's window
+		12 * time.Hour:                false,
+	} {
+		if got := policy.Window.Contains(saturday.Add(offset)); got != expected {
+			t.Errorf("%s: expected %v, got %v", saturday.Add(offset).Format(time.RFC1123), expected, got)
+		}
+	}
+
+	for name, values := range map[string]map[string]string{
+		"bad pin":          {"autoupdate_pin": "latest"},
+		"pin and channel":  {"autoupdate_pin": "1.4.2", "autoupdate_channel": "patch"},
+		"denied pin":       {"autoupdate_pin": "1.4.2", "autoupdate_denylist": "1.4.2"},
+		"unknown channel":  {"autoupdate_channel": "nightly"},
+		"bad denylist":     {"autoupdate_denylist": "1.4.3,bad"},
+		"percentage range": {"autoupdate_rollout_percentage": "120"},
+		"unknown day":      {"autoupdate_window": "Caturday 02:00-04:00"},
+		"bad time":         {"autoupdate_window": "02:00-25:00"},
+		"empty window":     {"autoupdate_window": "02:00-02:00"},
+		"window no range":  {"autoupdate_window": "02:00"},
+	} {
+		if _, err := ParseAutoUpdatePolicy(env(values)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRegisterConfigSchema(t *testing.T) {
+	RegisterConfigSchema("oracle", ConfigSchema{Env: []string{"oci_region"}, Required: []string{"oci_region"}})
+	t.Cleanup(func() {
+		schemasMu.Lock()
+		delete(schemas, "oracle")
+		schemasMu.Unlock()
+	})
+
+	if !slices.Contains(ProviderEnvNames(), "oci_region") {
+		t.Fatal("expected oci_region in the provider env names")
+	}
+	if err := ValidateJfrogProviderConfig(kubernetesProvider([]EnvVar{{Name: "artifactory_url", Value: "example.jfrog.io"}}, nil), "oracle"); err == nil {
+		t.Fatal("expected error when oci_region is missing")
+	}
+	mistyped := kubernetesProvider([]EnvVar{{Name: "artifactory_url", Value: "example.jfrog.io"}, {Name: "cloud_provider", Value: "oracel"}}, nil)
+	if err := ValidateJfrogProviderConfig(mistyped, ""); err == nil || !strings.Contains(err.Error(), "oracle") {
+		t.Fatalf("expected error listing the registered cloud providers, got %v", err)
+	}
+}
+
+func TestProviderEnvNamesIncludesCommonSettings(t *testing.T) {
+	names := ProviderEnvNames()
+	for _, name := range []string{"disable_token_cache", "token_cache_dir", "cache_duration_skew_seconds", "credential_daemon_socket", "http_retry_max_attempts", "http_timeout_seconds", "log_level"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("expected %s in the provider env names", name)
+		}
+	}
+}
@@ -17,11 +17,15 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"jfrog-credential-provider/internal/fips"
 	"jfrog-credential-provider/internal/logger"
 	"jfrog-credential-provider/internal/provider"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 )
 
@@ -47,7 +51,16 @@ func main() {
 	watchProviderConfig := watchKubeletCmd.String("provider-config", "", "Provider config file name")
 	watchTimeout := watchKubeletCmd.Int("timeout", 60, "Timeout in seconds to watch kubelet health")
 
+	// Create a subcommand for serve
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	serveSocket := serveCmd.String("socket", "", "Unix socket to listen on, defaults to credential_daemon_socket or "+provider.DefaultDaemonSocket)
+
 	switch {
+	case len(os.Args) > 1 && (os.Args[1] == "version" || os.Args[1] == "--version"):
+		fmt.Println("jfrog-credential-provider " + Version)
+		fmt.Println(fips.Mode())
+		return
+
 	case len(os.Args) > 1 && os.Args[1] == "add-provider-config":
 		// Parse flags for the subcommand
 		addProviderConfigCmd.Parse(os.Args[2:])
@@ -71,15 +84,40 @@ func main() {
 		provider.WatchKubelet(*watchIsYaml, resolvedHome, resolvedConfig, *watchTimeout, logs)
 		return
 
-	default:
-		httpTimeout := 30 * time.Second
-		if v := os.Getenv("http_timeout_seconds"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil && n > 0 {
-				httpTimeout = time.Duration(n) * time.Second
-			}
+	case len(os.Args) > 1 && os.Args[1] == "serve":
+		serveCmd.Parse(os.Args[2:])
+		socket := *serveSocket
+		if socket == "" {
+			socket = os.Getenv("credential_daemon_socket")
+		}
+		if socket == "" {
+			socket = provider.DefaultDaemonSocket
+		}
+		logs, err := logger.NewLogger()
+		if err != nil {
+			log.Fatalf("Failed to initialize logger: %v", err)
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := provider.Serve(ctx, logs, socket, requestTimeout()); err != nil {
+			logs.Exit("ERROR in JFrog Credentials provider daemon: "+err.Error(), 1)
+		}
+		return
+
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout())
 		defer cancel()
 		provider.StartProvider(ctx, Version)
 	}
 }
+
+// requestTimeout bounds the whole credential flow of one kubelet request.
+func requestTimeout() time.Duration {
+	httpTimeout := 30 * time.Second
+	if v := os.Getenv("http_timeout_seconds"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			httpTimeout = time.Duration(n) * time.Second
+		}
+	}
+	return httpTimeout
+}